@@ -85,6 +85,35 @@ var ApproximateTokenEnabled = false
 var RetryTimes = 0
 var DefaultChannelWeight = uint(1)
 var RetryCooldownSeconds = 5
+var RetryCooldownMaxSeconds = 300 // 自适应负载均衡下连续失败时指数退避的冷却上限
+
+// 按 渠道+模型 熔断，开启后重试不再冷却整个渠道
+var CircuitBreakerEnabled = false
+var CircuitBreakerFailureThreshold = 5 // 连续失败次数达到阈值后熔断
+var CircuitBreakerOpenSeconds = 60     // 熔断时间，超时后进入半开状态放行试探请求
+
+// 完全相同的请求直接返回缓存的响应，令牌开启缓存或所属分组在 ResponseCacheGroups 中时生效
+var ResponseCacheEnabled = false
+var ResponseCacheSeconds = 3600
+var ResponseCacheRatio = 0.1 // 缓存命中时按原价的该比例计费
+var ResponseCacheGroups []string
+
+// 并发数超出上限的请求排队等待，队列已满或等待超时返回 429
+var ConcurrencyQueueSize = 100
+var ConcurrencyQueueSeconds = 30
+
+// 在线充值，金额以 QuotaPerUnit 对应的单位计，实付金额 = 充值金额 * PaymentExchangeRate
+var PaymentExchangeRate = 7.3
+var PaymentMinAmount = 1
+var PaymentMaxAmount = 50
+var PaymentCallbackAddress = ""    // 支付网关回调及跳转使用的地址，为空时使用 ServerAddress
+var PaymentOrderExpireMinutes = 30 // 超过该时间仍未支付的订单向网关查询，未支付则关闭
+
+// 易支付，兼容旧版本的 YI_PAY_PID、YI_PAY_KEY 环境变量
+var EpayEnabled = os.Getenv("YI_PAY_KEY") != ""
+var EpayAddress = "https://yi-pay.com"
+var EpayPid = os.Getenv("YI_PAY_PID")
+var EpaySecret = os.Getenv("YI_PAY_KEY")
 
 var RootUserEmail = ""
 
@@ -158,6 +187,24 @@ const (
 	RedemptionCodeStatusUsed     = 3 // also don't use 0
 )
 
+const (
+	RechargeStatusPending  = 1 // don't use 0, 0 is the default value!
+	RechargeStatusPaid     = 2
+	RechargeStatusExpired  = 3
+	RechargeStatusRefunded = 4
+)
+
+const (
+	PlanStatusEnabled  = 1 // don't use 0, 0 is the default value!
+	PlanStatusDisabled = 2 // also don't use 0
+)
+
+const (
+	SubscriptionStatusActive   = 1 // don't use 0, 0 is the default value!
+	SubscriptionStatusExpired  = 2
+	SubscriptionStatusUpgraded = 3 // 升级到其他套餐后被替换
+)
+
 const (
 	ChannelStatusUnknown          = 0
 	ChannelStatusEnabled          = 1 // don't use 0, 0 is the default value!
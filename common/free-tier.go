@@ -0,0 +1,78 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	FreeTierWindowDay  = "day"
+	FreeTierWindowHour = "hour"
+)
+
+// FreeTierRule 免费模型（模型倍率为 0）在每个窗口内可调用的次数
+type FreeTierRule struct {
+	Limit  int    `json:"limit"`
+	Window string `json:"window"`
+}
+
+// FreeTier 分组 -> 模型（支持 * 通配符）-> 免费次数，分组为 * 时对所有分组生效
+// 没有匹配规则或 limit 为 0 时不限制调用次数，可在分组中用 0 覆盖 * 的规则
+// 默认不限制，兼容旧版本的 ENABLE_LIMIT_FREE=true：未在后台配置时每个用户每天可免费调用 30 次
+var FreeTier = map[string]map[string]FreeTierRule{}
+
+// FreeTierTimezone 按天、按小时重置免费次数时使用的时区
+var FreeTierTimezone = "Asia/Shanghai"
+
+var freeTierLocation, _ = time.LoadLocation(FreeTierTimezone)
+
+func FreeTier2JSONString() string {
+	jsonBytes, err := json.Marshal(FreeTier)
+	if err != nil {
+		SysError("error marshalling free tier: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateFreeTierByJSONString(jsonStr string) error {
+	freeTier := make(map[string]map[string]FreeTierRule)
+	if err := json.Unmarshal([]byte(jsonStr), &freeTier); err != nil {
+		return err
+	}
+	for group, rules := range freeTier {
+		for model, rule := range rules {
+			if rule.Window != FreeTierWindowDay && rule.Window != FreeTierWindowHour {
+				return fmt.Errorf("分组 %s 模型 %s 的窗口只能为 day 或 hour", group, model)
+			}
+		}
+	}
+	FreeTier = freeTier
+	return nil
+}
+
+func UpdateFreeTierTimezone(name string) error {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+	FreeTierTimezone = name
+	freeTierLocation = loc
+	return nil
+}
+
+// FreeTierWindow 返回 now 所在窗口的起止时间
+func FreeTierWindow(window string, now time.Time) (time.Time, time.Time) {
+	loc := freeTierLocation
+	if loc == nil {
+		loc = time.Local
+	}
+	now = now.In(loc)
+	year, month, day := now.Date()
+	if window == FreeTierWindowHour {
+		start := time.Date(year, month, day, now.Hour(), 0, 0, 0, loc)
+		return start, start.Add(time.Hour)
+	}
+	start := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 0, 1)
+}
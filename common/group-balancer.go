@@ -0,0 +1,32 @@
+package common
+
+import "encoding/json"
+
+const (
+	BalancerStrategyWeight   = "weight"   // 按渠道权重随机选择
+	BalancerStrategyAdaptive = "adaptive" // 按渠道健康度动态调整权重
+)
+
+// 分组 => 负载均衡策略，未配置的分组使用权重策略
+var GroupBalancer = map[string]string{}
+
+func GroupBalancer2JSONString() string {
+	jsonBytes, err := json.Marshal(GroupBalancer)
+	if err != nil {
+		SysError("error marshalling group balancer: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupBalancerByJSONString(jsonStr string) error {
+	GroupBalancer = make(map[string]string)
+	return json.Unmarshal([]byte(jsonStr), &GroupBalancer)
+}
+
+func GetGroupBalancer(name string) string {
+	strategy, ok := GroupBalancer[name]
+	if !ok || strategy == "" {
+		return BalancerStrategyWeight
+	}
+	return strategy
+}
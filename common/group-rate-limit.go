@@ -0,0 +1,38 @@
+package common
+
+import "encoding/json"
+
+// RateLimit 每分钟的请求数、token 数及同时处理的请求数上限，0 为不限制
+type RateLimit struct {
+	RPM         int `json:"rpm"`
+	TPM         int `json:"tpm"`
+	Concurrency int `json:"concurrency"`
+}
+
+// GroupRateLimitRule 分组的限流配置
+// RPM/TPM 是分组内每个用户默认的上限，用户单独设置后以用户的为准
+// TotalRPM/TotalTPM 及并发数则是整个分组共享的上限，避免单个分组占满公用的渠道
+type GroupRateLimitRule struct {
+	RateLimit
+	TotalRPM int `json:"total_rpm"`
+	TotalTPM int `json:"total_tpm"`
+}
+
+var GroupRateLimit = map[string]GroupRateLimitRule{}
+
+func GroupRateLimit2JSONString() string {
+	jsonBytes, err := json.Marshal(GroupRateLimit)
+	if err != nil {
+		SysError("error marshalling group rate limit: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupRateLimitByJSONString(jsonStr string) error {
+	GroupRateLimit = make(map[string]GroupRateLimitRule)
+	return json.Unmarshal([]byte(jsonStr), &GroupRateLimit)
+}
+
+func GetGroupRateLimit(name string) GroupRateLimitRule {
+	return GroupRateLimit[name]
+}
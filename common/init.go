@@ -50,6 +50,11 @@ func init() {
 			SessionSecret = os.Getenv("SESSION_SECRET")
 		}
 	}
+	if os.Getenv("ENABLE_LIMIT_FREE") == "true" {
+		FreeTier = map[string]map[string]FreeTierRule{
+			"*": {"*": {Limit: 30, Window: FreeTierWindowDay}},
+		}
+	}
 	if os.Getenv("SQLITE_PATH") != "" {
 		SQLitePath = os.Getenv("SQLITE_PATH")
 	}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "one_api"
+
+var (
+	relayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_requests_total",
+		Help:      "Total number of relay requests sent to upstream channels.",
+	}, []string{"model", "channel", "group", "status"})
+
+	relayRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_request_duration_seconds",
+		Help:      "Latency of relay requests sent to upstream channels.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"model", "channel", "group", "status"})
+
+	relayFirstTokenDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_first_token_duration_seconds",
+		Help:      "Time to first token of streaming relay requests.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30},
+	}, []string{"model", "channel", "group"})
+
+	relayRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_retries_total",
+		Help:      "Total number of relay retries on other channels.",
+	}, []string{"model", "group"})
+
+	channelCooldowns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "channel_cooldowns_total",
+		Help:      "Total number of channel cooldowns after relay failures.",
+	}, []string{"channel", "group"})
+
+	channelDisables = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "channel_disables_total",
+		Help:      "Total number of channels or channel keys disabled automatically.",
+	}, []string{"channel", "type"})
+
+	quotaConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quota_consumed_total",
+		Help:      "Total quota consumed by relay requests.",
+	}, []string{"model", "group"})
+
+	tokensConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_consumed_total",
+		Help:      "Total prompt and completion tokens consumed by relay requests.",
+	}, []string{"model", "group", "type"})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of database operations.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"operation", "table"})
+
+	redisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redis_command_duration_seconds",
+		Help:      "Latency of redis commands.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 14),
+	}, []string{"command"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		relayRequests,
+		relayRequestDuration,
+		relayFirstTokenDuration,
+		relayRetries,
+		channelCooldowns,
+		channelDisables,
+		quotaConsumed,
+		tokensConsumed,
+		dbDuration,
+		redisDuration,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RecordRelayRequest 记录一次上游请求，ttft 为 0 表示非流式请求或未收到数据
+func RecordRelayRequest(modelName string, channelId int, group string, status int, latency, ttft time.Duration) {
+	channel := strconv.Itoa(channelId)
+	statusCode := strconv.Itoa(status)
+	relayRequests.WithLabelValues(modelName, channel, group, statusCode).Inc()
+	relayRequestDuration.WithLabelValues(modelName, channel, group, statusCode).Observe(latency.Seconds())
+	if ttft > 0 {
+		relayFirstTokenDuration.WithLabelValues(modelName, channel, group).Observe(ttft.Seconds())
+	}
+}
+
+func RecordRelayRetry(modelName string, group string) {
+	relayRetries.WithLabelValues(modelName, group).Inc()
+}
+
+func RecordChannelCooldown(channelId int, group string) {
+	channelCooldowns.WithLabelValues(strconv.Itoa(channelId), group).Inc()
+}
+
+// RecordChannelDisable disableType 为 channel 或 key
+func RecordChannelDisable(channelId int, disableType string) {
+	channelDisables.WithLabelValues(strconv.Itoa(channelId), disableType).Inc()
+}
+
+func RecordQuotaConsumed(modelName string, group string, quota, promptTokens, completionTokens int) {
+	quotaConsumed.WithLabelValues(modelName, group).Add(float64(quota))
+	tokensConsumed.WithLabelValues(modelName, group, "prompt").Add(float64(promptTokens))
+	tokensConsumed.WithLabelValues(modelName, group, "completion").Add(float64(completionTokens))
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type redisStartTimeKey struct{}
+
+// RedisHook 统计 redis 命令耗时，pipeline 按整体计为一次
+type RedisHook struct{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartTimeKey{}, time.Now()), nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	observeRedis(ctx, cmd.Name())
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartTimeKey{}, time.Now()), nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	observeRedis(ctx, "pipeline")
+	return nil
+}
+
+func observeRedis(ctx context.Context, command string) {
+	startTime, ok := ctx.Value(redisStartTimeKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	redisDuration.WithLabelValues(command).Observe(time.Since(startTime).Seconds())
+}
@@ -263,6 +263,10 @@ func GetModelRatio(name string) []float64 {
 		name = strings.TrimSuffix(name, "-internet")
 	}
 	ratio, ok := ModelRatio[name]
+	if !ok && strings.HasPrefix(name, "ft:") {
+		// 微调模型 ft:gpt-3.5-turbo-0613:org:suffix:id 使用基础模型的倍率
+		ratio, ok = ModelRatio[strings.Split(name, ":")[1]]
+	}
 	if !ok {
 		SysError("model ratio not found: " + name)
 		return []float64{30, 30}
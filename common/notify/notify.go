@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/common/telegram"
+	"one-api/model"
+	"syscall"
+	"time"
+)
+
+// Message 推送给用户的通知，webhook 收到的是该结构的 JSON
+type Message struct {
+	Type      string `json:"type"`
+	UserId    int    `json:"user_id"`
+	TokenId   int    `json:"token_id,omitempty"`
+	TokenName string `json:"token_name,omitempty"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	Quota     int    `json:"quota"`     // 触发时的剩余额度，周期预算提醒时为已用额度
+	Threshold int    `json:"threshold"` // 触发的阈值
+	Timestamp int64  `json:"timestamp"`
+}
+
+var errWebhookAddress = errors.New("webhook address is not allowed")
+
+// webhook 地址由用户填写，连接时校验解析后的 IP，禁止访问本机及内网，也不跟随重定向
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+					return errWebhookAddress
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// ValidateWebhook 校验用户填写的 webhook 地址，只允许解析到公网地址的 http(s) 链接
+func ValidateWebhook(rawURL string) error {
+	webhook, err := url.Parse(rawURL)
+	if err != nil || (webhook.Scheme != "http" && webhook.Scheme != "https") || webhook.Hostname() == "" {
+		return errors.New("无效的 webhook 地址")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, webhook.Hostname())
+	if err != nil || len(ips) == 0 {
+		return errors.New("无法解析 webhook 地址")
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip.IP) {
+			return errors.New("webhook 地址不能指向本机或内网")
+		}
+	}
+	return nil
+}
+
+// Send 通过邮件、Telegram 及 webhook 推送，用户未配置的方式跳过
+func Send(user *model.User, message *Message) {
+	message.UserId = user.Id
+	if message.Timestamp == 0 {
+		message.Timestamp = common.GetTimestamp()
+	}
+
+	if user.Email != "" {
+		topUpLink := fmt.Sprintf("%s/topup", common.ServerAddress)
+		content := fmt.Sprintf("%s<br/>充值链接：<a href='%s'>%s</a>", message.Content, topUpLink, topUpLink)
+		if err := common.SendEmail(message.Title, user.Email, content); err != nil {
+			common.SysError("failed to send notify email: " + err.Error())
+		}
+	}
+	if user.TelegramId != 0 && telegram.TGEnabled {
+		if err := telegram.SendMessage(user.TelegramId, fmt.Sprintf("<b>%s</b>\n%s", message.Title, message.Content)); err != nil {
+			common.SysError("failed to send notify telegram message: " + err.Error())
+		}
+	}
+	if user.NotifyWebhook != "" {
+		if err := sendWebhook(user.NotifyWebhook, message); err != nil {
+			common.SysError("failed to send notify webhook: " + err.Error())
+		}
+	}
+}
+
+func sendWebhook(url string, message *Message) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 已发送的提醒，key 为 用户:令牌:类型，value 为周期，避免每次请求都写数据库
+var sentAlerts sync.Map
+
+// CheckQuotaAlerts 扣费后检查用户余额、令牌剩余额度及周期预算是否低于提醒阈值
+// 余额类的提醒每天最多一次，周期预算的提醒每个周期最多一次
+func CheckQuotaAlerts(userId int, tokenId int) {
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.SysError("failed to get user for quota alert: " + err.Error())
+		return
+	}
+	today := time.Now().Format("2006-01-02")
+
+	threshold := common.QuotaRemindThreshold
+	if user.AlertQuota != nil && *user.AlertQuota != 0 {
+		threshold = *user.AlertQuota
+	}
+	if user.Quota <= 0 {
+		sendQuotaAlert(user, today, &Message{
+			Type:    model.QuotaAlertUserExhausted,
+			Title:   "您的额度已用尽",
+			Content: "您的额度已用尽，为了不影响您的使用，请及时充值。",
+			Quota:   user.Quota,
+		})
+	} else if threshold > 0 && user.Quota < threshold {
+		sendQuotaAlert(user, today, &Message{
+			Type:      model.QuotaAlertUserQuotaLow,
+			Title:     "您的额度即将用尽",
+			Content:   fmt.Sprintf("您的额度即将用尽，当前剩余 %s，为了不影响您的使用，请及时充值。", common.LogQuota(user.Quota)),
+			Quota:     user.Quota,
+			Threshold: threshold,
+		})
+	}
+
+	if tokenId == 0 {
+		return
+	}
+	token, err := model.GetTokenById(tokenId)
+	if err != nil {
+		common.SysError("failed to get token for quota alert: " + err.Error())
+		return
+	}
+	if token.AlertQuota > 0 && !token.UnlimitedQuota && token.RemainQuota < token.AlertQuota {
+		sendQuotaAlert(user, today, &Message{
+			Type:      model.QuotaAlertTokenQuotaLow,
+			TokenId:   token.Id,
+			TokenName: token.Name,
+			Title:     fmt.Sprintf("令牌 %s 的额度即将用尽", token.Name),
+			Content:   fmt.Sprintf("令牌 %s 当前剩余 %s。", token.Name, common.LogQuota(token.RemainQuota)),
+			Quota:     token.RemainQuota,
+			Threshold: token.AlertQuota,
+		})
+	}
+	if token.AlertPercent > 0 && token.HasBudget() {
+		usedQuota := token.GetBudgetUsedQuota()
+		if usedQuota*100 >= token.BudgetLimit*token.AlertPercent {
+			sendQuotaAlert(user, strconv.FormatInt(token.BudgetResetTime, 10), &Message{
+				Type:      model.QuotaAlertTokenBudget,
+				TokenId:   token.Id,
+				TokenName: token.Name,
+				Title:     fmt.Sprintf("令牌 %s 的周期预算已使用 %d%%", token.Name, usedQuota*100/token.BudgetLimit),
+				Content: fmt.Sprintf("令牌 %s 本周期已使用 %s，预算为 %s，将于 %s 重置。", token.Name,
+					common.LogQuota(usedQuota), common.LogQuota(token.BudgetLimit), time.Unix(token.BudgetResetTime, 0).Format("2006-01-02 15:04:05")),
+				Quota:     usedQuota,
+				Threshold: token.BudgetLimit * token.AlertPercent / 100,
+			})
+		}
+	}
+}
+
+func sendQuotaAlert(user *model.User, period string, message *Message) {
+	key := fmt.Sprintf("%d:%d:%s", user.Id, message.TokenId, message.Type)
+	if sentPeriod, ok := sentAlerts.Load(key); ok && sentPeriod == period {
+		return
+	}
+
+	created, err := model.RecordQuotaAlert(user.Id, message.TokenId, message.Type, period)
+	if err != nil {
+		common.SysError("failed to record quota alert: " + err.Error())
+		return
+	}
+	sentAlerts.Store(key, period)
+	if created {
+		Send(user, message)
+	}
+}
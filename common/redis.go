@@ -3,6 +3,7 @@ package common
 import (
 	"context"
 	"github.com/go-redis/redis/v8"
+	"one-api/common/metrics"
 	"os"
 	"time"
 )
@@ -28,6 +29,7 @@ func InitRedisClient() (err error) {
 		FatalLog("failed to parse Redis connection string: " + err.Error())
 	}
 	RDB = redis.NewClient(opt)
+	RDB.AddHook(metrics.RedisHook{})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
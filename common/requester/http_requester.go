@@ -9,11 +9,13 @@ import (
 	"io"
 	"net/http"
 	"one-api/common"
+	"one-api/common/tracing"
 	"one-api/types"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 type HttpErrorHandler func(*http.Response) *types.OpenAIError
@@ -23,6 +25,7 @@ type HTTPRequester struct {
 	CreateFormBuilder func(io.Writer) FormBuilder
 	ErrorHandler      HttpErrorHandler
 	proxyAddr         string
+	Context           context.Context
 }
 
 // NewHTTPRequester 创建一个新的 HTTPRequester 实例。
@@ -47,18 +50,28 @@ type requestOptions struct {
 
 type requestOption func(*requestOptions)
 
+// 设置请求的上下文，客户端断开连接时会同时取消上游请求
+func (r *HTTPRequester) SetContext(ctx context.Context) {
+	r.Context = ctx
+}
+
 func (r *HTTPRequester) getContext() context.Context {
+	ctx := r.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	if r.proxyAddr == "" {
-		return context.Background()
+		return ctx
 	}
 
 	// 如果是以 socks5:// 开头的地址，那么使用 socks5 代理
 	if strings.HasPrefix(r.proxyAddr, "socks5://") {
-		return context.WithValue(context.Background(), ProxySock5AddrKey, r.proxyAddr)
+		return context.WithValue(ctx, ProxySock5AddrKey, r.proxyAddr)
 	}
 
 	// 否则使用 http 代理
-	return context.WithValue(context.Background(), ProxyHTTPAddrKey, r.proxyAddr)
+	return context.WithValue(ctx, ProxyHTTPAddrKey, r.proxyAddr)
 
 }
 
@@ -75,6 +88,7 @@ func (r *HTTPRequester) NewRequest(method, url string, setters ...requestOption)
 	if err != nil {
 		return nil, err
 	}
+	tracing.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
 
 	return req, nil
 }
@@ -144,6 +158,7 @@ func RequestStream[T streamable](requester *HTTPRequester, resp *http.Response,
 
 		DataChan: make(chan T),
 		ErrChan:  make(chan error),
+		exited:   make(chan struct{}),
 	}
 
 	return stream, nil
@@ -28,9 +28,13 @@ type streamReader[T streamable] struct {
 
 	DataChan chan T
 	ErrChan  chan error
+
+	started bool
+	exited  chan struct{}
 }
 
 func (stream *streamReader[T]) Recv() (<-chan T, <-chan error) {
+	stream.started = true
 	go stream.processLines()
 
 	return stream.DataChan, stream.ErrChan
@@ -38,6 +42,7 @@ func (stream *streamReader[T]) Recv() (<-chan T, <-chan error) {
 
 //nolint:gocognit
 func (stream *streamReader[T]) processLines() {
+	defer close(stream.exited)
 	for {
 		rawLine, readErr := stream.reader.ReadBytes('\n')
 		if readErr != nil {
@@ -63,4 +68,19 @@ func (stream *streamReader[T]) processLines() {
 
 func (stream *streamReader[T]) Close() {
 	stream.response.Body.Close()
+	if stream.started {
+		go drainStream(stream.DataChan, stream.ErrChan, stream.exited)
+	}
+}
+
+// 客户端提前断开时不再读取数据，需要继续消费通道，避免读取协程阻塞无法退出
+func drainStream[T streamable](dataChan chan T, errChan chan error, exited chan struct{}) {
+	for {
+		select {
+		case <-dataChan:
+		case <-errChan:
+		case <-exited:
+			return
+		}
+	}
 }
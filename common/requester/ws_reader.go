@@ -12,14 +12,19 @@ type wsReader[T streamable] struct {
 
 	DataChan chan T
 	ErrChan  chan error
+
+	started bool
+	exited  chan struct{}
 }
 
 func (stream *wsReader[T]) Recv() (<-chan T, <-chan error) {
+	stream.started = true
 	go stream.processLines()
 	return stream.DataChan, stream.ErrChan
 }
 
 func (stream *wsReader[T]) processLines() {
+	defer close(stream.exited)
 	for {
 		_, msg, err := stream.reader.ReadMessage()
 		if err != nil {
@@ -41,4 +46,7 @@ func (stream *wsReader[T]) processLines() {
 
 func (stream *wsReader[T]) Close() {
 	stream.reader.Close()
+	if stream.started {
+		go drainStream(stream.DataChan, stream.ErrChan, stream.exited)
+	}
 }
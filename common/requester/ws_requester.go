@@ -1,16 +1,20 @@
 package requester
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"one-api/common"
+	"one-api/common/tracing"
 	"one-api/types"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 type WSRequester struct {
 	WSClient *websocket.Dialer
+	Context  context.Context
 }
 
 func NewWSRequester(proxyAddr string) *WSRequester {
@@ -19,8 +23,23 @@ func NewWSRequester(proxyAddr string) *WSRequester {
 	}
 }
 
+// 设置请求的上下文，客户端断开连接时会同时关闭上游连接
+func (w *WSRequester) SetContext(ctx context.Context) {
+	w.Context = ctx
+}
+
 func (w *WSRequester) NewRequest(url string, header http.Header) (*websocket.Conn, error) {
-	conn, resp, err := w.WSClient.Dial(url, header)
+	ctx := w.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if header == nil {
+		header = make(http.Header)
+	}
+	tracing.Inject(ctx, propagation.HeaderCarrier(header))
+
+	conn, resp, err := w.WSClient.DialContext(ctx, url, header)
 	if err != nil {
 		return nil, err
 	}
@@ -29,6 +48,14 @@ func (w *WSRequester) NewRequest(url string, header http.Header) (*websocket.Con
 		return nil, errors.New("ws unexpected status code")
 	}
 
+	if ctx.Done() != nil {
+		// 请求上下文在请求结束或客户端断开时都会被取消，此时关闭连接使读取协程退出
+		go func() {
+			<-ctx.Done()
+			conn.Close()
+		}()
+	}
+
 	return conn, nil
 }
 
@@ -44,6 +71,7 @@ func SendWSJsonRequest[T streamable](conn *websocket.Conn, data any, handlerPref
 
 		DataChan: make(chan T),
 		ErrChan:  make(chan error),
+		exited:   make(chan struct{}),
 	}
 
 	return stream, nil
@@ -0,0 +1,304 @@
+package common
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 并发数限制，超出上限的请求按先后顺序排队等待，开启 Redis 时多节点共享
+var (
+	ErrSemaphoreQueueFull = errors.New("semaphore queue is full")
+	ErrSemaphoreTimeout   = errors.New("semaphore wait timeout")
+)
+
+const (
+	semaphoreKeysKey      = "concurrency:keys"
+	semaphoreLease        = time.Minute // 持有者需要定期续期，节点异常退出时租约到期后自动释放
+	semaphorePollInterval = 100 * time.Millisecond
+)
+
+// KEYS[1] 持有者，score 为租约到期时间；KEYS[2] 等待队列，score 为入队时间
+// ARGV: member, limit, now, lease 到期时间, 过期的入队时间
+var semaphoreAcquireScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', ARGV[5])
+local free = tonumber(ARGV[2]) - redis.call('ZCARD', KEYS[1])
+if free <= 0 then
+	return 0
+end
+local rank = redis.call('ZRANK', KEYS[2], ARGV[1])
+if rank == false then
+	if redis.call('ZCARD', KEYS[2]) >= free then
+		return 0
+	end
+elseif rank >= free then
+	return 0
+else
+	redis.call('ZREM', KEYS[2], ARGV[1])
+end
+redis.call('ZADD', KEYS[1], ARGV[4], ARGV[1])
+redis.call('PEXPIRE', KEYS[1], 2 * (ARGV[4] - ARGV[3]))
+return 1
+`)
+
+// KEYS[1] 等待队列；ARGV: member, now, 队列长度上限, 过期的入队时间, key 的过期时间
+var semaphoreEnqueueScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[4])
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
+`)
+
+type SemaphoreStat struct {
+	Key      string `json:"key"`
+	InFlight int64  `json:"in_flight"`
+	Waiting  int64  `json:"waiting"`
+}
+
+// AcquireSemaphore 获取 key 的一个并发名额，已满时最多排队 timeout，队列长度超过 queueSize 时直接返回 ErrSemaphoreQueueFull
+// 返回的 release 必须在请求结束时调用
+func AcquireSemaphore(ctx context.Context, key string, limit int, queueSize int, timeout time.Duration) (release func(), err error) {
+	if RedisEnabled {
+		return redisSemaphoreAcquire(ctx, key, limit, queueSize, timeout)
+	}
+	return memorySemaphores.acquire(ctx, key, limit, queueSize, timeout)
+}
+
+// GetSemaphoreStats 当前各 key 的并发数及排队数
+func GetSemaphoreStats() ([]*SemaphoreStat, error) {
+	var stats []*SemaphoreStat
+	var err error
+	if RedisEnabled {
+		stats, err = redisSemaphoreStats()
+	} else {
+		stats = memorySemaphores.stats()
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Key < stats[j].Key
+	})
+	return stats, err
+}
+
+func redisSemaphoreAcquire(ctx context.Context, key string, limit int, queueSize int, timeout time.Duration) (func(), error) {
+	holdersKey, queueKey := key+":holders", key+":queue"
+	member := GetUUID()
+	deadline := time.Now().Add(timeout)
+
+	tryAcquire := func() (bool, error) {
+		now := time.Now()
+		return semaphoreAcquireScript.Run(context.Background(), RDB, []string{holdersKey, queueKey},
+			member, limit, now.UnixMilli(), now.Add(semaphoreLease).UnixMilli(), now.Add(-timeout-semaphoreLease).UnixMilli()).Bool()
+	}
+
+	RDB.SAdd(context.Background(), semaphoreKeysKey, key)
+	ok, err := tryAcquire()
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		if queueSize <= 0 || timeout <= 0 {
+			return nil, ErrSemaphoreQueueFull
+		}
+		now := time.Now()
+		queued, err := semaphoreEnqueueScript.Run(context.Background(), RDB, []string{queueKey},
+			member, now.UnixMilli(), queueSize, now.Add(-timeout-semaphoreLease).UnixMilli(), (timeout + semaphoreLease).Milliseconds()).Bool()
+		if err != nil {
+			return nil, err
+		}
+		if !queued {
+			return nil, ErrSemaphoreQueueFull
+		}
+
+		ticker := time.NewTicker(semaphorePollInterval)
+		defer ticker.Stop()
+		for !ok {
+			select {
+			case <-ctx.Done():
+				RDB.ZRem(context.Background(), queueKey, member)
+				return nil, ctx.Err()
+			case <-ticker.C:
+			}
+			if time.Now().After(deadline) {
+				RDB.ZRem(context.Background(), queueKey, member)
+				return nil, ErrSemaphoreTimeout
+			}
+			ok, err = tryAcquire()
+			if err != nil {
+				RDB.ZRem(context.Background(), queueKey, member)
+				return nil, err
+			}
+		}
+	}
+
+	// 长时间的流式请求需要续期
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(semaphoreLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				RDB.ZAddXX(context.Background(), holdersKey, &redis.Z{
+					Score:  float64(time.Now().Add(semaphoreLease).UnixMilli()),
+					Member: member,
+				})
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			RDB.ZRem(context.Background(), holdersKey, member)
+		})
+	}, nil
+}
+
+func redisSemaphoreStats() ([]*SemaphoreStat, error) {
+	ctx := context.Background()
+	keys, err := RDB.SMembers(ctx, semaphoreKeysKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixMilli()
+	stats := make([]*SemaphoreStat, 0, len(keys))
+	for _, key := range keys {
+		inFlight, err := RDB.ZCount(ctx, key+":holders", "("+strconv.FormatInt(now, 10), "+inf").Result()
+		if err != nil {
+			return nil, err
+		}
+		waiting, err := RDB.ZCard(ctx, key+":queue").Result()
+		if err != nil {
+			return nil, err
+		}
+		if inFlight == 0 && waiting == 0 {
+			RDB.SRem(ctx, semaphoreKeysKey, key)
+			continue
+		}
+		stats = append(stats, &SemaphoreStat{Key: key, InFlight: inFlight, Waiting: waiting})
+	}
+	return stats, nil
+}
+
+type memorySemaphore struct {
+	holders int
+	queue   *list.List // 等待者的 chan，获得名额时关闭
+}
+
+type memorySemaphoreStore struct {
+	sync.Mutex
+	items map[string]*memorySemaphore
+}
+
+var memorySemaphores = &memorySemaphoreStore{
+	items: make(map[string]*memorySemaphore),
+}
+
+func (s *memorySemaphoreStore) acquire(ctx context.Context, key string, limit int, queueSize int, timeout time.Duration) (func(), error) {
+	s.Lock()
+	semaphore, ok := s.items[key]
+	if !ok {
+		semaphore = &memorySemaphore{queue: list.New()}
+		s.items[key] = semaphore
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			s.release(key)
+		})
+	}
+
+	if semaphore.holders < limit && semaphore.queue.Len() == 0 {
+		semaphore.holders++
+		s.Unlock()
+		return release, nil
+	}
+	if queueSize <= 0 || timeout <= 0 || semaphore.queue.Len() >= queueSize {
+		s.Unlock()
+		return nil, ErrSemaphoreQueueFull
+	}
+
+	waiter := make(chan struct{})
+	element := semaphore.queue.PushBack(waiter)
+	s.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-waiter:
+		return release, nil
+	case <-timer.C:
+		err = ErrSemaphoreTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.Lock()
+	select {
+	case <-waiter:
+		// 超时的同时获得了名额，归还给下一个等待者
+		s.Unlock()
+		release()
+		return nil, err
+	default:
+	}
+	semaphore.queue.Remove(element)
+	s.cleanup(key, semaphore)
+	s.Unlock()
+	return nil, err
+}
+
+// 名额直接转交给队首的等待者
+func (s *memorySemaphoreStore) release(key string) {
+	s.Lock()
+	defer s.Unlock()
+	semaphore, ok := s.items[key]
+	if !ok {
+		return
+	}
+	if front := semaphore.queue.Front(); front != nil {
+		semaphore.queue.Remove(front)
+		close(front.Value.(chan struct{}))
+		return
+	}
+	semaphore.holders--
+	s.cleanup(key, semaphore)
+}
+
+func (s *memorySemaphoreStore) cleanup(key string, semaphore *memorySemaphore) {
+	if semaphore.holders <= 0 && semaphore.queue.Len() == 0 {
+		delete(s.items, key)
+	}
+}
+
+func (s *memorySemaphoreStore) stats() []*SemaphoreStat {
+	s.Lock()
+	defer s.Unlock()
+	stats := make([]*SemaphoreStat, 0, len(s.items))
+	for key, semaphore := range s.items {
+		stats = append(stats, &SemaphoreStat{
+			Key:      key,
+			InFlight: int64(semaphore.holders),
+			Waiting:  int64(semaphore.queue.Len()),
+		})
+	}
+	return stats
+}
@@ -120,6 +120,18 @@ func StopTelegramBot() {
 	}
 }
 
+// SendMessage 主动向绑定了 Telegram 的用户发送消息
+func SendMessage(chatId int64, text string) error {
+	if !TGEnabled || TGBot == nil {
+		return errors.New("telegram bot is not enabled")
+	}
+
+	_, err := TGBot.SendMessage(chatId, text, &gotgbot.SendMessageOpts{
+		ParseMode: "html",
+	})
+	return err
+}
+
 func setDispatcher() *ext.Dispatcher {
 	menus := getMenu()
 	TGBot.SetMyCommands(menus, nil)
@@ -0,0 +1,40 @@
+package test
+
+import (
+	"one-api/common"
+	"one-api/model"
+	"path/filepath"
+	"testing"
+)
+
+// InitTestDB 使用临时目录下的 SQLite 数据库，测试结束后关闭
+func InitTestDB(t *testing.T) {
+	t.Helper()
+	common.RedisEnabled = false
+	common.MemoryCacheEnabled = false
+	common.SQLitePath = filepath.Join(t.TempDir(), "one-api.db")
+	if err := model.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		model.CloseDB()
+	})
+}
+
+func CreateTestUser(t *testing.T, username string, quota int) *model.User {
+	t.Helper()
+	user := &model.User{
+		Username:    username,
+		Password:    "12345678",
+		DisplayName: username,
+		Quota:       quota,
+		Group:       "default",
+		AccessToken: common.GetUUID(),
+		AffCode:     username,
+		CreatedTime: common.GetTimestamp(),
+	}
+	if err := model.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"one-api/common"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "one-api"
+
+var tracer = otel.Tracer(tracerName)
+
+var provider *sdktrace.TracerProvider
+
+// InitTracer 设置 TRACING_ENABLED=true 时开启链路追踪
+// 配置了 OTEL_EXPORTER_OTLP_ENDPOINT 时通过 OTLP/HTTP 上报，否则写入 TRACING_FILE（默认为日志目录下的 traces.log）
+func InitTracer() {
+	if os.Getenv("TRACING_ENABLED") != "true" {
+		return
+	}
+
+	exporter, err := newExporter()
+	if err != nil {
+		common.FatalLog("failed to initialize tracing exporter: " + err.Error())
+	}
+
+	SetupTracer(exporter)
+}
+
+// SetupTracer 使用指定的 exporter 开启链路追踪，也可用于测试时传入内存 exporter
+func SetupTracer(exporter sdktrace.SpanExporter) {
+	res := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(tracerName),
+		semconv.ServiceVersionKey.String(common.Version),
+	)
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+func newExporter() (sdktrace.SpanExporter, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		common.SysLog("tracing enabled, exporting to OTLP collector")
+		// endpoint、headers 等配置由 otlptracehttp 从 OTEL_EXPORTER_OTLP_* 环境变量读取
+		return otlptracehttp.New(context.Background())
+	}
+
+	var writer io.Writer = os.Stdout
+	tracingFile := os.Getenv("TRACING_FILE")
+	if tracingFile == "" && *common.LogDir != "" {
+		tracingFile = filepath.Join(*common.LogDir, "traces.log")
+	}
+	if tracingFile != "" {
+		file, err := os.OpenFile(tracingFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		writer = file
+	}
+	common.SysLog(fmt.Sprintf("tracing enabled, no OTLP collector configured, writing spans to %s", tracingFile))
+	return stdouttrace.New(stdouttrace.WithWriter(writer))
+}
+
+// Shutdown 退出前上报缓存中的 span
+func Shutdown() {
+	if provider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := provider.Shutdown(ctx); err != nil {
+		common.SysError("failed to shutdown tracer provider: " + err.Error())
+	}
+}
+
+// Start 创建子 span，未开启链路追踪时返回的是空操作 span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartServer 从请求头中提取上游传入的 traceparent，创建请求的根 span
+func StartServer(ctx context.Context, header propagation.HeaderCarrier, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, header)
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+}
+
+// Inject 将当前 span 写入请求头，传递给上游
+func Inject(ctx context.Context, header propagation.HeaderCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, header)
+}
+
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
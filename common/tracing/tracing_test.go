@@ -0,0 +1,45 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	_ "one-api/common/test/init"
+	"one-api/common/tracing"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracePropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.SetupTracer(exporter)
+	defer tracing.Shutdown()
+
+	incoming := http.Header{}
+	incoming.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, root := tracing.StartServer(context.Background(), propagation.HeaderCarrier(incoming), "POST /v1/chat/completions")
+	ctx, attempt := tracing.Start(ctx, "relay.attempt")
+
+	upstream := http.Header{}
+	tracing.Inject(ctx, propagation.HeaderCarrier(upstream))
+
+	attempt.End()
+	root.End()
+	// 内存 exporter 在 Shutdown 时会清空，这里只 flush
+	otel.GetTracerProvider().(*sdktrace.TracerProvider).ForceFlush(context.Background())
+
+	traceparent := upstream.Get("traceparent")
+	assert.Contains(t, traceparent, "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Contains(t, traceparent, attempt.SpanContext().SpanID().String())
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+	for _, span := range spans {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+	}
+}
@@ -207,3 +207,14 @@ func String2Int(str string) int {
 	}
 	return num
 }
+
+// SplitAndTrim 按 sep 分割并去除每项首尾的空白，忽略空项
+func SplitAndTrim(str string, sep string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(str, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
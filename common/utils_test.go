@@ -0,0 +1,16 @@
+package common_test
+
+import (
+	"one-api/common"
+	_ "one-api/common/test/init"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitAndTrim(t *testing.T) {
+	assert.Equal(t, []string{"default", "vip"}, common.SplitAndTrim("default, vip", ","))
+	assert.Equal(t, []string{"default", "vip"}, common.SplitAndTrim(" default ,, vip ,", ","))
+	assert.Empty(t, common.SplitAndTrim("", ","))
+	assert.Empty(t, common.SplitAndTrim(" , ", ","))
+}
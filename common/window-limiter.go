@@ -0,0 +1,123 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 固定窗口计数，用于 RPM/TPM 等按分钟统计的限流，开启 Redis 时多节点共享计数
+const windowLimiterMaxItems = 100000
+
+// 当前计数加上 amount 不超过 limit 时才累加，返回累加后（或被拒绝时）的计数
+var windowLimiterScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local amount = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+if limit > 0 and current + amount > limit then
+	return {current, 0}
+end
+current = redis.call('INCRBY', KEYS[1], amount)
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return {current, 1}
+`)
+
+type windowCounter struct {
+	count     int64
+	expiredAt time.Time
+}
+
+type windowLimiterStore struct {
+	sync.Mutex
+	items map[string]*windowCounter
+}
+
+var windowLimiterMemory = &windowLimiterStore{
+	items: make(map[string]*windowCounter),
+}
+
+// WindowKey 在 key 后追加当前窗口的序号，并返回距离窗口结束的时间
+func WindowKey(key string, window time.Duration) (string, time.Duration) {
+	now := time.Now()
+	index := now.UnixNano() / int64(window)
+	reset := time.Unix(0, (index+1)*int64(window)).Sub(now)
+	return fmt.Sprintf("%s:%d", key, index), reset
+}
+
+// WindowLimiterAllow 尝试在窗口计数上累加 amount，超过 limit 时不累加并返回 false，limit 为 0 时不限制
+func WindowLimiterAllow(key string, amount int64, limit int64, window time.Duration) (int64, bool, error) {
+	if RedisEnabled {
+		result, err := windowLimiterScript.Run(context.Background(), RDB, []string{key}, amount, limit, window.Milliseconds()).Int64Slice()
+		if err != nil {
+			return 0, false, err
+		}
+		return result[0], result[1] == 1, nil
+	}
+
+	current, ok := windowLimiterMemory.allow(key, amount, limit, window)
+	return current, ok, nil
+}
+
+// WindowLimiterAdd 直接调整窗口计数，用于请求结束后按实际用量修正或回滚
+func WindowLimiterAdd(key string, amount int64, window time.Duration) error {
+	_, _, err := WindowLimiterAllow(key, amount, 0, window)
+	return err
+}
+
+// WindowLimiterGet 读取窗口计数，不存在或已过期时为 0
+func WindowLimiterGet(key string) (int64, error) {
+	if RedisEnabled {
+		current, err := RDB.Get(context.Background(), key).Int64()
+		if err == redis.Nil {
+			return 0, nil
+		}
+		return current, err
+	}
+
+	return windowLimiterMemory.get(key), nil
+}
+
+func (s *windowLimiterStore) get(key string) int64 {
+	s.Lock()
+	defer s.Unlock()
+
+	counter, exists := s.items[key]
+	if !exists || time.Now().After(counter.expiredAt) {
+		return 0
+	}
+	return counter.count
+}
+
+func (s *windowLimiterStore) allow(key string, amount int64, limit int64, window time.Duration) (current int64, ok bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	counter, exists := s.items[key]
+	if !exists || now.After(counter.expiredAt) {
+		if len(s.items) >= windowLimiterMaxItems {
+			s.evict(now)
+		}
+		counter = &windowCounter{expiredAt: now.Add(window)}
+		s.items[key] = counter
+	}
+
+	if limit > 0 && counter.count+amount > limit {
+		return counter.count, false
+	}
+	counter.count += amount
+	return counter.count, true
+}
+
+func (s *windowLimiterStore) evict(now time.Time) {
+	for key, counter := range s.items {
+		if now.After(counter.expiredAt) {
+			delete(s.items, key)
+		}
+	}
+}
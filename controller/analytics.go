@@ -2,6 +2,7 @@ package controller
 
 import (
 	"net/http"
+	"one-api/common"
 	"one-api/model"
 	"strconv"
 
@@ -115,3 +116,18 @@ func GetRedemptionStatisticsByPeriod(c *gin.Context) {
 		"data":    logStatistics,
 	})
 }
+
+// GetConcurrencyStatistics 当前各令牌、用户及分组处理中和排队中的请求数
+func GetConcurrencyStatistics(c *gin.Context) {
+	stats, err := common.GetSemaphoreStats()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    stats,
+	})
+}
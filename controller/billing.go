@@ -26,7 +26,11 @@ func GetSubscription(c *gin.Context) {
 		})
 		return
 	}
-	if common.DisplayTokenStatEnabled && !token.UnlimitedQuota {
+	if token.HasBudget() {
+		expiredTime = token.ExpiredTime
+		usedQuota = token.GetBudgetUsedQuota()
+		remainQuota = token.BudgetLimit - usedQuota
+	} else if common.DisplayTokenStatEnabled && !token.UnlimitedQuota {
 		expiredTime = token.ExpiredTime
 		remainQuota = token.RemainQuota
 		usedQuota = token.UsedQuota
@@ -63,7 +67,7 @@ func GetSubscription(c *gin.Context) {
 	if common.DisplayInCurrencyEnabled {
 		amount /= common.QuotaPerUnit
 	}
-	if token != nil && token.UnlimitedQuota {
+	if token != nil && token.UnlimitedQuota && !token.HasBudget() {
 		amount = 100000000
 	}
 	subscription := OpenAISubscriptionResponse{
@@ -74,6 +78,10 @@ func GetSubscription(c *gin.Context) {
 		SystemHardLimitUSD: amount,
 		AccessUntil:        expiredTime,
 	}
+	if token.HasBudget() {
+		subscription.BudgetPeriod = token.BudgetPeriod
+		subscription.BudgetResetAt = token.BudgetResetTime
+	}
 	c.JSON(200, subscription)
 }
 
@@ -93,7 +101,9 @@ func GetUsage(c *gin.Context) {
 		})
 		return
 	}
-	if common.DisplayTokenStatEnabled && !token.UnlimitedQuota {
+	if token.HasBudget() {
+		quota = token.GetBudgetUsedQuota()
+	} else if common.DisplayTokenStatEnabled && !token.UnlimitedQuota {
 		quota = token.UsedQuota
 	} else {
 		userId := c.GetInt("id")
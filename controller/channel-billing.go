@@ -23,6 +23,8 @@ type OpenAISubscriptionResponse struct {
 	HardLimitUSD       float64 `json:"hard_limit_usd"`
 	SystemHardLimitUSD float64 `json:"system_hard_limit_usd"`
 	AccessUntil        int64   `json:"access_until"`
+	BudgetPeriod       string  `json:"budget_period,omitempty"`   // 令牌设置了周期预算时，额度为当前周期的预算
+	BudgetResetAt      int64   `json:"budget_reset_at,omitempty"` // 预算下次重置时间
 }
 
 type OpenAIUsageDailyCost struct {
@@ -47,6 +47,18 @@ func GetChannel(c *gin.Context) {
 		})
 		return
 	}
+	if channel.IsMultiKey() {
+		channel.Keys, err = model.GetChannelKeys(channel.Id)
+		if err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+		keys := make([]string, 0, len(channel.Keys))
+		for _, channelKey := range channel.Keys {
+			keys = append(keys, channelKey.Key)
+		}
+		channel.Key = strings.Join(keys, "\n")
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -65,6 +77,10 @@ func AddChannel(c *gin.Context) {
 		return
 	}
 	channel.CreatedTime = common.GetTimestamp()
+	if channel.KeyRotation != "" {
+		addMultiKeyChannel(c, &channel)
+		return
+	}
 	keys := strings.Split(channel.Key, "\n")
 	channels := make([]model.Channel, 0, len(keys))
 	for _, key := range keys {
@@ -89,6 +105,43 @@ func AddChannel(c *gin.Context) {
 	})
 }
 
+// 多 key 渠道只创建一个渠道，key 列表保存在 channel_keys 中
+func addMultiKeyChannel(c *gin.Context, channel *model.Channel) {
+	keys, err := prepareChannelKeys(channel)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	err = channel.Insert()
+	if err == nil {
+		err = model.SyncChannelKeys(channel.Id, keys)
+	}
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// 校验轮换方式并拆分 key 列表，渠道本身只保存第一个 key
+func prepareChannelKeys(channel *model.Channel) ([]string, error) {
+	if channel.KeyRotation != model.KeyRotationRoundRobin && channel.KeyRotation != model.KeyRotationRandom {
+		return nil, errors.New("无效的 key 轮换方式")
+	}
+
+	keys := model.SplitChannelKeys(channel.Key)
+	if len(keys) == 0 {
+		return nil, errors.New("key 不能为空")
+	}
+	channel.Key = keys[0]
+
+	return keys, nil
+}
+
 func DeleteChannel(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	channel := model.Channel{Id: id}
@@ -132,7 +185,23 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	if channel.KeyRotation == "" {
+		if oldChannel, err := model.GetChannelById(channel.Id, false); err == nil {
+			channel.KeyRotation = oldChannel.KeyRotation
+		}
+	}
+	var keys []string
+	if channel.IsMultiKey() && channel.Key != "" {
+		keys, err = prepareChannelKeys(&channel)
+		if err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+	}
 	err = channel.Update()
+	if err == nil && keys != nil {
+		err = model.SyncChannelKeys(channel.Id, keys)
+	}
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -147,6 +216,51 @@ func UpdateChannel(c *gin.Context) {
 	})
 }
 
+type ChannelKeyStatusRequest struct {
+	Status int `json:"status" binding:"required"`
+}
+
+// UpdateChannelKeyStatus 手动启用或禁用多 key 渠道中的单个 key
+func UpdateChannelKeyStatus(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var request ChannelKeyStatusRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var reason string
+	if request.Status != common.ChannelStatusEnabled {
+		request.Status = common.ChannelStatusManuallyDisabled
+		reason = "手动禁用"
+	}
+
+	channelKey, err := model.UpdateChannelKeyStatus(id, request.Status, reason)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	// 因 key 全部失效而被自动禁用的渠道，重新启用 key 后恢复渠道
+	if request.Status == common.ChannelStatusEnabled {
+		channel, err := model.GetChannelById(channelKey.ChannelId, false)
+		if err == nil && channel.Status == common.ChannelStatusAutoDisabled {
+			model.UpdateChannelStatusById(channel.Id, common.ChannelStatusEnabled)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    channelKey,
+	})
+}
+
 func BatchUpdateChannelsAzureApi(c *gin.Context) {
 	var params model.BatchChannelsParams
 	err := c.ShouldBindJSON(&params)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"one-api/common"
+	"one-api/common/metrics"
 	"one-api/model"
 	"one-api/types"
 
@@ -36,15 +37,31 @@ func ShouldDisableChannel(err *types.OpenAIError, statusCode int) bool {
 		return true
 	}
 
-	if err.Type == "insufficient_quota" || err.Code == "invalid_api_key" || err.Code == "account_deactivated" {
+	if err.Type == "insufficient_quota" || err.Code == "insufficient_quota" || err.Code == "invalid_api_key" || err.Code == "account_deactivated" {
 		return true
 	}
 	return false
 }
 
+// 禁用渠道中的单个 key，全部 key 均被禁用时禁用渠道
+func DisableChannelKey(channel *model.Channel, reason string) {
+	remaining, err := model.DisableChannelKey(channel.Id, channel.KeyId, reason)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to disable key #%d of channel #%d: %s", channel.KeyId, channel.Id, err.Error()))
+		return
+	}
+	metrics.RecordChannelDisable(channel.Id, "key")
+	common.SysLog(fmt.Sprintf("通道「%s」（#%d）的 key #%d 已被禁用，剩余可用 key %d 个，原因：%s", channel.Name, channel.Id, channel.KeyId, remaining, reason))
+
+	if remaining == 0 {
+		DisableChannel(channel.Id, channel.Name, "所有 key 均已被禁用，最后一个 key 的禁用原因："+reason)
+	}
+}
+
 // disable & notify
 func DisableChannel(channelId int, channelName string, reason string) {
 	model.UpdateChannelStatusById(channelId, common.ChannelStatusAutoDisabled)
+	metrics.RecordChannelDisable(channelId, "channel")
 	subject := fmt.Sprintf("通道「%s」（#%d）已被禁用", channelName, channelId)
 	content := fmt.Sprintf("通道「%s」（#%d）已被禁用，原因：%s", channelName, channelId, reason)
 	notifyRootUser(subject, content)
@@ -77,8 +77,12 @@ func ListModels(c *gin.Context) {
 	}
 	sort.Strings(models)
 
+	tokenModels := c.GetStringSlice("token_models")
 	groupOpenAIModels := make([]OpenAIModels, 0, len(models))
 	for _, modelId := range models {
+		if !model.IsModelAllowed(tokenModels, modelId) {
+			continue
+		}
 		groupOpenAIModels = append(groupOpenAIModels, OpenAIModels{
 			Id:         modelId,
 			Object:     "model",
@@ -140,7 +144,7 @@ func ListModelsForAdmin(c *gin.Context) {
 func RetrieveModel(c *gin.Context) {
 	modelId := c.Param("model")
 	ownedByName := getModelOwnedBy(modelId)
-	if *ownedByName != unknownOwnedBy {
+	if *ownedByName != unknownOwnedBy && model.IsModelAllowed(c.GetStringSlice("token_models"), modelId) {
 		c.JSON(200, OpenAIModels{
 			Id:         modelId,
 			Object:     "model",
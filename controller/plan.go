@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/payment"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func GetPlansList(c *gin.Context) {
+	var params model.GenericParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	plans, err := model.GetPlansList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plans,
+	})
+}
+
+func GetPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	plan, err := model.GetPlanById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
+
+func AddPlan(c *gin.Context) {
+	plan := model.Plan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := plan.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	plan.Id = 0
+	if plan.Status == 0 {
+		plan.Status = common.PlanStatusEnabled
+	}
+	if err := plan.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
+
+func UpdatePlan(c *gin.Context) {
+	plan := model.Plan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	cleanPlan, err := model.GetPlanById(plan.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	// If you add more fields, please also update plan.Update()
+	cleanPlan.Name = plan.Name
+	cleanPlan.Description = plan.Description
+	cleanPlan.Status = plan.Status
+	cleanPlan.Group = plan.Group
+	cleanPlan.Quota = plan.Quota
+	cleanPlan.Price = plan.Price
+	cleanPlan.CycleDays = plan.CycleDays
+	if err := cleanPlan.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := cleanPlan.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanPlan,
+	})
+}
+
+func GetSubscriptionsList(c *gin.Context) {
+	var params model.SubscriptionsListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	subscriptions, err := model.GetSubscriptionsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    subscriptions,
+	})
+}
+
+// GetEnabledPlans 用户可购买的套餐
+func GetEnabledPlans(c *gin.Context) {
+	plans, err := model.GetEnabledPlans()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plans,
+	})
+}
+
+// GetSelfSubscription 当前生效的订阅，没有订阅时 data 为 null
+func GetSelfSubscription(c *gin.Context) {
+	subscription, err := model.GetActiveSubscription(c.GetInt("id"))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err != nil {
+		subscription = nil
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    subscription,
+	})
+}
+
+// GetSubscriptionQuote 购买前查询续费或升级的实付金额
+func GetSubscriptionQuote(c *gin.Context) {
+	planId, err := strconv.Atoi(c.Query("plan_id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的套餐"))
+		return
+	}
+	cycles, err := strconv.Atoi(c.DefaultQuery("cycles", "1"))
+	if err != nil {
+		// 无法解析时按 0 处理，由下面的校验返回错误
+		cycles = 0
+	}
+	if err := validateSubscribeCycles(cycles); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	plan, err := model.GetPlanById(planId)
+	if err != nil || plan.Status != common.PlanStatusEnabled {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("套餐不存在或已下架"))
+		return
+	}
+	quote, err := model.GetSubscriptionQuote(c.GetInt("id"), plan, cycles)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    quote,
+	})
+}
+
+func validateSubscribeCycles(cycles int) error {
+	if cycles < 1 || cycles > model.SubscriptionMaxCycles {
+		return fmt.Errorf("购买的周期数必须在1-%d之间", model.SubscriptionMaxCycles)
+	}
+	return nil
+}
+
+type SubscribeRequest struct {
+	PlanId  int    `json:"plan_id"`
+	Cycles  int    `json:"cycles"`
+	Type    string `json:"type"`
+	Gateway string `json:"gateway"`
+}
+
+// Subscribe 通过充值流程购买、续费或升级套餐，支付成功后开通
+func Subscribe(c *gin.Context) {
+	req := SubscribeRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if req.Gateway == "" {
+		req.Gateway = "epay"
+	}
+	if req.Cycles == 0 {
+		req.Cycles = 1
+	}
+	if err := validateSubscribeCycles(req.Cycles); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	id := c.GetInt("id")
+	result, rechargeLog, err := payment.CreatePlanOrder(req.Gateway, req.Type, req.PlanId, req.Cycles, id, c.ClientIP())
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "",
+		"trade_no": rechargeLog.TradeNo,
+		"money":    rechargeLog.Money,
+		"data":     result,
+	})
+}
@@ -10,7 +10,7 @@ import (
 )
 
 func GetRedemptionsList(c *gin.Context) {
-	var params model.GenericParams
+	var params model.RedemptionsListParams
 	if err := c.ShouldBindQuery(&params); err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetRedemptionCampaignsList(c *gin.Context) {
+	var params model.GenericParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	campaigns, err := model.GetRedemptionCampaignsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    campaigns,
+	})
+}
+
+func GetRedemptionCampaign(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	campaign, err := model.GetRedemptionCampaignById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    campaign,
+	})
+}
+
+// AddRedemptionCampaign 创建活动并生成第一批兑换码
+func AddRedemptionCampaign(c *gin.Context) {
+	campaign := model.RedemptionCampaign{}
+	if err := c.ShouldBindJSON(&campaign); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := campaign.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	campaign.Id = 0
+	campaign.Status = common.RedemptionCodeStatusEnabled
+
+	keys, err := campaign.Insert(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"campaign": campaign,
+			"keys":     keys,
+		},
+	})
+}
+
+func UpdateRedemptionCampaign(c *gin.Context) {
+	campaign := model.RedemptionCampaign{}
+	if err := c.ShouldBindJSON(&campaign); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	cleanCampaign, err := model.GetRedemptionCampaignById(campaign.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	// If you add more fields, please also update campaign.Update()
+	cleanCampaign.Name = campaign.Name
+	cleanCampaign.Status = campaign.Status
+	cleanCampaign.ExpiredTime = campaign.ExpiredTime
+	cleanCampaign.MaxUses = campaign.MaxUses
+	cleanCampaign.MaxUsesPerUser = campaign.MaxUsesPerUser
+	if err := cleanCampaign.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := cleanCampaign.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanCampaign,
+	})
+}
+
+// AddRedemptionCampaignBatch 为活动追加一批兑换码
+func AddRedemptionCampaignBatch(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	var req struct {
+		Count int `json:"count"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	campaign, err := model.GetRedemptionCampaignById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	keys, err := campaign.AddBatch(c.GetInt("id"), req.Count)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    keys,
+	})
+}
+
+// ExportRedemptionCampaign 以 CSV 导出活动下所有的兑换码
+func ExportRedemptionCampaign(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	campaign, err := model.GetRedemptionCampaignById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	redemptions, err := model.GetCampaignRedemptions(campaign.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	expiredTime := ""
+	if campaign.ExpiredTime != -1 {
+		expiredTime = time.Unix(campaign.ExpiredTime, 0).Format("2006-01-02 15:04:05")
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=redemption-campaign-%d.csv", campaign.Id))
+	c.Status(http.StatusOK)
+	// 写入 BOM，避免 Excel 打开时中文乱码
+	c.Writer.WriteString("\xEF\xBB\xBF")
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"id", "key", "name", "quota", "status", "used_count", "max_uses", "expired_time", "created_time"})
+	for _, redemption := range redemptions {
+		writer.Write([]string{
+			strconv.Itoa(redemption.Id),
+			redemption.Key,
+			redemption.Name,
+			strconv.Itoa(redemption.Quota),
+			strconv.Itoa(redemption.Status),
+			strconv.Itoa(redemption.UsedCount),
+			strconv.Itoa(campaign.MaxUses),
+			expiredTime,
+			time.Unix(redemption.CreatedTime, 0).Format("2006-01-02 15:04:05"),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		common.SysError("failed to export redemption campaign: " + err.Error())
+	}
+}
@@ -0,0 +1,482 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/providers"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type relayAssistants struct {
+	c        *gin.Context
+	body     []byte
+	provider providersBase.AssistantsInterface
+	// 路径中 assistant/thread 对应的映射
+	owner *model.AssistantMapping
+}
+
+// RelayAssistants 转发 Assistants/Threads/Runs 请求
+// 创建 assistant 时按模型选择渠道，之后的请求按映射表转发到创建时的渠道
+func RelayAssistants(c *gin.Context) {
+	relay := &relayAssistants{c: c}
+
+	if c.Request.Method == http.MethodGet && c.FullPath() == "/v1/assistants" {
+		relay.listAssistants()
+		return
+	}
+
+	if err := relay.setRequest(); err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if errWithCode := relay.setProvider(); errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	response, errWithCode := relay.send(c.Request.Method, c.Request.URL.RequestURI(), relay.body)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	relay.handleResponse(response)
+	c.Data(http.StatusOK, "application/json", response)
+}
+
+func (r *relayAssistants) setRequest() error {
+	if r.c.Request.Method != http.MethodPost {
+		return nil
+	}
+
+	body, err := io.ReadAll(r.c.Request.Body)
+	if err != nil {
+		return err
+	}
+	r.body = body
+
+	return nil
+}
+
+func (r *relayAssistants) setProvider() *types.OpenAIErrorWithStatusCode {
+	userId := r.c.GetInt("id")
+
+	var channel *model.Channel
+	var err error
+	switch r.c.FullPath() {
+	case "/v1/assistants":
+		request := &types.AssistantRequest{}
+		if err = json.Unmarshal(r.body, request); err != nil {
+			return common.ErrorWrapper(err, "invalid_request_error", http.StatusBadRequest)
+		}
+		if request.Model == "" {
+			return common.StringErrorWrapper("field model is required", "invalid_request_error", http.StatusBadRequest)
+		}
+		if errWithCode := checkTokenModel(r.c, request.Model); errWithCode != nil {
+			return errWithCode
+		}
+		channel, err = r.fetchAssistantChannel(request)
+	case "/v1/threads":
+		var errWithCode *types.OpenAIErrorWithStatusCode
+		channel, errWithCode = r.fetchThreadChannel()
+		if errWithCode != nil {
+			return errWithCode
+		}
+	case "/v1/threads/runs":
+		assistant, errWithCode := r.getRunAssistant()
+		if errWithCode != nil {
+			return errWithCode
+		}
+		channel, err = fetchChannelByKey(assistant.ChannelId, assistant.KeyId)
+	default:
+		object := model.AssistantObjectThread
+		if strings.HasPrefix(r.c.FullPath(), "/v1/assistants") {
+			object = model.AssistantObjectAssistant
+		}
+		id := r.c.Param("id")
+		r.owner, err = model.GetAssistantMapping(id, object, userId)
+		if err != nil {
+			return common.StringErrorWrapper(fmt.Sprintf("No %s found with id '%s'.", object, id), "invalid_request_error", http.StatusNotFound)
+		}
+
+		// 修改 assistant 时可能更换模型
+		if r.c.Request.Method == http.MethodPost && r.c.FullPath() == "/v1/assistants/:id" {
+			request := &types.AssistantRequest{}
+			if err = json.Unmarshal(r.body, request); err != nil {
+				return common.ErrorWrapper(err, "invalid_request_error", http.StatusBadRequest)
+			}
+			if request.Model != "" {
+				if errWithCode := checkTokenModel(r.c, request.Model); errWithCode != nil {
+					return errWithCode
+				}
+			}
+		}
+
+		if r.c.Request.Method == http.MethodPost && r.c.FullPath() == "/v1/threads/:id/runs" {
+			assistant, errWithCode := r.getRunAssistant()
+			if errWithCode != nil {
+				return errWithCode
+			}
+			if assistant.ChannelId != r.owner.ChannelId {
+				return common.StringErrorWrapper("the assistant and the thread belong to different channels", "invalid_request_error", http.StatusBadRequest)
+			}
+		}
+		channel, err = fetchChannelByKey(r.owner.ChannelId, r.owner.KeyId)
+	}
+
+	if err != nil {
+		return common.ErrorWrapper(err, "channel_error", http.StatusServiceUnavailable)
+	}
+	r.c.Set("channel_id", channel.Id)
+
+	provider := providers.GetProvider(channel, r.c)
+	if provider == nil {
+		return common.StringErrorWrapper("channel not found", "channel_error", http.StatusServiceUnavailable)
+	}
+
+	assistantsProvider, ok := provider.(providersBase.AssistantsInterface)
+	if !ok {
+		return common.StringErrorWrapper("channel not implemented", "channel_error", http.StatusServiceUnavailable)
+	}
+	r.provider = assistantsProvider
+
+	return nil
+}
+
+// thread 不带模型，按请求指定的 assistant 或引用的文件所在的渠道创建，都没有时使用用户最近创建的 assistant 所在的渠道
+func (r *relayAssistants) fetchThreadChannel() (*model.Channel, *types.OpenAIErrorWithStatusCode) {
+	userId := r.c.GetInt("id")
+	request := &types.ThreadRequest{}
+	if len(r.body) > 0 {
+		if err := json.Unmarshal(r.body, request); err != nil {
+			return nil, common.ErrorWrapper(err, "invalid_request_error", http.StatusBadRequest)
+		}
+	}
+
+	var channelId, keyId int
+	if request.AssistantID != "" {
+		assistant, err := model.GetAssistantMapping(request.AssistantID, model.AssistantObjectAssistant, userId)
+		if err != nil {
+			return nil, common.StringErrorWrapper(fmt.Sprintf("No assistant found with id '%s'.", request.AssistantID), "invalid_request_error", http.StatusNotFound)
+		}
+		body, err := removeJSONField(r.body, "assistant_id")
+		if err != nil {
+			return nil, common.ErrorWrapper(err, "invalid_request_error", http.StatusBadRequest)
+		}
+		r.body = body
+		channelId, keyId = assistant.ChannelId, assistant.KeyId
+	} else if fileIDs := request.GetFileIDs(); len(fileIDs) > 0 {
+		file, err := model.GetUserFile(fileIDs[0], userId)
+		if err != nil {
+			return nil, common.StringErrorWrapper(fmt.Sprintf("No such File object: %s", fileIDs[0]), "invalid_request_error", http.StatusNotFound)
+		}
+		channelId, keyId = file.ChannelId, file.KeyId
+	} else {
+		latest, err := model.GetLatestAssistantMapping(model.AssistantObjectAssistant, userId)
+		if err != nil {
+			return nil, common.StringErrorWrapper("please create an assistant before creating threads", "invalid_request_error", http.StatusBadRequest)
+		}
+		channelId, keyId = latest.ChannelId, latest.KeyId
+	}
+
+	channel, err := fetchChannelByKey(channelId, keyId)
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "channel_error", http.StatusServiceUnavailable)
+	}
+	return channel, nil
+}
+
+func removeJSONField(body []byte, field string) ([]byte, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, field)
+	return json.Marshal(fields)
+}
+
+// 带有文件的 assistant 需要创建在文件所在的渠道上
+func (r *relayAssistants) fetchAssistantChannel(request *types.AssistantRequest) (*model.Channel, error) {
+	if len(request.FileIDs) == 0 {
+		return fetchChannel(r.c, request.Model)
+	}
+
+	file, err := model.GetUserFile(request.FileIDs[0], r.c.GetInt("id"))
+	if err != nil {
+		return nil, fmt.Errorf("No such File object: %s", request.FileIDs[0])
+	}
+
+	return fetchChannelByKey(file.ChannelId, file.KeyId)
+}
+
+// 创建 run 之前检查 assistant 归属及用户额度
+func (r *relayAssistants) getRunAssistant() (*model.AssistantMapping, *types.OpenAIErrorWithStatusCode) {
+	request := &types.RunRequest{}
+	if err := json.Unmarshal(r.body, request); err != nil {
+		return nil, common.ErrorWrapper(err, "invalid_request_error", http.StatusBadRequest)
+	}
+
+	assistant, err := model.GetAssistantMapping(request.AssistantID, model.AssistantObjectAssistant, r.c.GetInt("id"))
+	if err != nil {
+		return nil, common.StringErrorWrapper(fmt.Sprintf("No assistant found with id '%s'.", request.AssistantID), "invalid_request_error", http.StatusNotFound)
+	}
+
+	// run 可以指定模型覆盖 assistant 的模型
+	modelName := assistant.Model
+	if request.Model != nil && *request.Model != "" {
+		modelName = *request.Model
+	}
+	if errWithCode := checkTokenModel(r.c, modelName); errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	if errWithCode := checkUserQuota(r.c); errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return assistant, nil
+}
+
+func (r *relayAssistants) send(method, uri string, body []byte) ([]byte, *types.OpenAIErrorWithStatusCode) {
+	var requestBody any
+	if len(body) > 0 {
+		requestBody = bytes.NewReader(body)
+	}
+
+	resp, errWithCode := r.provider.SendAssistantsRequest(method, uri, requestBody)
+	if errWithCode != nil {
+		channel := r.provider.GetChannel()
+		go processChannelRelayError(r.c.Request.Context(), channel, errWithCode)
+		return nil, errWithCode
+	}
+	defer resp.Body.Close()
+
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+	}
+
+	return response, nil
+}
+
+// 记录新建对象的映射，删除对象时清理映射，并对已结束的 run 计费
+func (r *relayAssistants) handleResponse(response []byte) {
+	var err error
+	switch r.c.Request.Method + " " + r.c.FullPath() {
+	case "POST /v1/assistants":
+		assistant := &types.Assistant{}
+		if err = json.Unmarshal(response, assistant); err == nil {
+			err = r.insertMapping(assistant.ID, model.AssistantObjectAssistant, assistant.Model, "")
+		}
+	case "POST /v1/threads":
+		thread := &types.Thread{}
+		if err = json.Unmarshal(response, thread); err == nil {
+			err = r.insertMapping(thread.ID, model.AssistantObjectThread, "", "")
+		}
+	case "POST /v1/threads/runs":
+		run := &types.Run{}
+		if err = json.Unmarshal(response, run); err == nil {
+			err = r.insertMapping(run.ThreadID, model.AssistantObjectThread, "", "")
+			if err == nil {
+				err = r.insertMapping(run.ID, model.AssistantObjectRun, run.Model, run.ThreadID)
+			}
+		}
+	case "POST /v1/threads/:id/runs":
+		run := &types.Run{}
+		if err = json.Unmarshal(response, run); err == nil {
+			err = r.insertMapping(run.ID, model.AssistantObjectRun, run.Model, run.ThreadID)
+		}
+	case "DELETE /v1/assistants/:id", "DELETE /v1/threads/:id":
+		err = r.owner.Delete()
+	}
+
+	if err != nil {
+		common.LogError(r.c.Request.Context(), "assistants mapping error: "+err.Error())
+	}
+
+	if strings.Contains(r.c.FullPath(), "/runs") && !strings.Contains(r.c.FullPath(), "/steps") {
+		r.billRuns(response)
+	}
+}
+
+func (r *relayAssistants) insertMapping(objectId, object, modelName, threadId string) error {
+	if objectId == "" {
+		return errors.New("empty object id")
+	}
+
+	mapping := &model.AssistantMapping{
+		ObjectId:  objectId,
+		Object:    object,
+		UserId:    r.c.GetInt("id"),
+		TokenId:   r.c.GetInt("token_id"),
+		ChannelId: r.provider.GetChannel().Id,
+		KeyId:     r.provider.GetChannel().KeyId,
+		Model:     modelName,
+		ThreadId:  threadId,
+	}
+
+	return mapping.Insert()
+}
+
+func (r *relayAssistants) billRuns(response []byte) {
+	run := types.Run{}
+	if err := json.Unmarshal(response, &run); err != nil {
+		return
+	}
+
+	runs := []types.Run{run}
+	if run.Object == "list" {
+		runList := &types.RunList{}
+		if err := json.Unmarshal(response, runList); err != nil {
+			return
+		}
+		runs = runList.Runs
+	}
+
+	for i := range runs {
+		r.billRun(&runs[i])
+	}
+}
+
+// run 结束后上游才会返回 usage，此时按实际用量计费
+func (r *relayAssistants) billRun(run *types.Run) {
+	if run.Object != model.AssistantObjectRun || run.Usage == nil {
+		return
+	}
+
+	mapping, err := model.GetAssistantMapping(run.ID, model.AssistantObjectRun, r.c.GetInt("id"))
+	if err != nil {
+		return
+	}
+
+	billAssistantRun(r.c.Request.Context(), mapping, run)
+}
+
+// billAssistantRun 按 run 创建时的用户及令牌结算，后台同步时没有请求的上下文
+func billAssistantRun(ctx context.Context, mapping *model.AssistantMapping, run *types.Run) {
+	group, err := model.CacheGetUserGroup(mapping.UserId)
+	if err != nil {
+		common.LogError(ctx, "get user group error: "+err.Error())
+		return
+	}
+	billed, err := model.MarkAssistantRunBilled(run.ID)
+	if err != nil {
+		common.LogError(ctx, "mark run billed error: "+err.Error())
+		return
+	}
+	if !billed || run.Usage == nil {
+		return
+	}
+
+	modelName := run.Model
+	if modelName == "" {
+		modelName = mapping.Model
+	}
+
+	tokenName := ""
+	if token, err := model.GetTokenById(mapping.TokenId); err == nil {
+		tokenName = token.Name
+	}
+	quotaInfo := generatePostpaidQuotaInfo(group, modelName, mapping.UserId, mapping.ChannelId, mapping.TokenId)
+	quotaInfo.consumeAsync(ctx, tokenName, run.Usage)
+}
+
+// assistant 可能分布在多个渠道上，按本地映射表分页后逐个到所在渠道查询
+func (r *relayAssistants) listAssistants() {
+	mappings, err := model.GetUserAssistantMappings(model.AssistantObjectAssistant, r.c.GetInt("id"))
+	if err != nil {
+		common.AbortWithMessage(r.c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	// 映射表按 id 倒序，即创建时间倒序
+	if r.c.Query("order") == "asc" {
+		slices.Reverse(mappings)
+	}
+
+	start, end := 0, len(mappings)
+	if after := r.c.Query("after"); after != "" {
+		start = len(mappings)
+		if index := slices.IndexFunc(mappings, func(m *model.AssistantMapping) bool { return m.ObjectId == after }); index >= 0 {
+			start = index + 1
+		}
+	}
+	if before := r.c.Query("before"); before != "" {
+		if index := slices.IndexFunc(mappings, func(m *model.AssistantMapping) bool { return m.ObjectId == before }); index >= 0 && index < end {
+			end = index
+		}
+		if end-start > limit {
+			start = end - limit
+		}
+	}
+	if start > end {
+		start = end
+	}
+
+	result := &types.AssistantsList{
+		Object:     "list",
+		Assistants: make([]types.Assistant, 0),
+	}
+	providerCache := make(map[objectChannel]providersBase.AssistantsInterface)
+	index := start
+	for ; index < end && len(result.Assistants) < limit; index++ {
+		mapping := mappings[index]
+		cacheKey := objectChannel{mapping.ChannelId, mapping.KeyId}
+		provider, ok := providerCache[cacheKey]
+		if !ok {
+			if channel, err := fetchChannelByKey(mapping.ChannelId, mapping.KeyId); err == nil {
+				provider, _ = providers.GetProvider(channel, r.c).(providersBase.AssistantsInterface)
+			}
+			providerCache[cacheKey] = provider
+		}
+		if provider == nil {
+			continue
+		}
+		r.provider = provider
+
+		response, errWithCode := r.send(http.MethodGet, "/v1/assistants/"+mapping.ObjectId, nil)
+		if errWithCode != nil {
+			continue
+		}
+
+		assistant := types.Assistant{}
+		if err := json.Unmarshal(response, &assistant); err != nil || assistant.ID == "" {
+			continue
+		}
+		result.Assistants = append(result.Assistants, assistant)
+	}
+	result.HasMore = index < end
+
+	if len(result.Assistants) > 0 {
+		result.FirstID = &result.Assistants[0].ID
+		result.LastID = &result.Assistants[len(result.Assistants)-1].ID
+	}
+
+	r.c.JSON(http.StatusOK, result)
+}
+
+func responseRelayError(c *gin.Context, err *types.OpenAIErrorWithStatusCode) {
+	requestId := c.GetString(common.RequestIdKey)
+	err.OpenAIError.Message = common.MessageWithRequestId(err.OpenAIError.Message, requestId)
+	c.JSON(err.StatusCode, gin.H{
+		"error": err.OpenAIError,
+	})
+}
@@ -1,11 +1,13 @@
 package relay
 
 import (
+	"one-api/common/tracing"
 	"one-api/types"
 
 	providersBase "one-api/providers/base"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type relayBase struct {
@@ -24,15 +26,28 @@ type RelayBaseInterface interface {
 	getOriginalModel() string
 	getModelName() string
 	getContext() *gin.Context
+	// 按各自接口的格式返回错误
+	responseError(err *types.OpenAIErrorWithStatusCode)
 }
 
 func (r *relayBase) setProvider(modelName string) error {
+	_, span := tracing.Start(r.c.Request.Context(), "relay.set_provider", attribute.String("model", modelName))
+	defer span.End()
+
 	provider, modelName, fail := getProvider(r.c, modelName)
 	if fail != nil {
+		tracing.RecordError(span, fail)
 		return fail
 	}
 	r.provider = provider
 	r.modelName = modelName
+
+	channel := provider.GetChannel()
+	span.SetAttributes(
+		attribute.Int("channel.id", channel.Id),
+		attribute.Int("channel.type", channel.Type),
+		attribute.String("upstream_model", modelName),
+	)
 	return nil
 }
 
@@ -51,3 +66,7 @@ func (r *relayBase) getOriginalModel() string {
 func (r *relayBase) getModelName() string {
 	return r.modelName
 }
+
+func (r *relayBase) responseError(err *types.OpenAIErrorWithStatusCode) {
+	responseRelayError(r.c, err)
+}
@@ -0,0 +1,515 @@
+package relay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"one-api/common"
+	"one-api/common/requester"
+	"one-api/model"
+	"one-api/types"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	responseCacheKindChat       = "chat"
+	responseCacheKindCompletion = "completion"
+	responseCacheKindEmbedding  = "embedding"
+
+	responseCacheChunkSize = 20 // 缓存命中时重新分块输出的字符数
+)
+
+type cachedResponse struct {
+	Kind     string          `json:"kind"`
+	Response json.RawMessage `json:"response"`
+	Usage    types.Usage     `json:"usage"`
+}
+
+// relayCache 单次请求的响应缓存，未命中时收集上游响应，请求成功后写入缓存
+type relayCache struct {
+	key    string
+	kind   string
+	stream bool
+
+	response any
+	// 流式响应按 choice index 合并
+	chatChoices       map[int]*types.ChatCompletionChoice
+	completionChoices map[int]*types.CompletionChoice
+	streamId          string
+	streamModel       string
+	streamCompleted   bool
+}
+
+// 令牌开启了缓存，或者用户分组在 ResponseCacheGroups 中时才使用缓存
+func getResponseCacheScope(c *gin.Context) string {
+	if !common.ResponseCacheEnabled {
+		return ""
+	}
+	if c.GetBool("token_response_cache") {
+		return fmt.Sprintf("token:%d", c.GetInt("token_id"))
+	}
+	if group := c.GetString("group"); group != "" && slices.Contains(common.ResponseCacheGroups, group) {
+		return "group:" + group
+	}
+	return ""
+}
+
+// 去掉 stream、user 等不影响响应内容的字段后计算请求的哈希
+func newRelayCache(c *gin.Context, relay RelayBaseInterface) *relayCache {
+	if c.GetInt("specific_channel_id") > 0 {
+		return nil
+	}
+	scope := getResponseCacheScope(c)
+	if scope == "" {
+		return nil
+	}
+
+	cache := &relayCache{}
+	var request any
+	switch r := relay.(type) {
+	case *relayChat:
+		chatRequest := r.chatRequest
+		cache.kind = responseCacheKindChat
+		cache.stream = chatRequest.Stream
+		chatRequest.Stream = false
+		chatRequest.User = ""
+		request = chatRequest
+	case *relayCompletions:
+		completionRequest := r.request
+		cache.kind = responseCacheKindCompletion
+		cache.stream = completionRequest.Stream
+		completionRequest.Stream = false
+		completionRequest.User = ""
+		request = completionRequest
+	case *relayEmbeddings:
+		embeddingRequest := r.request
+		cache.kind = responseCacheKindEmbedding
+		embeddingRequest.User = ""
+		request = embeddingRequest
+	default:
+		return nil
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil
+	}
+	hash := sha256.Sum256(append([]byte(cache.kind+":"), body...))
+	cache.key = fmt.Sprintf("response_cache:%s:%s", scope, hex.EncodeToString(hash[:]))
+
+	return cache
+}
+
+func getRelayCache(c *gin.Context) *relayCache {
+	cache, ok := c.Get("response_cache")
+	if !ok {
+		return nil
+	}
+	return cache.(*relayCache)
+}
+
+// 命中缓存时直接返回缓存的响应并按折扣计费，未命中时记录到上下文中，请求成功后写入缓存
+// 命中缓存同样计入 RPM/TPM，并经过与正常请求相同的额度、模型限额及周期预算检查
+func serveResponseCache(c *gin.Context, relay RelayBaseInterface) bool {
+	cache := newRelayCache(c, relay)
+	if cache == nil {
+		return false
+	}
+
+	cached := cache.get()
+	if cached == nil {
+		c.Set("response_cache", cache)
+		return false
+	}
+
+	if errWithCode := checkRateLimit(c, cached.Usage.PromptTokens); errWithCode != nil {
+		relay.responseError(errWithCode)
+		return true
+	}
+
+	quotaInfo, errWithCode := generateQuotaInfo(c, relay.getOriginalModel(), cached.Usage.PromptTokens)
+	if errWithCode != nil {
+		relay.responseError(errWithCode)
+		return true
+	}
+
+	if err := cache.replay(c, cached); err != nil {
+		common.LogError(c.Request.Context(), "replay response cache failed: "+err.Error())
+		quotaInfo.undo(c)
+		c.Set("response_cache", cache)
+		return false
+	}
+
+	common.LogInfo(c.Request.Context(), "response cache hit: "+cache.key)
+	quotaInfo.cacheHit = true
+	quotaInfo.consume(c, &cached.Usage)
+	reconcileRateLimit(c, &cached.Usage)
+	return true
+}
+
+func (r *relayCache) get() *cachedResponse {
+	value, ok := model.GetResponseCache(r.key)
+	if !ok {
+		return nil
+	}
+
+	cached := &cachedResponse{}
+	if err := json.Unmarshal([]byte(value), cached); err != nil || cached.Kind != r.kind {
+		return nil
+	}
+	return cached
+}
+
+// 保存非流式响应
+func (r *relayCache) setResponse(response any) {
+	if r == nil {
+		return
+	}
+	r.response = response
+}
+
+// 收集流式响应的数据块
+func (r *relayCache) collect(data string) {
+	if r == nil {
+		return
+	}
+
+	switch r.kind {
+	case responseCacheKindChat:
+		r.collectChat(data)
+	case responseCacheKindCompletion:
+		r.collectCompletion(data)
+	}
+}
+
+func (r *relayCache) collectChat(data string) {
+	var chunk types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+	if r.chatChoices == nil {
+		r.chatChoices = make(map[int]*types.ChatCompletionChoice)
+	}
+	r.streamId = chunk.ID
+	r.streamModel = chunk.Model
+
+	for _, streamChoice := range chunk.Choices {
+		choice, ok := r.chatChoices[streamChoice.Index]
+		if !ok {
+			choice = &types.ChatCompletionChoice{
+				Index:   streamChoice.Index,
+				Message: types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant},
+			}
+			r.chatChoices[streamChoice.Index] = choice
+		}
+
+		delta := streamChoice.Delta
+		if delta.Content != "" {
+			content, _ := choice.Message.Content.(string)
+			choice.Message.Content = content + delta.Content
+		}
+		if delta.FunctionCall != nil {
+			if choice.Message.FunctionCall == nil {
+				choice.Message.FunctionCall = &types.ChatCompletionToolCallsFunction{}
+			}
+			mergeToolCallFunction(choice.Message.FunctionCall, delta.FunctionCall)
+		}
+		for _, toolCall := range delta.ToolCalls {
+			choice.Message.ToolCalls = mergeToolCall(choice.Message.ToolCalls, toolCall)
+		}
+		if streamChoice.FinishReason != nil && streamChoice.FinishReason != "" {
+			choice.FinishReason = streamChoice.FinishReason
+		}
+	}
+}
+
+func mergeToolCall(toolCalls []*types.ChatCompletionToolCalls, delta *types.ChatCompletionToolCalls) []*types.ChatCompletionToolCalls {
+	for _, toolCall := range toolCalls {
+		if toolCall.Index != delta.Index {
+			continue
+		}
+		if delta.Id != "" {
+			toolCall.Id = delta.Id
+		}
+		if delta.Type != "" {
+			toolCall.Type = delta.Type
+		}
+		if delta.Function != nil {
+			mergeToolCallFunction(toolCall.Function, delta.Function)
+		}
+		return toolCalls
+	}
+
+	toolCall := &types.ChatCompletionToolCalls{
+		Id:       delta.Id,
+		Type:     delta.Type,
+		Index:    delta.Index,
+		Function: &types.ChatCompletionToolCallsFunction{},
+	}
+	if delta.Function != nil {
+		mergeToolCallFunction(toolCall.Function, delta.Function)
+	}
+	return append(toolCalls, toolCall)
+}
+
+func mergeToolCallFunction(function, delta *types.ChatCompletionToolCallsFunction) {
+	if delta.Name != "" {
+		function.Name = delta.Name
+	}
+	function.Arguments += delta.Arguments
+}
+
+func (r *relayCache) collectCompletion(data string) {
+	var chunk types.CompletionResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+	if r.completionChoices == nil {
+		r.completionChoices = make(map[int]*types.CompletionChoice)
+	}
+	r.streamId = chunk.ID
+	r.streamModel = chunk.Model
+
+	for _, streamChoice := range chunk.Choices {
+		choice, ok := r.completionChoices[streamChoice.Index]
+		if !ok {
+			choice = &types.CompletionChoice{Index: streamChoice.Index}
+			r.completionChoices[streamChoice.Index] = choice
+		}
+		choice.Text += streamChoice.Text
+		if streamChoice.FinishReason != "" {
+			choice.FinishReason = streamChoice.FinishReason
+		}
+	}
+}
+
+// 流式响应正常结束后才会写入缓存
+func (r *relayCache) streamDone() {
+	if r == nil {
+		return
+	}
+	r.streamCompleted = true
+}
+
+// 将流式响应合并为非流式响应的格式
+func (r *relayCache) buildStreamResponse() any {
+	if !r.streamCompleted {
+		return nil
+	}
+
+	switch r.kind {
+	case responseCacheKindChat:
+		if len(r.chatChoices) == 0 {
+			return nil
+		}
+		response := &types.ChatCompletionResponse{
+			ID:     r.streamId,
+			Object: "chat.completion",
+			Model:  r.streamModel,
+		}
+		for _, choice := range r.chatChoices {
+			response.Choices = append(response.Choices, *choice)
+		}
+		sort.Slice(response.Choices, func(i, j int) bool {
+			return response.Choices[i].Index < response.Choices[j].Index
+		})
+		return response
+	case responseCacheKindCompletion:
+		if len(r.completionChoices) == 0 {
+			return nil
+		}
+		response := &types.CompletionResponse{
+			ID:     r.streamId,
+			Object: "text_completion",
+			Model:  r.streamModel,
+		}
+		for _, choice := range r.completionChoices {
+			response.Choices = append(response.Choices, *choice)
+		}
+		sort.Slice(response.Choices, func(i, j int) bool {
+			return response.Choices[i].Index < response.Choices[j].Index
+		})
+		return response
+	}
+	return nil
+}
+
+// 请求成功后写入缓存
+func (r *relayCache) save(usage *types.Usage) {
+	if r == nil {
+		return
+	}
+
+	response := r.response
+	if r.stream {
+		response = r.buildStreamResponse()
+	}
+	if response == nil || usage == nil {
+		return
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	value, err := json.Marshal(&cachedResponse{
+		Kind:     r.kind,
+		Response: body,
+		Usage:    *usage,
+	})
+	if err != nil {
+		return
+	}
+
+	if err := model.SetResponseCache(r.key, string(value), time.Duration(common.ResponseCacheSeconds)*time.Second); err != nil {
+		common.SysError("failed to save response cache: " + err.Error())
+	}
+}
+
+func (r *relayCache) replay(c *gin.Context, cached *cachedResponse) error {
+	c.Header("X-Cache", "HIT")
+
+	switch r.kind {
+	case responseCacheKindChat:
+		var response types.ChatCompletionResponse
+		if err := json.Unmarshal(cached.Response, &response); err != nil {
+			return err
+		}
+		response.Created = common.GetTimestamp()
+		if r.stream {
+			replayChatStream(c, &response)
+			return nil
+		}
+		response.Usage = &cached.Usage
+		return replayJson(c, response)
+	case responseCacheKindCompletion:
+		var response types.CompletionResponse
+		if err := json.Unmarshal(cached.Response, &response); err != nil {
+			return err
+		}
+		response.Created = common.GetTimestamp()
+		if r.stream {
+			replayCompletionStream(c, &response)
+			return nil
+		}
+		response.Usage = &cached.Usage
+		return replayJson(c, response)
+	default:
+		var response types.EmbeddingResponse
+		if err := json.Unmarshal(cached.Response, &response); err != nil {
+			return err
+		}
+		response.Usage = &cached.Usage
+		return replayJson(c, response)
+	}
+}
+
+func replayJson(c *gin.Context, response any) error {
+	if errWithCode := responseJsonClient(c, response); errWithCode != nil {
+		return &errWithCode.OpenAIError
+	}
+	return nil
+}
+
+func splitCacheContent(content string) []string {
+	runes := []rune(content)
+	chunks := make([]string, 0, len(runes)/responseCacheChunkSize+1)
+	for i := 0; i < len(runes); i += responseCacheChunkSize {
+		end := i + responseCacheChunkSize
+		if end > len(runes) {
+			end = len(runes)
+		}
+		chunks = append(chunks, string(runes[i:end]))
+	}
+	return chunks
+}
+
+func writeCacheStream(c *gin.Context, chunks []any) {
+	requester.SetEventStreamHeaders(c)
+	c.Stream(func(w io.Writer) bool {
+		for _, chunk := range chunks {
+			data, _ := json.Marshal(chunk)
+			fmt.Fprintln(w, "data: "+string(data)+"\n")
+		}
+		fmt.Fprintln(w, "data: [DONE]")
+		return false
+	})
+}
+
+func replayChatStream(c *gin.Context, response *types.ChatCompletionResponse) {
+	newChunk := func(choice types.ChatCompletionStreamChoice) types.ChatCompletionStreamResponse {
+		return types.ChatCompletionStreamResponse{
+			ID:      response.ID,
+			Object:  "chat.completion.chunk",
+			Created: response.Created,
+			Model:   response.Model,
+			Choices: []types.ChatCompletionStreamChoice{choice},
+		}
+	}
+
+	chunks := make([]any, 0)
+	for _, choice := range response.Choices {
+		chunks = append(chunks, newChunk(types.ChatCompletionStreamChoice{
+			Index: choice.Index,
+			Delta: types.ChatCompletionStreamChoiceDelta{Role: choice.Message.Role},
+		}))
+
+		for _, content := range splitCacheContent(choice.Message.StringContent()) {
+			chunks = append(chunks, newChunk(types.ChatCompletionStreamChoice{
+				Index: choice.Index,
+				Delta: types.ChatCompletionStreamChoiceDelta{Content: content},
+			}))
+		}
+
+		if choice.Message.FunctionCall != nil || len(choice.Message.ToolCalls) > 0 {
+			chunks = append(chunks, newChunk(types.ChatCompletionStreamChoice{
+				Index: choice.Index,
+				Delta: types.ChatCompletionStreamChoiceDelta{
+					FunctionCall: choice.Message.FunctionCall,
+					ToolCalls:    choice.Message.ToolCalls,
+				},
+			}))
+		}
+
+		chunks = append(chunks, newChunk(types.ChatCompletionStreamChoice{
+			Index:        choice.Index,
+			FinishReason: choice.FinishReason,
+		}))
+	}
+
+	writeCacheStream(c, chunks)
+}
+
+func replayCompletionStream(c *gin.Context, response *types.CompletionResponse) {
+	newChunk := func(choice types.CompletionChoice) types.CompletionResponse {
+		return types.CompletionResponse{
+			ID:      response.ID,
+			Object:  "text_completion",
+			Created: response.Created,
+			Model:   response.Model,
+			Choices: []types.CompletionChoice{choice},
+		}
+	}
+
+	chunks := make([]any, 0)
+	for _, choice := range response.Choices {
+		for _, text := range splitCacheContent(choice.Text) {
+			chunks = append(chunks, newChunk(types.CompletionChoice{
+				Index: choice.Index,
+				Text:  text,
+			}))
+		}
+		chunks = append(chunks, newChunk(types.CompletionChoice{
+			Index:        choice.Index,
+			FinishReason: choice.FinishReason,
+		}))
+	}
+
+	writeCacheStream(c, chunks)
+}
@@ -53,18 +53,22 @@ func (r *relayChat) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 
 	if r.chatRequest.Stream {
 		var response requester.StreamReaderInterface[string]
+		startStreamTimeout(r.c)
 		response, err = chatProvider.CreateChatCompletionStream(&r.chatRequest)
 		if err != nil {
 			return
 		}
 
+		// 流式响应只会在首个数据块之前返回错误，此时还没有向客户端输出，不标记 done 以便重试其他渠道
 		err = responseStreamClient(r.c, response)
+		return
 	} else {
 		var response *types.ChatCompletionResponse
 		response, err = chatProvider.CreateChatCompletion(&r.chatRequest)
 		if err != nil {
 			return
 		}
+		getRelayCache(r.c).setResponse(response)
 		err = responseJsonClient(r.c, response)
 	}
 
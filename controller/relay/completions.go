@@ -53,18 +53,21 @@ func (r *relayCompletions) send() (err *types.OpenAIErrorWithStatusCode, done bo
 
 	if r.request.Stream {
 		var response requester.StreamReaderInterface[string]
+		startStreamTimeout(r.c)
 		response, err = provider.CreateCompletionStream(&r.request)
 		if err != nil {
 			return
 		}
 
 		err = responseStreamClient(r.c, response)
+		return
 	} else {
 		var response *types.CompletionResponse
 		response, err = provider.CreateCompletion(&r.request)
 		if err != nil {
 			return
 		}
+		getRelayCache(r.c).setResponse(response)
 		err = responseJsonClient(r.c, response)
 	}
 
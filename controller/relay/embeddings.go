@@ -53,6 +53,7 @@ func (r *relayEmbeddings) send() (err *types.OpenAIErrorWithStatusCode, done boo
 	if err != nil {
 		return
 	}
+	getRelayCache(r.c).setResponse(response)
 	err = responseJsonClient(r.c, response)
 
 	if err != nil {
@@ -0,0 +1,231 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/providers"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 支持 Files 接口的渠道类型
+var filesChannelTypes = []int{common.ChannelTypeOpenAI}
+
+func UploadFile(c *gin.Context) {
+	var request types.FileRequest
+	if err := c.ShouldBind(&request); err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	channel, err := fetchFilesChannel(c, &request)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	provider, errWithCode := getFilesProvider(c, channel)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	file, errWithCode := provider.CreateFile(&request)
+	if errWithCode != nil {
+		go processChannelRelayError(c.Request.Context(), provider.GetChannel(), errWithCode)
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	fileMapping := &model.File{
+		FileId:    file.ID,
+		UserId:    c.GetInt("id"),
+		TokenId:   c.GetInt("token_id"),
+		ChannelId: channel.Id,
+		KeyId:     provider.GetChannel().KeyId,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Bytes:     file.Bytes,
+	}
+	if err := fileMapping.Insert(); err != nil {
+		common.LogError(c.Request.Context(), "insert file error: "+err.Error())
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+// 文件可能分布在多个渠道上，逐个渠道查询后只返回属于当前用户的文件
+func ListFiles(c *gin.Context) {
+	purpose := c.Query("purpose")
+	files, err := model.GetUserFiles(c.GetInt("id"), purpose)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	owned := make(map[string]bool, len(files))
+	objectChannels := make([]objectChannel, 0)
+	for _, file := range files {
+		owned[file.FileId] = true
+		fileChannel := objectChannel{file.ChannelId, file.KeyId}
+		if !slices.Contains(objectChannels, fileChannel) {
+			objectChannels = append(objectChannels, fileChannel)
+		}
+	}
+
+	result := &types.FilesList{
+		Object: "list",
+		Files:  make([]types.File, 0),
+	}
+	for _, fileChannel := range objectChannels {
+		channel, err := fetchChannelByKey(fileChannel.channelId, fileChannel.keyId)
+		if err != nil {
+			continue
+		}
+
+		provider, errWithCode := getFilesProvider(c, channel)
+		if errWithCode != nil {
+			continue
+		}
+
+		filesList, errWithCode := provider.ListFiles(purpose)
+		if errWithCode != nil {
+			go processChannelRelayError(c.Request.Context(), provider.GetChannel(), errWithCode)
+			continue
+		}
+
+		for _, file := range filesList.Files {
+			if owned[file.ID] {
+				result.Files = append(result.Files, file)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func RetrieveFile(c *gin.Context) {
+	_, provider, errWithCode := getUserFileProvider(c)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	file, errWithCode := provider.RetrieveFile(c.Param("id"))
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+func DeleteFile(c *gin.Context) {
+	fileMapping, provider, errWithCode := getUserFileProvider(c)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	response, errWithCode := provider.DeleteFile(fileMapping.FileId)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	if response.Deleted {
+		if err := fileMapping.Delete(); err != nil {
+			common.LogError(c.Request.Context(), "delete file error: "+err.Error())
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func RetrieveFileContent(c *gin.Context) {
+	fileMapping, provider, errWithCode := getUserFileProvider(c)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	response, errWithCode := provider.RetrieveFileContent(fileMapping.FileId)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	responseMultipart(c, response)
+}
+
+func fetchFilesChannel(c *gin.Context, request *types.FileRequest) (*model.Channel, error) {
+	channelId := c.GetInt("specific_channel_id")
+	if channelId > 0 {
+		return fetchChannelById(channelId)
+	}
+
+	// assistants 用途的文件需要与 assistant 位于同一渠道，未指定 assistant 时使用最近创建的 assistant
+	if request.AssistantID != "" {
+		assistant, err := model.GetAssistantMapping(request.AssistantID, model.AssistantObjectAssistant, c.GetInt("id"))
+		if err != nil {
+			return nil, fmt.Errorf("No assistant found with id '%s'.", request.AssistantID)
+		}
+		return fetchChannelByKey(assistant.ChannelId, assistant.KeyId)
+	}
+	if request.Purpose == "assistants" {
+		latest, err := model.GetLatestAssistantMapping(model.AssistantObjectAssistant, c.GetInt("id"))
+		if err == nil {
+			return fetchChannelByKey(latest.ChannelId, latest.KeyId)
+		}
+	}
+
+	group := c.GetString("group")
+	channel, err := model.ChannelGroup.NextByType(group, filesChannelTypes, getSkipChannelIds(c)...)
+	if err != nil {
+		return nil, fmt.Errorf("当前分组 %s 下无支持文件接口的渠道", group)
+	}
+
+	return channel, nil
+}
+
+func getFilesProvider(c *gin.Context, channel *model.Channel) (providersBase.FilesInterface, *types.OpenAIErrorWithStatusCode) {
+	c.Set("channel_id", channel.Id)
+
+	provider := providers.GetProvider(channel, c)
+	if provider == nil {
+		return nil, common.StringErrorWrapper("channel not found", "channel_error", http.StatusServiceUnavailable)
+	}
+
+	filesProvider, ok := provider.(providersBase.FilesInterface)
+	if !ok {
+		return nil, common.StringErrorWrapper("channel not implemented", "channel_error", http.StatusServiceUnavailable)
+	}
+
+	return filesProvider, nil
+}
+
+// 获取当前用户的文件及其所在渠道
+func getUserFileProvider(c *gin.Context) (*model.File, providersBase.FilesInterface, *types.OpenAIErrorWithStatusCode) {
+	fileId := c.Param("id")
+	fileMapping, err := model.GetUserFile(fileId, c.GetInt("id"))
+	if err != nil {
+		return nil, nil, common.StringErrorWrapper(fmt.Sprintf("No such File object: %s", fileId), "invalid_request_error", http.StatusNotFound)
+	}
+
+	channel, err := fetchChannelByKey(fileMapping.ChannelId, fileMapping.KeyId)
+	if err != nil {
+		return nil, nil, common.ErrorWrapper(err, "channel_error", http.StatusServiceUnavailable)
+	}
+
+	provider, errWithCode := getFilesProvider(c, channel)
+	if errWithCode != nil {
+		return nil, nil, errWithCode
+	}
+
+	return fileMapping, provider, nil
+}
@@ -0,0 +1,301 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/requester"
+	"one-api/model"
+	"one-api/providers"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 流式返回任务事件时轮询上游的间隔
+const fineTuningEventsPollInterval = 5 * time.Second
+
+// 微调任务必须创建在训练文件所在的渠道上
+func CreateFineTuningJob(c *gin.Context) {
+	var request types.FineTuningJobRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, err := model.GetUserFile(request.TrainingFile, c.GetInt("id"))
+	if err != nil {
+		responseRelayError(c, common.StringErrorWrapper(fmt.Sprintf("No such File object: %s", request.TrainingFile), "invalid_request_error", http.StatusNotFound))
+		return
+	}
+
+	channel, err := fetchChannelByKey(file.ChannelId, file.KeyId)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	provider, errWithCode := getFineTuningProvider(c, channel)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	if errWithCode := checkTokenModel(c, request.Model); errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	if errWithCode := checkUserQuota(c); errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	job, errWithCode := provider.CreateFineTuningJob(&request)
+	if errWithCode != nil {
+		go processChannelRelayError(c.Request.Context(), provider.GetChannel(), errWithCode)
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	jobMapping := &model.FineTuningJob{
+		JobId:     job.ID,
+		UserId:    c.GetInt("id"),
+		TokenId:   c.GetInt("token_id"),
+		ChannelId: channel.Id,
+		KeyId:     provider.GetChannel().KeyId,
+		Group:     c.GetString("group"),
+		Model:     job.Model,
+		Status:    job.Status,
+	}
+	if err := jobMapping.Insert(); err != nil {
+		common.LogError(c.Request.Context(), "insert fine-tuning job error: "+err.Error())
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// 上游账号由多个用户共享，按本地记录分页后逐个到任务所在的渠道查询
+func ListFineTuningJobs(c *gin.Context) {
+	var params types.FineTuningListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if params.Limit <= 0 || params.Limit > 100 {
+		params.Limit = 20
+	}
+
+	// 多取一条用于判断是否还有下一页
+	jobs, err := model.GetUserFineTuningJobs(c.GetInt("id"), params.After, params.Limit+1)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result := &types.FineTuningJobList{
+		Object:  "list",
+		Data:    make([]types.FineTuningJob, 0, len(jobs)),
+		HasMore: len(jobs) > params.Limit,
+	}
+	if result.HasMore {
+		jobs = jobs[:params.Limit]
+	}
+
+	providerCache := make(map[objectChannel]providersBase.FineTuningInterface)
+	for _, jobMapping := range jobs {
+		cacheKey := objectChannel{jobMapping.ChannelId, jobMapping.KeyId}
+		provider, ok := providerCache[cacheKey]
+		if !ok {
+			if channel, err := fetchChannelByKey(jobMapping.ChannelId, jobMapping.KeyId); err == nil {
+				provider, _ = getFineTuningProvider(c, channel)
+			}
+			providerCache[cacheKey] = provider
+		}
+
+		if provider != nil {
+			job, errWithCode := provider.RetrieveFineTuningJob(jobMapping.JobId)
+			if errWithCode == nil {
+				syncFineTuningJob(c.Request.Context(), jobMapping, job)
+				result.Data = append(result.Data, *job)
+				continue
+			}
+		}
+		// 渠道不可用时返回本地记录的状态，保证分页完整
+		result.Data = append(result.Data, localFineTuningJob(jobMapping))
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func RetrieveFineTuningJob(c *gin.Context) {
+	jobMapping, provider, errWithCode := getUserFineTuningJobProvider(c)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	job, errWithCode := provider.RetrieveFineTuningJob(jobMapping.JobId)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+	syncFineTuningJob(c.Request.Context(), jobMapping, job)
+
+	c.JSON(http.StatusOK, job)
+}
+
+func CancelFineTuningJob(c *gin.Context) {
+	jobMapping, provider, errWithCode := getUserFineTuningJobProvider(c)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	job, errWithCode := provider.CancelFineTuningJob(jobMapping.JobId)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+	syncFineTuningJob(c.Request.Context(), jobMapping, job)
+
+	c.JSON(http.StatusOK, job)
+}
+
+// ?stream=true 时以 SSE 持续推送新事件，直到任务结束或客户端断开
+func ListFineTuningJobEvents(c *gin.Context) {
+	jobMapping, provider, errWithCode := getUserFineTuningJobProvider(c)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	if c.Query("stream") == "true" {
+		streamFineTuningJobEvents(c, jobMapping, provider)
+		return
+	}
+
+	var params types.FineTuningListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, errWithCode := provider.ListFineTuningJobEvents(jobMapping.JobId, &params)
+	if errWithCode != nil {
+		responseRelayError(c, errWithCode)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+func streamFineTuningJobEvents(c *gin.Context, jobMapping *model.FineTuningJob, provider providersBase.FineTuningInterface) {
+	requester.SetEventStreamHeaders(c)
+
+	seen := make(map[string]bool)
+	c.Stream(func(w io.Writer) bool {
+		events, errWithCode := provider.ListFineTuningJobEvents(jobMapping.JobId, &types.FineTuningListParams{Limit: 100})
+		if errWithCode != nil {
+			fmt.Fprintln(w, "data: "+errWithCode.OpenAIError.Error()+"\n")
+			fmt.Fprintln(w, "data: [DONE]")
+			return false
+		}
+
+		// 上游按时间倒序返回，推送时改为正序
+		for i := len(events.Data) - 1; i >= 0; i-- {
+			event := events.Data[i]
+			if seen[event.ID] {
+				continue
+			}
+			seen[event.ID] = true
+			data, _ := json.Marshal(event)
+			fmt.Fprintln(w, "data: "+string(data)+"\n")
+		}
+
+		job, errWithCode := provider.RetrieveFineTuningJob(jobMapping.JobId)
+		if errWithCode == nil {
+			syncFineTuningJob(c.Request.Context(), jobMapping, job)
+			if job.IsFinished() {
+				fmt.Fprintln(w, "data: [DONE]")
+				return false
+			}
+		}
+		c.Writer.Flush()
+
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-time.After(fineTuningEventsPollInterval):
+			return true
+		}
+	})
+}
+
+// 同步任务状态，任务成功后自动为所属分组注册微调模型
+func syncFineTuningJob(ctx context.Context, jobMapping *model.FineTuningJob, job *types.FineTuningJob) {
+	fineTunedModel := ""
+	if job.FineTunedModel != nil {
+		fineTunedModel = *job.FineTunedModel
+	}
+
+	if err := jobMapping.UpdateStatus(job.Status, fineTunedModel); err != nil {
+		common.LogError(ctx, "update fine-tuning job error: "+err.Error())
+	}
+}
+
+func localFineTuningJob(jobMapping *model.FineTuningJob) types.FineTuningJob {
+	job := types.FineTuningJob{
+		ID:        jobMapping.JobId,
+		Object:    "fine_tuning.job",
+		CreatedAt: jobMapping.CreatedTime,
+		Model:     jobMapping.Model,
+		Status:    jobMapping.Status,
+	}
+	if jobMapping.FineTunedModel != "" {
+		job.FineTunedModel = &jobMapping.FineTunedModel
+	}
+	return job
+}
+
+func getFineTuningProvider(c *gin.Context, channel *model.Channel) (providersBase.FineTuningInterface, *types.OpenAIErrorWithStatusCode) {
+	c.Set("channel_id", channel.Id)
+
+	provider := providers.GetProvider(channel, c)
+	if provider == nil {
+		return nil, common.StringErrorWrapper("channel not found", "channel_error", http.StatusServiceUnavailable)
+	}
+
+	fineTuningProvider, ok := provider.(providersBase.FineTuningInterface)
+	if !ok {
+		return nil, common.StringErrorWrapper("channel not implemented", "channel_error", http.StatusServiceUnavailable)
+	}
+
+	return fineTuningProvider, nil
+}
+
+// 获取当前用户的微调任务及其所在渠道
+func getUserFineTuningJobProvider(c *gin.Context) (*model.FineTuningJob, providersBase.FineTuningInterface, *types.OpenAIErrorWithStatusCode) {
+	jobId := c.Param("id")
+	jobMapping, err := model.GetUserFineTuningJob(jobId, c.GetInt("id"))
+	if err != nil {
+		return nil, nil, common.StringErrorWrapper(fmt.Sprintf("No such fine-tuning job: %s", jobId), "invalid_request_error", http.StatusNotFound)
+	}
+
+	channel, err := fetchChannelByKey(jobMapping.ChannelId, jobMapping.KeyId)
+	if err != nil {
+		return nil, nil, common.ErrorWrapper(err, "channel_error", http.StatusServiceUnavailable)
+	}
+
+	provider, errWithCode := getFineTuningProvider(c, channel)
+	if errWithCode != nil {
+		return nil, nil, errWithCode
+	}
+
+	return jobMapping, provider, nil
+}
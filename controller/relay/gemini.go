@@ -0,0 +1,536 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/requester"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	geminiActionGenerateContent       = "generateContent"
+	geminiActionStreamGenerateContent = "streamGenerateContent"
+)
+
+// relayGemini 兼容 Gemini generateContent 接口，请求转换为 OpenAI 格式后交给任意支持 chat 的渠道处理
+type relayGemini struct {
+	relayBase
+	geminiRequest types.GeminiRequest
+	chatRequest   types.ChatCompletionRequest
+}
+
+func NewRelayGemini(c *gin.Context) *relayGemini {
+	relay := &relayGemini{}
+	relay.c = c
+	return relay
+}
+
+func (r *relayGemini) setRequest() error {
+	// 路径格式为 /v1beta/models/{model}:{action}
+	modelAction := strings.TrimPrefix(r.c.Param("model"), "/")
+	index := strings.LastIndex(modelAction, ":")
+	if index <= 0 {
+		return errors.New("invalid path, expected models/{model}:generateContent")
+	}
+	modelName, action := modelAction[:index], modelAction[index+1:]
+	if action != geminiActionGenerateContent && action != geminiActionStreamGenerateContent {
+		return fmt.Errorf("unsupported action: %s", action)
+	}
+
+	if err := common.UnmarshalBodyReusable(r.c, &r.geminiRequest); err != nil {
+		return err
+	}
+
+	if err := r.convertToChatRequest(); err != nil {
+		return err
+	}
+	r.chatRequest.Model = modelName
+	r.chatRequest.Stream = action == geminiActionStreamGenerateContent
+
+	r.originalModel = modelName
+
+	return nil
+}
+
+func (r *relayGemini) getPromptTokens() (int, error) {
+	return common.CountTokenMessages(r.chatRequest.Messages, r.modelName), nil
+}
+
+func (r *relayGemini) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
+	chatProvider, ok := r.provider.(providersBase.ChatInterface)
+	if !ok {
+		err = common.StringErrorWrapper("channel not implemented", "channel_error", http.StatusServiceUnavailable)
+		done = true
+		return
+	}
+
+	r.chatRequest.Model = r.modelName
+
+	if r.chatRequest.Stream {
+		var response requester.StreamReaderInterface[string]
+		startStreamTimeout(r.c)
+		response, err = chatProvider.CreateChatCompletionStream(&r.chatRequest)
+		if err != nil {
+			return
+		}
+
+		err = r.responseStream(response)
+		return
+	} else {
+		var response *types.ChatCompletionResponse
+		response, err = chatProvider.CreateChatCompletion(&r.chatRequest)
+		if err != nil {
+			return
+		}
+		err = responseJsonClient(r.c, convertToGeminiResponse(response))
+	}
+
+	if err != nil {
+		done = true
+	}
+
+	return
+}
+
+// Gemini 格式的错误：{"error":{"code":...,"message":...,"status":...}}
+func (r *relayGemini) responseError(err *types.OpenAIErrorWithStatusCode) {
+	requestId := r.c.GetString(common.RequestIdKey)
+	r.c.JSON(err.StatusCode, newGeminiError(err.StatusCode, common.MessageWithRequestId(err.Message, requestId)))
+}
+
+func newGeminiError(statusCode int, message string) *types.GeminiErrorResponse {
+	return &types.GeminiErrorResponse{
+		Error: types.GeminiError{
+			Code:    statusCode,
+			Message: message,
+			Status:  geminiErrorStatus(statusCode),
+		},
+	}
+}
+
+func geminiErrorStatus(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	default:
+		return "INTERNAL"
+	}
+}
+
+func (r *relayGemini) convertToChatRequest() error {
+	request := &r.geminiRequest
+	r.chatRequest = types.ChatCompletionRequest{
+		Messages: make([]types.ChatCompletionMessage, 0, len(request.Contents)+1),
+	}
+
+	if config := request.GenerationConfig; config != nil {
+		r.chatRequest.Temperature = config.Temperature
+		r.chatRequest.TopP = config.TopP
+		r.chatRequest.MaxTokens = config.MaxOutputTokens
+		r.chatRequest.N = config.CandidateCount
+		r.chatRequest.Stop = config.StopSequences
+	}
+
+	if request.SystemInstruction != nil {
+		var system string
+		for _, part := range request.SystemInstruction.Parts {
+			system += part.Text
+		}
+		if system != "" {
+			r.chatRequest.Messages = append(r.chatRequest.Messages, types.ChatCompletionMessage{
+				Role:    types.ChatMessageRoleSystem,
+				Content: system,
+			})
+		}
+	}
+
+	// gemini 的函数调用没有 id，按函数名依次匹配调用与结果
+	callCount := 0
+	pendingCalls := make(map[string][]string)
+	for _, content := range request.Contents {
+		if content.Role == types.GeminiRoleModel {
+			message := types.ChatCompletionMessage{
+				Role: types.ChatMessageRoleAssistant,
+			}
+			var text string
+			for _, part := range content.Parts {
+				if part.FunctionCall == nil {
+					text += part.Text
+					continue
+				}
+				callCount++
+				id := fmt.Sprintf("call_%d", callCount)
+				pendingCalls[part.FunctionCall.Name] = append(pendingCalls[part.FunctionCall.Name], id)
+				args, _ := json.Marshal(part.FunctionCall.Args)
+				message.ToolCalls = append(message.ToolCalls, &types.ChatCompletionToolCalls{
+					Id:   id,
+					Type: "function",
+					Function: &types.ChatCompletionToolCallsFunction{
+						Name:      part.FunctionCall.Name,
+						Arguments: string(args),
+					},
+					Index: len(message.ToolCalls),
+				})
+			}
+			message.Content = text
+			r.chatRequest.Messages = append(r.chatRequest.Messages, message)
+			continue
+		}
+
+		parts := make([]any, 0, len(content.Parts))
+		for _, part := range content.Parts {
+			switch {
+			case part.FunctionResponse != nil:
+				name := part.FunctionResponse.Name
+				var id string
+				if ids := pendingCalls[name]; len(ids) > 0 {
+					id, pendingCalls[name] = ids[0], ids[1:]
+				}
+				response, _ := json.Marshal(part.FunctionResponse.Response)
+				r.chatRequest.Messages = append(r.chatRequest.Messages, types.ChatCompletionMessage{
+					Role:       types.ChatMessageRoleTool,
+					Name:       &name,
+					Content:    string(response),
+					ToolCallID: id,
+				})
+			case part.InlineData != nil:
+				parts = append(parts, map[string]any{
+					"type": types.ContentTypeImageURL,
+					"image_url": map[string]any{
+						"url": fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data),
+					},
+				})
+			case part.Text != "":
+				parts = append(parts, map[string]any{
+					"type": types.ContentTypeText,
+					"text": part.Text,
+				})
+			}
+		}
+
+		if len(parts) == 0 {
+			continue
+		}
+
+		message := types.ChatCompletionMessage{
+			Role:    types.ChatMessageRoleUser,
+			Content: parts,
+		}
+		// 只有文本时使用字符串，兼容不支持多模态的渠道
+		if len(parts) == 1 && parts[0].(map[string]any)["type"] == types.ContentTypeText {
+			message.Content = parts[0].(map[string]any)["text"]
+		}
+		r.chatRequest.Messages = append(r.chatRequest.Messages, message)
+	}
+
+	for _, tool := range request.Tools {
+		for _, declaration := range tool.FunctionDeclarations {
+			r.chatRequest.Tools = append(r.chatRequest.Tools, &types.ChatCompletionTool{
+				Type: "function",
+				Function: types.ChatCompletionFunction{
+					Name:        declaration.Name,
+					Description: declaration.Description,
+					Parameters:  convertGeminiSchema(declaration.Parameters),
+				},
+			})
+		}
+	}
+
+	if r.chatRequest.Tools != nil && request.ToolConfig != nil && request.ToolConfig.FunctionCallingConfig != nil {
+		config := request.ToolConfig.FunctionCallingConfig
+		switch strings.ToUpper(config.Mode) {
+		case "AUTO":
+			r.chatRequest.ToolChoice = "auto"
+		case "NONE":
+			r.chatRequest.ToolChoice = "none"
+		case "ANY":
+			r.chatRequest.ToolChoice = "required"
+			if len(config.AllowedFunctionNames) == 1 {
+				r.chatRequest.ToolChoice = map[string]any{
+					"type": "function",
+					"function": map[string]any{
+						"name": config.AllowedFunctionNames[0],
+					},
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// gemini 的 schema 类型为大写（OBJECT、STRING），转换为 JSON Schema 的小写类型
+func convertGeminiSchema(schema any) any {
+	switch value := schema.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(value))
+		for key, item := range value {
+			if typeName, ok := item.(string); ok && key == "type" {
+				result[key] = strings.ToLower(typeName)
+				continue
+			}
+			result[key] = convertGeminiSchema(item)
+		}
+		return result
+	case []any:
+		result := make([]any, 0, len(value))
+		for _, item := range value {
+			result = append(result, convertGeminiSchema(item))
+		}
+		return result
+	default:
+		return schema
+	}
+}
+
+func convertToGeminiResponse(response *types.ChatCompletionResponse) *types.GeminiResponse {
+	geminiResponse := &types.GeminiResponse{
+		Candidates: make([]types.GeminiCandidate, 0, len(response.Choices)),
+	}
+
+	for _, choice := range response.Choices {
+		candidate := types.GeminiCandidate{
+			Index: choice.Index,
+			Content: types.GeminiContent{
+				Role:  types.GeminiRoleModel,
+				Parts: make([]types.GeminiPart, 0),
+			},
+		}
+		if text := choice.Message.StringContent(); text != "" {
+			candidate.Content.Parts = append(candidate.Content.Parts, types.GeminiPart{
+				Text: text,
+			})
+		}
+
+		toolCalls := choice.Message.ToolCalls
+		if choice.Message.FunctionCall != nil {
+			toolCalls = append(toolCalls, &types.ChatCompletionToolCalls{
+				Function: choice.Message.FunctionCall,
+			})
+		}
+		for _, toolCall := range toolCalls {
+			if toolCall.Function == nil {
+				continue
+			}
+			candidate.Content.Parts = append(candidate.Content.Parts, convertGeminiFunctionCall(toolCall.Function.Name, toolCall.Function.Arguments))
+		}
+
+		finishReason, _ := choice.FinishReason.(string)
+		candidate.FinishReason = finishReasonOpenAI2Gemini(finishReason)
+		geminiResponse.Candidates = append(geminiResponse.Candidates, candidate)
+	}
+
+	if response.Usage != nil {
+		geminiResponse.UsageMetadata = &types.GeminiUsageMetadata{
+			PromptTokenCount:     response.Usage.PromptTokens,
+			CandidatesTokenCount: response.Usage.CompletionTokens,
+			TotalTokenCount:      response.Usage.TotalTokens,
+		}
+	}
+
+	return geminiResponse
+}
+
+func convertGeminiFunctionCall(name, arguments string) types.GeminiPart {
+	args := make(map[string]any)
+	if arguments != "" {
+		_ = json.Unmarshal([]byte(arguments), &args)
+	}
+
+	return types.GeminiPart{
+		FunctionCall: &types.GeminiFunctionCall{
+			Name: name,
+			Args: args,
+		},
+	}
+}
+
+func finishReasonOpenAI2Gemini(finishReason string) string {
+	switch finishReason {
+	case "", types.FinishReasonNull:
+		return ""
+	case types.FinishReasonLength:
+		return types.GeminiFinishReasonMaxTokens
+	case types.FinishReasonContentFilter:
+		return types.GeminiFinishReasonSafety
+	default:
+		return types.GeminiFinishReasonStop
+	}
+}
+
+// geminiStreamConverter 将 OpenAI 流式响应转换为 Gemini 流式响应
+// gemini 的函数调用不分片，参数拼接完成后在结束时一次性输出
+type geminiStreamConverter struct {
+	w     io.Writer
+	sse   bool
+	count int
+	// openai tool_calls index => 函数调用
+	toolCalls    map[int]*types.ChatCompletionToolCallsFunction
+	toolIndexes  []int
+	finishReason string
+}
+
+func (r *relayGemini) responseStream(stream requester.StreamReaderInterface[string]) *types.OpenAIErrorWithStatusCode {
+	defer stream.Close()
+	receiver, errWithCode := receiveStream(r.c, stream)
+	if errWithCode != nil {
+		return errWithCode
+	}
+
+	converter := &geminiStreamConverter{
+		sse:       r.c.Query("alt") == "sse",
+		toolCalls: make(map[int]*types.ChatCompletionToolCallsFunction),
+	}
+	if converter.sse {
+		requester.SetEventStreamHeaders(r.c)
+	} else {
+		r.c.Writer.Header().Set("Content-Type", "application/json")
+	}
+
+	usage := r.provider.GetUsage()
+	r.c.Stream(func(w io.Writer) bool {
+		converter.w = w
+		data, err := receiver.recv()
+		if err == nil {
+			converter.convert(data)
+			return true
+		}
+		if errors.Is(err, context.Canceled) {
+			return false
+		}
+		if !errors.Is(err, io.EOF) {
+			// 已经开始输出后无法修改状态码，以错误对象结束响应，不能伪装成正常结束
+			common.LogError(r.c.Request.Context(), "gemini stream error: "+err.Error())
+			converter.error(newGeminiError(http.StatusInternalServerError, err.Error()))
+			return false
+		}
+		converter.finish(usage)
+		return false
+	})
+
+	return nil
+}
+
+func (s *geminiStreamConverter) write(response any) {
+	data, _ := json.Marshal(response)
+	if s.sse {
+		fmt.Fprintf(s.w, "data: %s\r\n\r\n", data)
+	} else {
+		// 非 sse 模式下输出一个 JSON 数组
+		prefix := ",\r\n"
+		if s.count == 0 {
+			prefix = "["
+		}
+		fmt.Fprintf(s.w, "%s%s", prefix, data)
+	}
+	s.count++
+}
+
+func (s *geminiStreamConverter) convert(data string) {
+	var chunk types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil || len(chunk.Choices) == 0 {
+		return
+	}
+
+	choice := chunk.Choices[0]
+	if finishReason, ok := choice.FinishReason.(string); ok && finishReason != "" {
+		s.finishReason = finishReason
+	}
+
+	toolCalls := choice.Delta.ToolCalls
+	if choice.Delta.FunctionCall != nil {
+		toolCalls = append(toolCalls, &types.ChatCompletionToolCalls{
+			Function: choice.Delta.FunctionCall,
+		})
+	}
+	for _, toolCall := range toolCalls {
+		if toolCall.Function == nil {
+			continue
+		}
+		function, ok := s.toolCalls[toolCall.Index]
+		if !ok {
+			function = &types.ChatCompletionToolCallsFunction{}
+			s.toolCalls[toolCall.Index] = function
+			s.toolIndexes = append(s.toolIndexes, toolCall.Index)
+		}
+		function.Name += toolCall.Function.Name
+		function.Arguments += toolCall.Function.Arguments
+	}
+
+	if choice.Delta.Content == "" {
+		return
+	}
+
+	s.write(&types.GeminiResponse{
+		Candidates: []types.GeminiCandidate{
+			{
+				Content: types.GeminiContent{
+					Role:  types.GeminiRoleModel,
+					Parts: []types.GeminiPart{{Text: choice.Delta.Content}},
+				},
+			},
+		},
+	})
+}
+
+func (s *geminiStreamConverter) finish(usage *types.Usage) {
+	parts := make([]types.GeminiPart, 0, len(s.toolIndexes))
+	for _, index := range s.toolIndexes {
+		function := s.toolCalls[index]
+		parts = append(parts, convertGeminiFunctionCall(function.Name, function.Arguments))
+	}
+
+	finishReason := finishReasonOpenAI2Gemini(s.finishReason)
+	if finishReason == "" {
+		finishReason = types.GeminiFinishReasonStop
+	}
+
+	s.write(&types.GeminiResponse{
+		Candidates: []types.GeminiCandidate{
+			{
+				Content: types.GeminiContent{
+					Role:  types.GeminiRoleModel,
+					Parts: parts,
+				},
+				FinishReason: finishReason,
+			},
+		},
+		UsageMetadata: &types.GeminiUsageMetadata{
+			PromptTokenCount:     usage.PromptTokens,
+			CandidatesTokenCount: usage.CompletionTokens,
+			TotalTokenCount:      usage.PromptTokens + usage.CompletionTokens,
+		},
+	})
+
+	if !s.sse {
+		fmt.Fprint(s.w, "]")
+	}
+}
+
+func (s *geminiStreamConverter) error(response *types.GeminiErrorResponse) {
+	s.write(response)
+	if !s.sse {
+		fmt.Fprint(s.w, "]")
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"net/http"
 	"one-api/common"
+	"one-api/common/metrics"
+	"one-api/common/tracing"
 	"one-api/model"
 	"one-api/types"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func Relay(c *gin.Context) {
@@ -18,22 +22,31 @@ func Relay(c *gin.Context) {
 	}
 
 	if err := relay.setRequest(); err != nil {
-		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		abortWithRelayError(relay, common.ErrorWrapper(err, "invalid_request_error", http.StatusBadRequest))
+		return
+	}
+
+	if !model.IsModelAllowed(c.GetStringSlice("token_models"), relay.getOriginalModel()) {
+		abortWithRelayError(relay, common.StringErrorWrapper(fmt.Sprintf("该令牌无权使用模型：%s", relay.getOriginalModel()), "model_not_allowed", http.StatusForbidden))
+		return
+	}
+
+	if serveResponseCache(c, relay) {
 		return
 	}
 
 	if err := relay.setProvider(relay.getOriginalModel()); err != nil {
-		common.AbortWithMessage(c, http.StatusServiceUnavailable, err.Error())
+		abortWithRelayError(relay, common.ErrorWrapper(err, "channel_error", http.StatusServiceUnavailable))
 		return
 	}
 
 	apiErr, done := RelayHandler(relay)
-	if apiErr == nil {
+	if apiErr == nil || isClientCanceled(c) {
 		return
 	}
 
 	channel := relay.getProvider().GetChannel()
-	go processChannelRelayError(c.Request.Context(), channel.Id, channel.Name, apiErr)
+	go processChannelRelayError(c.Request.Context(), channel, apiErr)
 
 	retryTimes := common.RetryTimes
 	if done || !shouldRetry(c, apiErr.StatusCode) {
@@ -42,8 +55,14 @@ func Relay(c *gin.Context) {
 	}
 
 	for i := retryTimes; i > 0; i-- {
-		// 冻结通道
-		model.ChannelGroup.Cooldowns(channel.Id)
+		metrics.RecordRelayRetry(relay.getOriginalModel(), c.GetString("group"))
+		if common.CircuitBreakerEnabled {
+			// 熔断按 渠道+模型 统计，重试时只跳过本次请求失败过的渠道
+			c.Set("skip_channel_ids", append(getSkipChannelIds(c), channel.Id))
+		} else {
+			// 冻结通道
+			model.ChannelGroup.Cooldowns(channel.Id, c.GetString("group"))
+		}
 		if err := relay.setProvider(relay.getOriginalModel()); err != nil {
 			continue
 		}
@@ -51,36 +70,71 @@ func Relay(c *gin.Context) {
 		channel = relay.getProvider().GetChannel()
 		common.LogError(c.Request.Context(), fmt.Sprintf("using channel #%d(%s) to retry (remain times %d)", channel.Id, channel.Name, i))
 		apiErr, done = RelayHandler(relay)
-		if apiErr == nil {
+		if apiErr == nil || isClientCanceled(c) {
 			return
 		}
-		go processChannelRelayError(c.Request.Context(), channel.Id, channel.Name, apiErr)
+		go processChannelRelayError(c.Request.Context(), channel, apiErr)
 		if done || !shouldRetry(c, apiErr.StatusCode) {
 			break
 		}
 	}
 
 	if apiErr != nil {
-		requestId := c.GetString(common.RequestIdKey)
-		if apiErr.StatusCode == http.StatusTooManyRequests {
+		if apiErr.StatusCode == http.StatusTooManyRequests && apiErr.Code != "rate_limit_exceeded" {
 			apiErr.OpenAIError.Message = "当前分组上游负载已饱和，请稍后再试"
 		}
-		apiErr.OpenAIError.Message = common.MessageWithRequestId(apiErr.OpenAIError.Message, requestId)
-		c.JSON(apiErr.StatusCode, gin.H{
-			"error": apiErr.OpenAIError,
-		})
-
+		relay.responseError(apiErr)
 	}
 }
 
+func abortWithRelayError(relay RelayBaseInterface, err *types.OpenAIErrorWithStatusCode) {
+	c := relay.getContext()
+	common.LogError(c.Request.Context(), err.Message)
+	relay.responseError(err)
+	c.Abort()
+}
+
 func RelayHandler(relay RelayBaseInterface) (err *types.OpenAIErrorWithStatusCode, done bool) {
+	c := relay.getContext()
+	channel := relay.getProvider().GetChannel()
+	parentCtx := c.Request.Context()
+	ctx, span := tracing.Start(parentCtx, "relay.attempt",
+		attribute.String("model", relay.getOriginalModel()),
+		attribute.Int("channel.id", channel.Id),
+		attribute.Int("channel.type", channel.Type),
+	)
+	ctx, cancel := withStreamTimeout(c, ctx, channel)
+	c.Set(streamErrorKey, nil)
+	// 本次尝试的计费及上游请求都挂在该 span 下，上游请求头会携带对应的 traceparent
+	c.Request = c.Request.WithContext(ctx)
+	relay.getProvider().SetContext(c)
+	defer func() {
+		getStreamTimeout(c).stop()
+		cancel()
+		if err != nil {
+			span.SetAttributes(attribute.Int("http.status_code", err.StatusCode))
+			tracing.RecordError(span, &err.OpenAIError)
+		}
+		span.End()
+		c.Request = c.Request.WithContext(parentCtx)
+	}()
+
+	_, tokenSpan := tracing.Start(ctx, "relay.count_prompt_tokens")
 	promptTokens, tonkeErr := relay.getPromptTokens()
+	tokenSpan.SetAttributes(attribute.Int("prompt_tokens", promptTokens))
+	tracing.RecordError(tokenSpan, tonkeErr)
+	tokenSpan.End()
 	if tonkeErr != nil {
 		err = common.ErrorWrapper(tonkeErr, "token_error", http.StatusBadRequest)
 		done = true
 		return
 	}
 
+	if err = checkRateLimit(c, promptTokens); err != nil {
+		done = true
+		return
+	}
+
 	usage := &types.Usage{
 		PromptTokens: promptTokens,
 	}
@@ -94,13 +148,89 @@ func RelayHandler(relay RelayBaseInterface) (err *types.OpenAIErrorWithStatusCod
 		return
 	}
 
+	startTime := time.Now()
 	err, done = relay.send()
 
+	// 流式请求超时，首个数据块之前超时还没有向客户端输出，可以重试其他渠道
+	resultErr := err
+	if getStreamTimeout(c).isExceeded() {
+		if err != nil {
+			err = common.StringErrorWrapper("upstream first byte timeout", "first_byte_timeout", http.StatusGatewayTimeout)
+			done = false
+			resultErr = err
+		} else {
+			common.LogWarn(c.Request.Context(), fmt.Sprintf("channel #%d stream idle timeout, billing %d completion tokens", channel.Id, usage.CompletionTokens))
+			resultErr = common.StringErrorWrapper("upstream stream idle timeout", "stream_idle_timeout", http.StatusGatewayTimeout)
+		}
+	} else if streamErr := getStreamError(c); err == nil && streamErr != nil {
+		common.LogWarn(c.Request.Context(), fmt.Sprintf("channel #%d stream error, billing %d completion tokens: %s", channel.Id, usage.CompletionTokens, streamErr.Error()))
+		resultErr = common.ErrorWrapper(streamErr, "stream_error", http.StatusBadGateway)
+	}
+
+	if err != nil && isClientCanceled(relay.getContext()) {
+		// 客户端在上游返回前断开，上游请求已随之取消，不计入渠道失败
+		common.LogWarn(relay.getContext().Request.Context(), "client disconnected, upstream request canceled")
+		quotaInfo.undo(relay.getContext())
+		done = true
+		return
+	}
+	recordChannelResult(relay, startTime, resultErr)
+
 	if err != nil {
 		quotaInfo.undo(relay.getContext())
 		return
 	}
 
+	if isClientCanceled(relay.getContext()) {
+		common.LogWarn(relay.getContext().Request.Context(), fmt.Sprintf("client disconnected during stream, billing %d completion tokens", usage.CompletionTokens))
+	}
+
 	quotaInfo.consume(relay.getContext(), usage)
+	reconcileRateLimit(c, usage)
+	if !isClientCanceled(c) {
+		getRelayCache(c).save(usage)
+	}
 	return
 }
+
+// 记录渠道的请求结果，供监控指标、自适应负载均衡及熔断使用，客户端错误不计入渠道健康度
+func recordChannelResult(relay RelayBaseInterface, startTime time.Time, err *types.OpenAIErrorWithStatusCode) {
+	c := relay.getContext()
+	latency := time.Since(startTime)
+	var ttft time.Duration
+	if firstResponseTime := c.GetTime("first_response_time"); !firstResponseTime.IsZero() {
+		ttft = firstResponseTime.Sub(startTime)
+	}
+
+	channelId := relay.getProvider().GetChannel().Id
+	status := http.StatusOK
+	if err != nil {
+		status = err.StatusCode
+	}
+	metrics.RecordRelayRequest(relay.getOriginalModel(), channelId, c.GetString("group"), status, latency, ttft)
+
+	success := err == nil
+	if !success && !isChannelFailure(err) {
+		return
+	}
+
+	model.ChannelHealths.Record(channelId, success, latency, ttft)
+
+	if success {
+		model.ChannelCircuitBreakers.RecordSuccess(channelId, relay.getOriginalModel())
+	} else {
+		model.ChannelCircuitBreakers.RecordFailure(channelId, relay.getOriginalModel())
+	}
+}
+
+// isChannelFailure 上游故障、限流及鉴权失败计入渠道健康度，404 通常是客户端请求了不存在的对象，只有模型不存在时才计入
+func isChannelFailure(err *types.OpenAIErrorWithStatusCode) bool {
+	statusCode := err.StatusCode
+	if statusCode == http.StatusNotFound {
+		return err.Code == "model_not_found"
+	}
+	return statusCode >= http.StatusInternalServerError ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode == http.StatusUnauthorized ||
+		statusCode == http.StatusForbidden
+}
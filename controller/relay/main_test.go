@@ -0,0 +1,36 @@
+package relay
+
+import (
+	"net/http"
+	_ "one-api/common/test/init"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsChannelFailure(t *testing.T) {
+	cases := []struct {
+		statusCode int
+		code       any
+		failure    bool
+	}{
+		{http.StatusInternalServerError, nil, true},
+		{http.StatusBadGateway, nil, true},
+		{http.StatusTooManyRequests, "rate_limit_exceeded", true},
+		{http.StatusUnauthorized, "invalid_api_key", true},
+		{http.StatusForbidden, nil, true},
+		{http.StatusNotFound, "model_not_found", true},
+		// 用户请求的文件、任务等不存在，与渠道无关
+		{http.StatusNotFound, nil, false},
+		{http.StatusBadRequest, "context_length_exceeded", false},
+	}
+
+	for _, c := range cases {
+		err := &types.OpenAIErrorWithStatusCode{
+			OpenAIError: types.OpenAIError{Code: c.code},
+			StatusCode:  c.statusCode,
+		}
+		assert.Equal(t, c.failure, isChannelFailure(err), "status %d code %v", c.statusCode, c.code)
+	}
+}
@@ -0,0 +1,538 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/common/requester"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// relayMessages 兼容 Anthropic Messages API，请求转换为 OpenAI 格式后交给任意支持 chat 的渠道处理
+type relayMessages struct {
+	relayBase
+	messagesRequest types.MessagesRequest
+	chatRequest     types.ChatCompletionRequest
+}
+
+func NewRelayMessages(c *gin.Context) *relayMessages {
+	relay := &relayMessages{}
+	relay.c = c
+	return relay
+}
+
+func (r *relayMessages) setRequest() error {
+	if err := common.UnmarshalBodyReusable(r.c, &r.messagesRequest); err != nil {
+		return err
+	}
+
+	if r.messagesRequest.MaxTokens < 0 || r.messagesRequest.MaxTokens > math.MaxInt32/2 {
+		return errors.New("max_tokens is invalid")
+	}
+
+	if err := r.convertToChatRequest(); err != nil {
+		return err
+	}
+
+	r.originalModel = r.messagesRequest.Model
+
+	return nil
+}
+
+func (r *relayMessages) getPromptTokens() (int, error) {
+	return common.CountTokenMessages(r.chatRequest.Messages, r.modelName), nil
+}
+
+func (r *relayMessages) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
+	chatProvider, ok := r.provider.(providersBase.ChatInterface)
+	if !ok {
+		err = common.StringErrorWrapper("channel not implemented", "channel_error", http.StatusServiceUnavailable)
+		done = true
+		return
+	}
+
+	r.chatRequest.Model = r.modelName
+
+	if r.chatRequest.Stream {
+		var response requester.StreamReaderInterface[string]
+		startStreamTimeout(r.c)
+		response, err = chatProvider.CreateChatCompletionStream(&r.chatRequest)
+		if err != nil {
+			return
+		}
+
+		err = r.responseStream(response)
+		return
+	} else {
+		var response *types.ChatCompletionResponse
+		response, err = chatProvider.CreateChatCompletion(&r.chatRequest)
+		if err != nil {
+			return
+		}
+		err = responseJsonClient(r.c, r.convertToMessagesResponse(response))
+	}
+
+	if err != nil {
+		done = true
+	}
+
+	return
+}
+
+// Anthropic 格式的错误：{"type":"error","error":{"type":...,"message":...}}
+func (r *relayMessages) responseError(err *types.OpenAIErrorWithStatusCode) {
+	requestId := r.c.GetString(common.RequestIdKey)
+	r.c.JSON(err.StatusCode, &types.MessagesStreamEvent{
+		Type: "error",
+		Error: &types.MessagesError{
+			Type:    types.MessagesErrorType(err.StatusCode),
+			Message: common.MessageWithRequestId(err.Message, requestId),
+		},
+	})
+}
+
+func (r *relayMessages) convertToChatRequest() error {
+	request := &r.messagesRequest
+	r.chatRequest = types.ChatCompletionRequest{
+		Model:       request.Model,
+		Messages:    make([]types.ChatCompletionMessage, 0, len(request.Messages)+1),
+		MaxTokens:   request.MaxTokens,
+		Temperature: request.Temperature,
+		TopP:        request.TopP,
+		Stream:      request.Stream,
+		Stop:        request.StopSequences,
+	}
+
+	if request.Metadata != nil {
+		r.chatRequest.User = request.Metadata.UserId
+	}
+
+	if system := request.GetSystem(); system != "" {
+		r.chatRequest.Messages = append(r.chatRequest.Messages, types.ChatCompletionMessage{
+			Role:    types.ChatMessageRoleSystem,
+			Content: system,
+		})
+	}
+
+	for _, message := range request.Messages {
+		contents, err := message.ParseContent()
+		if err != nil {
+			return err
+		}
+
+		if message.Role == types.ChatMessageRoleAssistant {
+			r.chatRequest.Messages = append(r.chatRequest.Messages, convertMessagesAssistant(contents))
+			continue
+		}
+
+		// tool_result 需要拆分成独立的 tool 消息，放在同一轮的用户输入之前
+		parts := make([]any, 0, len(contents))
+		for _, content := range contents {
+			switch content.Type {
+			case types.MessagesContentTypeToolResult:
+				r.chatRequest.Messages = append(r.chatRequest.Messages, types.ChatCompletionMessage{
+					Role:       types.ChatMessageRoleTool,
+					Content:    convertMessagesToolResult(content.Content),
+					ToolCallID: content.ToolUseId,
+				})
+			case types.MessagesContentTypeText:
+				parts = append(parts, map[string]any{
+					"type": types.ContentTypeText,
+					"text": content.Text,
+				})
+			case types.MessagesContentTypeImage:
+				if content.Source == nil {
+					continue
+				}
+				url := content.Source.URL
+				if content.Source.Type == "base64" {
+					url = fmt.Sprintf("data:%s;base64,%s", content.Source.MediaType, content.Source.Data)
+				}
+				parts = append(parts, map[string]any{
+					"type": types.ContentTypeImageURL,
+					"image_url": map[string]any{
+						"url": url,
+					},
+				})
+			}
+		}
+
+		if len(parts) == 0 {
+			continue
+		}
+
+		chatMessage := types.ChatCompletionMessage{
+			Role:    types.ChatMessageRoleUser,
+			Content: parts,
+		}
+		// 只有文本时使用字符串，兼容不支持多模态的渠道
+		if len(parts) == 1 && parts[0].(map[string]any)["type"] == types.ContentTypeText {
+			chatMessage.Content = parts[0].(map[string]any)["text"]
+		}
+		r.chatRequest.Messages = append(r.chatRequest.Messages, chatMessage)
+	}
+
+	if len(request.Tools) > 0 {
+		r.chatRequest.Tools = make([]*types.ChatCompletionTool, 0, len(request.Tools))
+		for _, tool := range request.Tools {
+			r.chatRequest.Tools = append(r.chatRequest.Tools, &types.ChatCompletionTool{
+				Type: "function",
+				Function: types.ChatCompletionFunction{
+					Name:        tool.Name,
+					Description: tool.Description,
+					Parameters:  tool.InputSchema,
+				},
+			})
+		}
+
+		if request.ToolChoice != nil {
+			switch request.ToolChoice.Type {
+			case "auto":
+				r.chatRequest.ToolChoice = "auto"
+			case "any":
+				r.chatRequest.ToolChoice = "required"
+			case "tool":
+				r.chatRequest.ToolChoice = map[string]any{
+					"type": "function",
+					"function": map[string]any{
+						"name": request.ToolChoice.Name,
+					},
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func convertMessagesAssistant(contents []types.MessagesContent) types.ChatCompletionMessage {
+	message := types.ChatCompletionMessage{
+		Role: types.ChatMessageRoleAssistant,
+	}
+
+	var text string
+	for _, content := range contents {
+		switch content.Type {
+		case types.MessagesContentTypeText:
+			text += content.Text
+		case types.MessagesContentTypeToolUse:
+			arguments := string(content.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			message.ToolCalls = append(message.ToolCalls, &types.ChatCompletionToolCalls{
+				Id:   content.Id,
+				Type: "function",
+				Function: &types.ChatCompletionToolCallsFunction{
+					Name:      content.Name,
+					Arguments: arguments,
+				},
+				Index: len(message.ToolCalls),
+			})
+		}
+	}
+	message.Content = text
+
+	return message
+}
+
+// tool_result 的 content 可以是字符串或者内容块数组，只保留文本部分
+func convertMessagesToolResult(content any) string {
+	if content == nil {
+		return ""
+	}
+
+	contents, _ := types.MessagesMessage{Content: content}.ParseContent()
+	var text string
+	for _, item := range contents {
+		text += item.Text
+	}
+	return text
+}
+
+func (r *relayMessages) convertToMessagesResponse(response *types.ChatCompletionResponse) *types.MessagesResponse {
+	messagesResponse := &types.MessagesResponse{
+		Id:      response.ID,
+		Type:    "message",
+		Role:    types.ChatMessageRoleAssistant,
+		Content: make([]types.MessagesContent, 0),
+		Model:   r.originalModel,
+	}
+	if messagesResponse.Id == "" {
+		messagesResponse.Id = fmt.Sprintf("msg_%s", common.GetUUID())
+	}
+
+	if response.Usage != nil {
+		messagesResponse.Usage.InputTokens = response.Usage.PromptTokens
+		messagesResponse.Usage.OutputTokens = response.Usage.CompletionTokens
+	}
+
+	if len(response.Choices) == 0 {
+		return messagesResponse
+	}
+
+	choice := response.Choices[0]
+	if text := choice.Message.StringContent(); text != "" {
+		messagesResponse.Content = append(messagesResponse.Content, types.MessagesContent{
+			Type: types.MessagesContentTypeText,
+			Text: text,
+		})
+	}
+
+	toolCalls := choice.Message.ToolCalls
+	if choice.Message.FunctionCall != nil {
+		toolCalls = append(toolCalls, &types.ChatCompletionToolCalls{
+			Function: choice.Message.FunctionCall,
+		})
+	}
+	for _, toolCall := range toolCalls {
+		if toolCall.Function == nil {
+			continue
+		}
+		messagesResponse.Content = append(messagesResponse.Content, types.MessagesContent{
+			Type:  types.MessagesContentTypeToolUse,
+			Id:    convertMessagesToolUseId(toolCall.Id),
+			Name:  toolCall.Function.Name,
+			Input: convertMessagesToolInput(toolCall.Function.Arguments),
+		})
+	}
+
+	finishReason, _ := choice.FinishReason.(string)
+	stopReason := finishReasonOpenAI2Messages(finishReason, len(toolCalls) > 0)
+	messagesResponse.StopReason = &stopReason
+
+	return messagesResponse
+}
+
+func convertMessagesToolUseId(id string) string {
+	if id == "" {
+		return fmt.Sprintf("toolu_%s", common.GetUUID())
+	}
+	return id
+}
+
+func convertMessagesToolInput(arguments string) json.RawMessage {
+	if arguments == "" || !json.Valid([]byte(arguments)) {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+func finishReasonOpenAI2Messages(finishReason string, hasToolCalls bool) string {
+	if hasToolCalls {
+		return types.MessagesStopReasonToolUse
+	}
+
+	switch finishReason {
+	case types.FinishReasonLength:
+		return types.MessagesStopReasonMaxTokens
+	case types.FinishReasonToolCalls, types.FinishReasonFunctionCall:
+		return types.MessagesStopReasonToolUse
+	default:
+		return types.MessagesStopReasonEndTurn
+	}
+}
+
+// messagesStreamConverter 将 OpenAI 流式响应转换为 Anthropic SSE 事件
+type messagesStreamConverter struct {
+	w          io.Writer
+	id         string
+	model      string
+	blockIndex int
+	blockType  string
+	// openai tool_calls index => content block index
+	toolBlocks   map[int]int
+	finishReason string
+	hasToolCalls bool
+}
+
+func (r *relayMessages) responseStream(stream requester.StreamReaderInterface[string]) *types.OpenAIErrorWithStatusCode {
+	defer stream.Close()
+	receiver, errWithCode := receiveStream(r.c, stream)
+	if errWithCode != nil {
+		return errWithCode
+	}
+
+	requester.SetEventStreamHeaders(r.c)
+	converter := &messagesStreamConverter{
+		id:         fmt.Sprintf("msg_%s", common.GetUUID()),
+		model:      r.originalModel,
+		blockIndex: -1,
+		toolBlocks: make(map[int]int),
+	}
+	usage := r.provider.GetUsage()
+
+	started := false
+	r.c.Stream(func(w io.Writer) bool {
+		converter.w = w
+		if !started {
+			converter.messageStart(usage.PromptTokens)
+			started = true
+		}
+
+		data, err := receiver.recv()
+		if err == nil {
+			converter.convert(data)
+			return true
+		}
+		if errors.Is(err, context.Canceled) {
+			return false
+		}
+		if !errors.Is(err, io.EOF) {
+			converter.sendEvent(&types.MessagesStreamEvent{
+				Type: "error",
+				Error: &types.MessagesError{
+					Type:    "api_error",
+					Message: err.Error(),
+				},
+			})
+			return false
+		}
+
+		converter.messageStop(usage.CompletionTokens)
+		return false
+	})
+
+	return nil
+}
+
+func (s *messagesStreamConverter) sendEvent(event *types.MessagesStreamEvent) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
+
+func (s *messagesStreamConverter) messageStart(promptTokens int) {
+	s.sendEvent(&types.MessagesStreamEvent{
+		Type: "message_start",
+		Message: &types.MessagesResponse{
+			Id:      s.id,
+			Type:    "message",
+			Role:    types.ChatMessageRoleAssistant,
+			Content: make([]types.MessagesContent, 0),
+			Model:   s.model,
+			Usage: types.MessagesUsage{
+				InputTokens: promptTokens,
+			},
+		},
+	})
+}
+
+func (s *messagesStreamConverter) startBlock(blockType string, contentBlock any) {
+	s.stopBlock()
+	s.blockIndex++
+	s.blockType = blockType
+	index := s.blockIndex
+	s.sendEvent(&types.MessagesStreamEvent{
+		Type:         "content_block_start",
+		Index:        &index,
+		ContentBlock: contentBlock,
+	})
+}
+
+func (s *messagesStreamConverter) stopBlock() {
+	if s.blockType == "" {
+		return
+	}
+	index := s.blockIndex
+	s.sendEvent(&types.MessagesStreamEvent{
+		Type:  "content_block_stop",
+		Index: &index,
+	})
+	s.blockType = ""
+}
+
+func (s *messagesStreamConverter) sendDelta(index int, delta *types.MessagesStreamDelta) {
+	s.sendEvent(&types.MessagesStreamEvent{
+		Type:  "content_block_delta",
+		Index: &index,
+		Delta: delta,
+	})
+}
+
+func (s *messagesStreamConverter) convert(data string) {
+	var chunk types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil || len(chunk.Choices) == 0 {
+		return
+	}
+
+	choice := chunk.Choices[0]
+	if finishReason, ok := choice.FinishReason.(string); ok && finishReason != "" {
+		s.finishReason = finishReason
+	}
+
+	if choice.Delta.Content != "" {
+		if s.blockType != types.MessagesContentTypeText {
+			// text 字段必须存在，客户端会在此基础上拼接增量
+			s.startBlock(types.MessagesContentTypeText, map[string]any{
+				"type": types.MessagesContentTypeText,
+				"text": "",
+			})
+		}
+		s.sendDelta(s.blockIndex, &types.MessagesStreamDelta{
+			Type: "text_delta",
+			Text: choice.Delta.Content,
+		})
+	}
+
+	toolCalls := choice.Delta.ToolCalls
+	if choice.Delta.FunctionCall != nil {
+		toolCalls = append(toolCalls, &types.ChatCompletionToolCalls{
+			Function: choice.Delta.FunctionCall,
+		})
+	}
+
+	for _, toolCall := range toolCalls {
+		if toolCall.Function == nil {
+			continue
+		}
+
+		blockIndex, ok := s.toolBlocks[toolCall.Index]
+		if !ok {
+			if toolCall.Function.Name == "" {
+				continue
+			}
+			s.hasToolCalls = true
+			s.startBlock(types.MessagesContentTypeToolUse, &types.MessagesContent{
+				Type:  types.MessagesContentTypeToolUse,
+				Id:    convertMessagesToolUseId(toolCall.Id),
+				Name:  toolCall.Function.Name,
+				Input: json.RawMessage("{}"),
+			})
+			blockIndex = s.blockIndex
+			s.toolBlocks[toolCall.Index] = blockIndex
+		}
+
+		if toolCall.Function.Arguments != "" {
+			s.sendDelta(blockIndex, &types.MessagesStreamDelta{
+				Type:        "input_json_delta",
+				PartialJson: toolCall.Function.Arguments,
+			})
+		}
+	}
+}
+
+func (s *messagesStreamConverter) messageStop(completionTokens int) {
+	s.stopBlock()
+
+	stopReason := finishReasonOpenAI2Messages(s.finishReason, s.hasToolCalls)
+	s.sendEvent(&types.MessagesStreamEvent{
+		Type: "message_delta",
+		Delta: &types.MessagesStreamDelta{
+			StopReason: &stopReason,
+		},
+		Usage: &types.MessagesUsage{
+			OutputTokens: completionTokens,
+		},
+	})
+	s.sendEvent(&types.MessagesStreamEvent{
+		Type: "message_stop",
+	})
+}
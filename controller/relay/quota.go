@@ -7,16 +7,20 @@ import (
 	"math"
 	"net/http"
 	"one-api/common"
+	"one-api/common/metrics"
+	"one-api/common/notify"
+	"one-api/common/tracing"
 	"one-api/model"
 	"one-api/types"
-	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type QuotaInfo struct {
 	modelName         string
+	groupName         string
 	promptTokens      int
 	preConsumedTokens int
 	modelRatio        []float64
@@ -27,9 +31,16 @@ type QuotaInfo struct {
 	channelId         int
 	tokenId           int
 	HandelStatus      bool
+	cacheHit          bool   // 命中响应缓存，按 ResponseCacheRatio 折扣计费
+	modelLimitKey     string // 令牌的模型限额配置项，用于累计该模型的已用额度
+	budgetLimited     bool   // 令牌设置了周期预算，需要累计当前周期的已用额度
+	freeTier          *model.FreeTierCounter
 }
 
 func generateQuotaInfo(c *gin.Context, modelName string, promptTokens int) (*QuotaInfo, *types.OpenAIErrorWithStatusCode) {
+	_, span := tracing.Start(c.Request.Context(), "relay.generate_quota_info")
+	defer span.End()
+
 	quotaInfo := &QuotaInfo{
 		modelName:    modelName,
 		promptTokens: promptTokens,
@@ -42,8 +53,10 @@ func generateQuotaInfo(c *gin.Context, modelName string, promptTokens int) (*Quo
 
 	errWithCode := quotaInfo.preQuotaConsumption()
 	if errWithCode != nil {
+		tracing.RecordError(span, &errWithCode.OpenAIError)
 		return nil, errWithCode
 	}
+	span.SetAttributes(attribute.Int("pre_consumed_quota", quotaInfo.preConsumedQuota))
 
 	return quotaInfo, nil
 }
@@ -55,6 +68,7 @@ func (q *QuotaInfo) initQuotaInfo(groupName string) {
 	ratio := modelRatio[0] * groupRatio
 	preConsumedQuota := int(float64(q.promptTokens+preConsumedTokens) * ratio)
 
+	q.groupName = groupName
 	q.preConsumedTokens = preConsumedTokens
 	q.modelRatio = modelRatio
 	q.groupRatio = groupRatio
@@ -80,42 +94,39 @@ func (q *QuotaInfo) preQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 	if token.RemainQuota < q.preConsumedQuota && q.modelRatio[0] != 0 && !token.UnlimitedQuota {
 		return common.ErrorWrapper(errors.New("token is not enough"), "insufficient_token_quota", http.StatusForbidden)
 	}
-
-	if q.modelRatio[0] == 0 {
-		times, err := model.GetTimesByUserId(q.userId)
+	if limitKey, limit, ok := token.GetModelLimit(q.modelName); ok {
+		usedQuota, err := model.GetTokenModelUsedQuota(token.Id, limitKey)
 		if err != nil {
-			return common.ErrorWrapper(err, "get_Times_failed", http.StatusInternalServerError)
+			return common.ErrorWrapper(err, "get_token_model_quota_failed", http.StatusInternalServerError)
 		}
-		t := time.Unix(times.ChangeTime, 0)
-
-		// 创建中国时区的 Location
-		loc, _ := time.LoadLocation("Asia/Shanghai")
-
-		// 将 t 转换为中国时区的时间
-		t = t.In(loc)
-
-		// 获取今天的日期
-		now := time.Now().In(loc)
-		year, month, day := now.Date()
+		if usedQuota+q.preConsumedQuota > limit && q.modelRatio[0] != 0 {
+			return common.ErrorWrapper(fmt.Errorf("token quota for model %s is not enough", q.modelName), "insufficient_token_model_quota", http.StatusForbidden)
+		}
+		q.modelLimitKey = limitKey
+	}
+	if token.HasBudget() {
+		if err := model.ResetTokenBudget(token); err != nil {
+			return common.ErrorWrapper(err, "reset_token_budget_failed", http.StatusInternalServerError)
+		}
+		if token.GetBudgetUsedQuota()+q.preConsumedQuota > token.BudgetLimit && q.modelRatio[0] != 0 {
+			return common.ErrorWrapper(fmt.Errorf("token %s budget is not enough", token.BudgetPeriod), "insufficient_token_budget", http.StatusForbidden)
+		}
+		q.budgetLimited = true
+	}
 
-		// 比较时间是否在今天
-		tYear, tMonth, tDay := t.Date()
-		if !(year == tYear && month == tMonth && day == tDay) {
-			times.Times = 30
-		} else {
-			if times.Times <= 0 {
-				return common.ErrorWrapper(err, "free time is used out!", http.StatusForbidden)
-			}
+	if q.modelRatio[0] == 0 {
+		q.freeTier, err = model.ConsumeFreeTier(q.userId, q.groupName, q.modelName)
+		if errors.Is(err, model.ErrFreeTierExhausted) {
+			return common.ErrorWrapper(err, "free_tier_exhausted", http.StatusForbidden)
 		}
-		times.ChangeTime = common.GetTimestamp()
-		err = times.Update()
 		if err != nil {
-			return common.ErrorWrapper(err, "update_Times_failed", http.StatusInternalServerError)
+			return common.ErrorWrapper(err, "consume_free_tier_failed", http.StatusInternalServerError)
 		}
 	}
 
 	err = model.CacheDecreaseUserQuota(q.userId, q.preConsumedQuota)
 	if err != nil {
+		q.refundFreeTier()
 		return common.ErrorWrapper(err, "decrease_user_quota_failed", http.StatusInternalServerError)
 	}
 
@@ -143,7 +154,10 @@ func (q *QuotaInfo) completedQuotaConsumption(usage *types.Usage, tokenName stri
 	promptTokens := usage.PromptTokens
 	completionTokens := usage.CompletionTokens
 	quota = int(math.Ceil(((float64(promptTokens) * q.ratio) + (float64(completionTokens) * completionRatio))))
-	if q.ratio != 0 && quota <= 0 {
+	if q.cacheHit {
+		quota = int(math.Ceil(float64(quota) * common.ResponseCacheRatio))
+	}
+	if q.ratio != 0 && quota <= 0 && !q.cacheHit {
 		quota = 1
 	}
 	totalTokens := promptTokens + completionTokens
@@ -161,6 +175,21 @@ func (q *QuotaInfo) completedQuotaConsumption(usage *types.Usage, tokenName stri
 	if err != nil {
 		return errors.New("error consuming token remain quota: " + err.Error())
 	}
+	if q.modelLimitKey != "" && quota > 0 {
+		err = model.IncreaseTokenModelUsedQuota(q.tokenId, q.modelLimitKey, quota)
+		if err != nil {
+			return errors.New("error consuming token model quota: " + err.Error())
+		}
+	}
+	if q.budgetLimited && quota > 0 {
+		err = model.IncreaseTokenBudgetUsedQuota(q.tokenId, quota)
+		if err != nil {
+			return errors.New("error consuming token budget: " + err.Error())
+		}
+	}
+	if quota > 0 {
+		go notify.CheckQuotaAlerts(q.userId, q.tokenId)
+	}
 	if quota >= 0 {
 		requestTime := 0
 		requestStartTimeValue := ctx.Value("requestStartTime")
@@ -178,22 +207,13 @@ func (q *QuotaInfo) completedQuotaConsumption(usage *types.Usage, tokenName stri
 		}
 
 		logContent := fmt.Sprintf("模型倍率 %s", modelRatioStr)
+		if q.cacheHit {
+			logContent += fmt.Sprintf("，缓存命中（按 %.2f 倍计费）", common.ResponseCacheRatio)
+		}
 		model.RecordConsumeLog(ctx, q.userId, q.channelId, promptTokens, completionTokens, q.modelName, tokenName, quota, logContent, requestTime)
 		model.UpdateUserUsedQuotaAndRequestCount(q.userId, quota)
 		model.UpdateChannelUsedQuota(q.channelId, quota)
-
-		times, err := model.GetTimesByUserId(q.userId)
-		if err != nil {
-			return errors.New("error get times: " + err.Error())
-		}
-		if os.Getenv("ENABLE_LIMIT_FREE") == "true" {
-			times.Times = times.Times - 1
-		}
-
-		err = times.Update()
-		if err != nil {
-			return errors.New("error update times: " + err.Error())
-		}
+		metrics.RecordQuotaConsumed(q.modelName, q.groupName, quota, promptTokens, completionTokens)
 	}
 
 	return nil
@@ -201,6 +221,7 @@ func (q *QuotaInfo) completedQuotaConsumption(usage *types.Usage, tokenName stri
 
 func (q *QuotaInfo) undo(c *gin.Context) {
 	tokenId := c.GetInt("token_id")
+	q.refundFreeTier()
 	if q.HandelStatus {
 		go func(ctx context.Context) {
 			// return pre-consumed quota
@@ -212,13 +233,104 @@ func (q *QuotaInfo) undo(c *gin.Context) {
 	}
 }
 
+// 请求失败时退回占用的免费次数
+func (q *QuotaInfo) refundFreeTier() {
+	if q.freeTier == nil {
+		return
+	}
+	if err := q.freeTier.Refund(); err != nil {
+		common.SysError("error refund free tier: " + err.Error())
+	}
+	q.freeTier = nil
+}
+
 func (q *QuotaInfo) consume(c *gin.Context, usage *types.Usage) {
-	tokenName := c.GetString("token_name")
+	q.consumeAsync(c.Request.Context(), c.GetString("token_name"), usage)
+}
+
+// consumeAsync 异步结算，后台任务没有请求的上下文时直接调用
+func (q *QuotaInfo) consumeAsync(ctx context.Context, tokenName string, usage *types.Usage) {
 	// 如果没有报错，则消费配额
 	go func(ctx context.Context) {
+		ctx, span := tracing.Start(ctx, "relay.consume",
+			attribute.Int("prompt_tokens", usage.PromptTokens),
+			attribute.Int("completion_tokens", usage.CompletionTokens),
+		)
+		defer span.End()
+
 		err := q.completedQuotaConsumption(usage, tokenName, ctx)
 		if err != nil {
+			tracing.RecordError(span, err)
 			common.LogError(ctx, err.Error())
 		}
-	}(c.Request.Context())
+	}(ctx)
+}
+
+// 生成不预扣费的 QuotaInfo，用于 Assistants Runs 等异步任务结束后按实际用量结算
+func generatePostpaidQuotaInfo(group string, modelName string, userId, channelId, tokenId int) *QuotaInfo {
+	quotaInfo := &QuotaInfo{
+		modelName:    modelName,
+		userId:       userId,
+		channelId:    channelId,
+		tokenId:      tokenId,
+		HandelStatus: false,
+	}
+	quotaInfo.initQuotaInfo(group)
+	quotaInfo.preConsumedQuota = 0
+
+	// 结算时无法拒绝，但用量仍需计入令牌的模型限额及周期预算
+	if token, err := model.GetTokenById(tokenId); err == nil {
+		if limitKey, _, ok := token.GetModelLimit(modelName); ok {
+			quotaInfo.modelLimitKey = limitKey
+		}
+		if token.HasBudget() && model.ResetTokenBudget(token) == nil {
+			quotaInfo.budgetLimited = true
+		}
+	}
+
+	return quotaInfo
+}
+
+// 检查令牌能否使用该模型，以及该模型的限额是否已用完，用于不经过预扣费的接口
+func checkTokenModel(c *gin.Context, modelName string) *types.OpenAIErrorWithStatusCode {
+	if !model.IsModelAllowed(c.GetStringSlice("token_models"), modelName) {
+		return common.StringErrorWrapper(fmt.Sprintf("该令牌无权使用模型：%s", modelName), "model_not_allowed", http.StatusForbidden)
+	}
+
+	token, err := model.GetTokenById(c.GetInt("token_id"))
+	if err != nil {
+		return common.ErrorWrapper(err, "get_token_failed", http.StatusInternalServerError)
+	}
+	if limitKey, limit, ok := token.GetModelLimit(modelName); ok {
+		usedQuota, err := model.GetTokenModelUsedQuota(token.Id, limitKey)
+		if err != nil {
+			return common.ErrorWrapper(err, "get_token_model_quota_failed", http.StatusInternalServerError)
+		}
+		if usedQuota >= limit {
+			return common.ErrorWrapper(fmt.Errorf("token quota for model %s is not enough", modelName), "insufficient_token_model_quota", http.StatusForbidden)
+		}
+	}
+
+	return nil
+}
+
+// 检查用户及令牌是否还有可用额度，不做预扣费
+func checkUserQuota(c *gin.Context) *types.OpenAIErrorWithStatusCode {
+	userQuota, err := model.CacheGetUserQuota(c.GetInt("id"))
+	if err != nil {
+		return common.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
+	}
+	if userQuota <= 0 {
+		return common.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
+	}
+
+	token, err := model.GetTokenById(c.GetInt("token_id"))
+	if err != nil {
+		return common.ErrorWrapper(err, "get_token_failed", http.StatusInternalServerError)
+	}
+	if token.RemainQuota <= 0 && !token.UnlimitedQuota {
+		return common.ErrorWrapper(errors.New("token is not enough"), "insufficient_token_quota", http.StatusForbidden)
+	}
+
+	return nil
 }
@@ -0,0 +1,194 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/types"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	rateLimitWindow   = time.Minute
+	rateLimitRequests = "requests"
+	rateLimitTokens   = "tokens"
+)
+
+type rateLimitCounter struct {
+	kind    string
+	key     string
+	limit   int64
+	amount  int64
+	current int64
+	reset   time.Duration
+	checked bool
+	taken   bool
+}
+
+// relayRateLimit 本次请求计入的 RPM/TPM 计数，TPM 先按提示 token 数计入，响应结束后再按实际用量修正
+type relayRateLimit struct {
+	counters     []*rateLimitCounter
+	promptTokens int
+}
+
+// checkRateLimit 按用户（未单独设置时使用分组的默认配置）、令牌及整个分组分别限流，重试其他渠道时不重复计数
+func checkRateLimit(c *gin.Context, promptTokens int) *types.OpenAIErrorWithStatusCode {
+	if _, ok := c.Get("rate_limit"); ok {
+		return nil
+	}
+
+	userId := c.GetInt("id")
+	userLimit, err := model.CacheGetUserRateLimit(userId)
+	if err != nil {
+		return common.ErrorWrapper(err, "get_user_rate_limit_failed", http.StatusInternalServerError)
+	}
+	group := c.GetString("group")
+	groupLimit := common.GetGroupRateLimit(group)
+	if userLimit.RPM == 0 {
+		userLimit.RPM = groupLimit.RPM
+	}
+	if userLimit.TPM == 0 {
+		userLimit.TPM = groupLimit.TPM
+	}
+	tokenLimit, _ := c.Value("token_rate_limit").(common.RateLimit)
+
+	rateLimit := &relayRateLimit{promptTokens: promptTokens}
+	rateLimit.add(fmt.Sprintf("user:%d", userId), userLimit)
+	rateLimit.add(fmt.Sprintf("token:%d", c.GetInt("token_id")), tokenLimit)
+	rateLimit.add("group:"+group, common.RateLimit{RPM: groupLimit.TotalRPM, TPM: groupLimit.TotalTPM})
+	c.Set("rate_limit", rateLimit)
+
+	for _, counter := range rateLimit.counters {
+		ok, err := counter.take()
+		if err != nil {
+			// 计数失败时放行，避免 Redis 故障导致服务不可用
+			common.LogError(c.Request.Context(), "rate limit error: "+err.Error())
+			continue
+		}
+		if !ok {
+			rateLimit.rollback()
+			rateLimit.setHeaders(c)
+			c.Header("retry-after", strconv.Itoa(int(formatReset(counter.reset).Seconds())))
+			return counter.error()
+		}
+	}
+	rateLimit.setHeaders(c)
+
+	return nil
+}
+
+func (r *relayRateLimit) add(subject string, limit common.RateLimit) {
+	if limit.RPM > 0 {
+		r.counters = append(r.counters, newRateLimitCounter(rateLimitRequests, subject, limit.RPM, 1))
+	}
+	if limit.TPM > 0 {
+		r.counters = append(r.counters, newRateLimitCounter(rateLimitTokens, subject, limit.TPM, r.promptTokens))
+	}
+}
+
+func newRateLimitCounter(kind, subject string, limit, amount int) *rateLimitCounter {
+	key, reset := common.WindowKey(fmt.Sprintf("rateLimit:%s:%s", kind, subject), rateLimitWindow)
+	return &rateLimitCounter{
+		kind:   kind,
+		key:    key,
+		limit:  int64(limit),
+		amount: int64(amount),
+		reset:  reset,
+	}
+}
+
+func (r *rateLimitCounter) take() (bool, error) {
+	current, ok, err := common.WindowLimiterAllow(r.key, r.amount, r.limit, rateLimitWindow)
+	if err != nil {
+		return false, err
+	}
+	r.current = current
+	r.checked = true
+	r.taken = ok
+	return ok, nil
+}
+
+func (r *rateLimitCounter) remaining() int64 {
+	if r.current >= r.limit {
+		return 0
+	}
+	return r.limit - r.current
+}
+
+func (r *rateLimitCounter) error() *types.OpenAIErrorWithStatusCode {
+	unit := "requests per min (RPM)"
+	if r.kind == rateLimitTokens {
+		unit = "tokens per min (TPM)"
+	}
+	return &types.OpenAIErrorWithStatusCode{
+		OpenAIError: types.OpenAIError{
+			Message: fmt.Sprintf("Rate limit reached for %s: Limit %d, Used %d, Requested %d. Please try again in %s.",
+				unit, r.limit, r.current, r.amount, formatReset(r.reset)),
+			Type: r.kind,
+			Code: "rate_limit_exceeded",
+		},
+		StatusCode: http.StatusTooManyRequests,
+	}
+}
+
+// 拒绝请求时退回已计入其他限流的数量
+func (r *relayRateLimit) rollback() {
+	for _, counter := range r.counters {
+		if !counter.taken {
+			continue
+		}
+		if err := common.WindowLimiterAdd(counter.key, -counter.amount, rateLimitWindow); err != nil {
+			common.SysError("rollback rate limit error: " + err.Error())
+		}
+		counter.current -= counter.amount
+		counter.taken = false
+	}
+}
+
+// 按剩余最少的限流输出 OpenAI 格式的 x-ratelimit-* 响应头
+func (r *relayRateLimit) setHeaders(c *gin.Context) {
+	for _, kind := range []string{rateLimitRequests, rateLimitTokens} {
+		var strictest *rateLimitCounter
+		for _, counter := range r.counters {
+			if counter.kind == kind && counter.checked && (strictest == nil || counter.remaining() < strictest.remaining()) {
+				strictest = counter
+			}
+		}
+		if strictest == nil {
+			continue
+		}
+		c.Header("x-ratelimit-limit-"+kind, strconv.FormatInt(strictest.limit, 10))
+		c.Header("x-ratelimit-remaining-"+kind, strconv.FormatInt(strictest.remaining(), 10))
+		c.Header("x-ratelimit-reset-"+kind, formatReset(strictest.reset).String())
+	}
+}
+
+// reconcileRateLimit 响应结束后按实际的 token 数修正 TPM 计数
+func reconcileRateLimit(c *gin.Context, usage *types.Usage) {
+	rateLimit, ok := c.Value("rate_limit").(*relayRateLimit)
+	if !ok {
+		return
+	}
+
+	delta := int64(usage.PromptTokens + usage.CompletionTokens - rateLimit.promptTokens)
+	if delta == 0 {
+		return
+	}
+	for _, counter := range rateLimit.counters {
+		if counter.kind != rateLimitTokens || !counter.taken {
+			continue
+		}
+		if err := common.WindowLimiterAdd(counter.key, delta, rateLimitWindow); err != nil {
+			common.LogError(c.Request.Context(), "reconcile rate limit error: "+err.Error())
+		}
+	}
+}
+
+// 向上取整到秒
+func formatReset(reset time.Duration) time.Duration {
+	return (reset + time.Second - 1).Truncate(time.Second)
+}
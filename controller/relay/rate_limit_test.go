@@ -0,0 +1,60 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/test"
+	_ "one-api/common/test/init"
+	"one-api/model"
+	"one-api/types"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRateLimitRollback(t *testing.T) {
+	test.InitTestDB(t)
+	user := test.CreateTestUser(t, "ratelimit", 0)
+	assert.Nil(t, model.DB.Model(user).Update("rate_limit_rpm", 10).Error)
+
+	newContext := func() *gin.Context {
+		c, _ := test.GetContext("POST", "/v1/chat/completions", test.RequestJSONConfig(), nil)
+		c.Set("id", user.Id)
+		c.Set("token_id", 1)
+		c.Set("group", "default")
+		c.Set("token_rate_limit", common.RateLimit{TPM: 50})
+		return c
+	}
+	userKey, _ := common.WindowKey(fmt.Sprintf("rateLimit:requests:user:%d", user.Id), rateLimitWindow)
+	tokenKey, _ := common.WindowKey("rateLimit:tokens:token:1", rateLimitWindow)
+
+	// 令牌的 TPM 超限时，已计入的用户 RPM 需要退回
+	c := newContext()
+	errWithCode := checkRateLimit(c, 100)
+	assert.NotNil(t, errWithCode)
+	assert.Equal(t, http.StatusTooManyRequests, errWithCode.StatusCode)
+	assert.Equal(t, rateLimitTokens, errWithCode.Type)
+	assert.NotEmpty(t, c.Writer.Header().Get("retry-after"))
+
+	current, _ := common.WindowLimiterGet(userKey)
+	assert.Equal(t, int64(0), current)
+	current, _ = common.WindowLimiterGet(tokenKey)
+	assert.Equal(t, int64(0), current)
+
+	c = newContext()
+	assert.Nil(t, checkRateLimit(c, 20))
+	assert.Equal(t, "9", c.Writer.Header().Get("x-ratelimit-remaining-requests"))
+	assert.Equal(t, "30", c.Writer.Header().Get("x-ratelimit-remaining-tokens"))
+
+	// 同一请求重试其他渠道时不重复计数
+	assert.Nil(t, checkRateLimit(c, 20))
+	current, _ = common.WindowLimiterGet(userKey)
+	assert.Equal(t, int64(1), current)
+
+	// 按实际用量修正 TPM
+	reconcileRateLimit(c, &types.Usage{PromptTokens: 20, CompletionTokens: 10})
+	current, _ = common.WindowLimiterGet(tokenKey)
+	assert.Equal(t, int64(30), current)
+}
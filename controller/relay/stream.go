@@ -0,0 +1,176 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/requester"
+	"one-api/model"
+	"one-api/types"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	streamTimeoutKey = "stream_timeout"
+	streamErrorKey   = "stream_error"
+)
+
+var errStreamTimeout = errors.New("upstream stream timeout")
+
+// streamTimeout 流式请求的首字超时及数据块间的空闲超时，超时后取消本次上游请求
+type streamTimeout struct {
+	sync.Mutex
+	firstByte time.Duration
+	idle      time.Duration
+	cancel    context.CancelFunc
+	timer     *time.Timer
+	exceeded  bool
+}
+
+// withStreamTimeout 为本次尝试创建可取消的上下文，流式请求调用 startStreamTimeout 后才开始计时
+func withStreamTimeout(c *gin.Context, ctx context.Context, channel *model.Channel) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	c.Set(streamTimeoutKey, &streamTimeout{
+		firstByte: channel.GetFirstByteTimeout(),
+		idle:      channel.GetIdleTimeout(),
+		cancel:    cancel,
+	})
+	return ctx, cancel
+}
+
+func getStreamTimeout(c *gin.Context) *streamTimeout {
+	timeout, _ := c.Value(streamTimeoutKey).(*streamTimeout)
+	return timeout
+}
+
+// startStreamTimeout 在发起流式请求前调用，上游响应头的等待时间也计入首字超时
+func startStreamTimeout(c *gin.Context) {
+	timeout := getStreamTimeout(c)
+	if timeout == nil {
+		return
+	}
+	timeout.reset(timeout.firstByte)
+}
+
+func (t *streamTimeout) reset(d time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	if d > 0 && !t.exceeded {
+		t.timer = time.AfterFunc(d, t.expire)
+	}
+}
+
+func (t *streamTimeout) expire() {
+	t.Lock()
+	t.exceeded = true
+	t.Unlock()
+	t.cancel()
+}
+
+func (t *streamTimeout) stop() {
+	if t == nil {
+		return
+	}
+	t.reset(0)
+}
+
+func (t *streamTimeout) isExceeded() bool {
+	if t == nil {
+		return false
+	}
+	t.Lock()
+	defer t.Unlock()
+	return t.exceeded
+}
+
+// 收到数据块后重新开始空闲计时，首个数据块的时间用于统计首字时间
+func markStreamChunk(c *gin.Context) {
+	if _, ok := c.Get("first_response_time"); !ok {
+		c.Set("first_response_time", time.Now())
+	}
+	if timeout := getStreamTimeout(c); timeout != nil {
+		timeout.reset(timeout.idle)
+	}
+}
+
+type streamChunk struct {
+	data string
+	err  error
+}
+
+// streamReceiver 读取上游的流式响应，首个数据块会先缓存起来，确认上游可用后再写入响应头
+type streamReceiver struct {
+	c        *gin.Context
+	dataChan <-chan string
+	errChan  <-chan error
+	pending  *streamChunk
+	started  bool
+}
+
+// receiveStream 等待上游返回首个数据块，此前的错误及超时都还没有向客户端输出，可以切换渠道重试
+func receiveStream(c *gin.Context, stream requester.StreamReaderInterface[string]) (*streamReceiver, *types.OpenAIErrorWithStatusCode) {
+	dataChan, errChan := stream.Recv()
+	receiver := &streamReceiver{
+		c:        c,
+		dataChan: dataChan,
+		errChan:  errChan,
+	}
+
+	data, err := receiver.recv()
+	if err == nil || errors.Is(err, io.EOF) {
+		receiver.pending = &streamChunk{data: data, err: err}
+		receiver.started = true
+		return receiver, nil
+	}
+
+	var openAIError *types.OpenAIError
+	if errors.As(err, &openAIError) {
+		return nil, &types.OpenAIErrorWithStatusCode{
+			OpenAIError: *openAIError,
+			StatusCode:  http.StatusInternalServerError,
+		}
+	}
+	return nil, common.ErrorWrapper(err, "stream_error", http.StatusInternalServerError)
+}
+
+// recv 返回下一个数据块，流结束时返回 io.EOF，客户端断开时返回 context.Canceled
+func (r *streamReceiver) recv() (string, error) {
+	if r.pending != nil {
+		chunk := r.pending
+		r.pending = nil
+		return chunk.data, chunk.err
+	}
+
+	ctx := r.c.Request.Context()
+	select {
+	case <-ctx.Done():
+		if getStreamTimeout(r.c).isExceeded() {
+			return "", errStreamTimeout
+		}
+		return "", ctx.Err()
+	case data := <-r.dataChan:
+		markStreamChunk(r.c)
+		return data, nil
+	case err := <-r.errChan:
+		if r.started && !errors.Is(err, io.EOF) {
+			// 已经开始输出，错误只能写入流中，记录下来供渠道健康度统计
+			r.c.Set(streamErrorKey, err)
+		}
+		return "", err
+	}
+}
+
+// getStreamError 本次尝试开始输出后上游返回的错误，此时请求已完成，按已生成的内容计费
+func getStreamError(c *gin.Context) error {
+	err, _ := c.Value(streamErrorKey).(error)
+	return err
+}
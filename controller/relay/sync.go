@@ -0,0 +1,154 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/providers"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	assistantRunSyncInterval = time.Minute
+	// 超过该时间仍未结束的 run 不再查询
+	assistantRunMaxAge = 7 * 24 * time.Hour
+	assistantRunBatch  = 100
+
+	fineTuningJobSyncInterval = time.Minute
+	fineTuningJobMaxAge       = 30 * 24 * time.Hour
+	fineTuningJobBatch        = 100
+)
+
+var assistantRunTerminalStatus = []string{"completed", "failed", "cancelled", "expired", "incomplete"}
+
+// SyncAssistantRuns 定时查询未计费的 run，客户端不再轮询时也能在结束后计费
+func SyncAssistantRuns() {
+	for {
+		time.Sleep(assistantRunSyncInterval)
+		syncAssistantRuns()
+	}
+}
+
+func syncAssistantRuns() {
+	afterId := 0
+	for {
+		mappings, err := model.GetUnbilledAssistantRuns(assistantRunMaxAge, afterId, assistantRunBatch)
+		if err != nil {
+			common.SysError("failed to fetch unbilled runs: " + err.Error())
+			return
+		}
+		for _, mapping := range mappings {
+			afterId = mapping.Id
+			if err := syncAssistantRun(mapping); err != nil {
+				common.SysError(fmt.Sprintf("failed to sync run %s: %s", mapping.ObjectId, err.Error()))
+			}
+		}
+		if len(mappings) < assistantRunBatch {
+			return
+		}
+	}
+}
+
+func syncAssistantRun(mapping *model.AssistantMapping) error {
+	uri := fmt.Sprintf("/v1/threads/%s/runs/%s", mapping.ThreadId, mapping.ObjectId)
+	c, err := newProviderContext(http.MethodGet, uri)
+	if err != nil {
+		return err
+	}
+	c.Set("channel_id", mapping.ChannelId)
+
+	channel, err := fetchChannelByKey(mapping.ChannelId, mapping.KeyId)
+	if err != nil {
+		return err
+	}
+	provider, ok := providers.GetProvider(channel, c).(providersBase.AssistantsInterface)
+	if !ok {
+		return fmt.Errorf("channel %d not implemented", channel.Id)
+	}
+
+	resp, errWithCode := provider.SendAssistantsRequest(http.MethodGet, uri, nil)
+	if errWithCode != nil {
+		return errors.New(errWithCode.Message)
+	}
+	defer resp.Body.Close()
+
+	run := &types.Run{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(run); err != nil {
+		return err
+	}
+	if run.ID != mapping.ObjectId || !slices.Contains(assistantRunTerminalStatus, run.Status) {
+		return nil
+	}
+
+	// 已结束但没有 usage 的 run 同样标记为已计费，不再查询
+	billAssistantRun(c.Request.Context(), mapping, run)
+	return nil
+}
+
+// newProviderContext 后台任务创建 provider 使用的上下文，provider 只读取请求及 Keys，不会写入响应
+func newProviderContext(method, uri string) (*gin.Context, error) {
+	req, err := http.NewRequestWithContext(context.Background(), method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &gin.Context{Request: req}, nil
+}
+
+// SyncFineTuningJobs 定时同步未结束的微调任务，客户端不再查询时也能在任务成功后注册微调模型
+func SyncFineTuningJobs() {
+	for {
+		time.Sleep(fineTuningJobSyncInterval)
+		syncFineTuningJobs()
+	}
+}
+
+func syncFineTuningJobs() {
+	afterId := 0
+	for {
+		jobs, err := model.GetUnfinishedFineTuningJobs(fineTuningJobMaxAge, afterId, fineTuningJobBatch)
+		if err != nil {
+			common.SysError("failed to fetch unfinished fine-tuning jobs: " + err.Error())
+			return
+		}
+		for _, jobMapping := range jobs {
+			afterId = jobMapping.Id
+			if err := syncFineTuningJobStatus(jobMapping); err != nil {
+				common.SysError(fmt.Sprintf("failed to sync fine-tuning job %s: %s", jobMapping.JobId, err.Error()))
+			}
+		}
+		if len(jobs) < fineTuningJobBatch {
+			return
+		}
+	}
+}
+
+func syncFineTuningJobStatus(jobMapping *model.FineTuningJob) error {
+	c, err := newProviderContext(http.MethodGet, "/v1/fine_tuning/jobs/"+jobMapping.JobId)
+	if err != nil {
+		return err
+	}
+	channel, err := fetchChannelByKey(jobMapping.ChannelId, jobMapping.KeyId)
+	if err != nil {
+		return err
+	}
+	provider, errWithCode := getFineTuningProvider(c, channel)
+	if errWithCode != nil {
+		return errors.New(errWithCode.Message)
+	}
+	job, errWithCode := provider.RetrieveFineTuningJob(jobMapping.JobId)
+	if errWithCode != nil {
+		return errors.New(errWithCode.Message)
+	}
+	syncFineTuningJob(c.Request.Context(), jobMapping, job)
+	return nil
+}
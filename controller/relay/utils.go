@@ -40,6 +40,10 @@ func Path2Relay(c *gin.Context, path string) RelayBaseInterface {
 		return NewRelayTranscriptions(c)
 	} else if strings.HasPrefix(path, "/v1/audio/translations") {
 		return NewRelayTranslations(c)
+	} else if strings.HasPrefix(path, "/v1/messages") {
+		return NewRelayMessages(c)
+	} else if strings.HasPrefix(path, "/v1beta/models") {
+		return NewRelayGemini(c)
 	}
 
 	return nil
@@ -88,9 +92,25 @@ func fetchChannelById(channelId int) (*model.Channel, error) {
 	return channel, nil
 }
 
+// objectChannel 文件、assistant 等对象所在的渠道及 key
+type objectChannel struct {
+	channelId int
+	keyId     int
+}
+
+// fetchChannelByKey 获取对象所在的渠道，多 key 渠道固定使用创建对象时的 key
+func fetchChannelByKey(channelId, keyId int) (*model.Channel, error) {
+	channel, err := fetchChannelById(channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	return channel.PinKey(keyId)
+}
+
 func fetchChannelByModel(c *gin.Context, modelName string) (*model.Channel, error) {
 	group := c.GetString("group")
-	channel, err := model.ChannelGroup.Next(group, modelName)
+	channel, err := model.ChannelGroup.Next(group, modelName, getSkipChannelIds(c)...)
 	if err != nil {
 		message := fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", group, modelName)
 		if channel != nil {
@@ -103,6 +123,11 @@ func fetchChannelByModel(c *gin.Context, modelName string) (*model.Channel, erro
 	return channel, nil
 }
 
+func getSkipChannelIds(c *gin.Context) []int {
+	channelIds, _ := c.Value("skip_channel_ids").([]int)
+	return channelIds
+}
+
 func responseJsonClient(c *gin.Context, data interface{}) *types.OpenAIErrorWithStatusCode {
 	// 将data转换为 JSON
 	responseBody, err := json.Marshal(data)
@@ -121,28 +146,44 @@ func responseJsonClient(c *gin.Context, data interface{}) *types.OpenAIErrorWith
 }
 
 func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface[string]) *types.OpenAIErrorWithStatusCode {
-	requester.SetEventStreamHeaders(c)
-	dataChan, errChan := stream.Recv()
-
 	defer stream.Close()
+	receiver, errWithCode := receiveStream(c, stream)
+	if errWithCode != nil {
+		return errWithCode
+	}
+
+	requester.SetEventStreamHeaders(c)
+	cache := getRelayCache(c)
 	c.Stream(func(w io.Writer) bool {
-		select {
-		case data := <-dataChan:
+		data, err := receiver.recv()
+		if err == nil {
+			cache.collect(data)
 			fmt.Fprintln(w, "data: "+data+"\n")
 			return true
-		case err := <-errChan:
-			if !errors.Is(err, io.EOF) {
-				fmt.Fprintln(w, "data: "+err.Error()+"\n")
-			}
+		}
 
-			fmt.Fprintln(w, "data: [DONE]")
+		if errors.Is(err, context.Canceled) {
+			// 客户端已断开，停止读取上游，已生成的内容照常计费
 			return false
 		}
+		if !errors.Is(err, io.EOF) {
+			fmt.Fprintln(w, "data: "+err.Error()+"\n")
+		} else {
+			cache.streamDone()
+		}
+
+		fmt.Fprintln(w, "data: [DONE]")
+		return false
 	})
 
 	return nil
 }
 
+// 客户端断开连接时请求上下文会被取消，流式请求超时主动取消的不算在内
+func isClientCanceled(c *gin.Context) bool {
+	return errors.Is(c.Request.Context().Err(), context.Canceled) && !getStreamTimeout(c).isExceeded()
+}
+
 func responseMultipart(c *gin.Context, resp *http.Response) *types.OpenAIErrorWithStatusCode {
 	defer resp.Body.Close()
 
@@ -194,9 +235,16 @@ func shouldRetry(c *gin.Context, statusCode int) bool {
 	return true
 }
 
-func processChannelRelayError(ctx context.Context, channelId int, channelName string, err *types.OpenAIErrorWithStatusCode) {
-	common.LogError(ctx, fmt.Sprintf("relay error (channel #%d(%s)): %s", channelId, channelName, err.Message))
-	if controller.ShouldDisableChannel(&err.OpenAIError, err.StatusCode) {
-		controller.DisableChannel(channelId, channelName, err.Message)
+func processChannelRelayError(ctx context.Context, channel *model.Channel, err *types.OpenAIErrorWithStatusCode) {
+	common.LogError(ctx, fmt.Sprintf("relay error (channel #%d(%s)): %s", channel.Id, channel.Name, err.Message))
+	// key 失效时禁用，其余的 5xx、429 等错误开启熔断时由熔断器暂停渠道，恢复后自动放行
+	if !controller.ShouldDisableChannel(&err.OpenAIError, err.StatusCode) {
+		return
+	}
+	// 多 key 渠道只禁用出错的 key
+	if channel.KeyId > 0 {
+		controller.DisableChannelKey(channel, err.Message)
+		return
 	}
+	controller.DisableChannel(channel.Id, channel.Name, err.Message)
 }
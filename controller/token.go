@@ -47,6 +47,14 @@ func GetToken(c *gin.Context) {
 		})
 		return
 	}
+	token.ModelUsages, err = model.GetTokenModelUsages(token.Id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -95,16 +103,33 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
-	cleanToken := model.Token{
-		UserId:         c.GetInt("id"),
-		Name:           token.Name,
-		Key:            common.GenerateKey(),
-		CreatedTime:    common.GetTimestamp(),
-		AccessedTime:   common.GetTimestamp(),
-		ExpiredTime:    token.ExpiredTime,
-		RemainQuota:    token.RemainQuota,
-		UnlimitedQuota: token.UnlimitedQuota,
+	if err := token.ValidateRestrictions(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
 	}
+	cleanToken := model.Token{
+		UserId:           c.GetInt("id"),
+		Name:             token.Name,
+		Key:              common.GenerateKey(),
+		CreatedTime:      common.GetTimestamp(),
+		AccessedTime:     common.GetTimestamp(),
+		ExpiredTime:      token.ExpiredTime,
+		RemainQuota:      token.RemainQuota,
+		UnlimitedQuota:   token.UnlimitedQuota,
+		ResponseCache:    token.ResponseCache,
+		Models:           token.Models,
+		AllowIps:         token.AllowIps,
+		ModelLimits:      token.ModelLimits,
+		RateLimitRPM:     token.RateLimitRPM,
+		RateLimitTPM:     token.RateLimitTPM,
+		ConcurrencyLimit: token.ConcurrencyLimit,
+		AlertPercent:     token.AlertPercent,
+		AlertQuota:       token.AlertQuota,
+	}
+	cleanToken.SetBudget(token.BudgetPeriod, token.BudgetLimit)
 	err = cleanToken.Insert()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -155,6 +180,13 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if err := token.ValidateRestrictions(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -187,6 +219,16 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.ExpiredTime = token.ExpiredTime
 		cleanToken.RemainQuota = token.RemainQuota
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
+		cleanToken.ResponseCache = token.ResponseCache
+		cleanToken.Models = token.Models
+		cleanToken.AllowIps = token.AllowIps
+		cleanToken.ModelLimits = token.ModelLimits
+		cleanToken.RateLimitRPM = token.RateLimitRPM
+		cleanToken.RateLimitTPM = token.RateLimitTPM
+		cleanToken.ConcurrencyLimit = token.ConcurrencyLimit
+		cleanToken.SetBudget(token.BudgetPeriod, token.BudgetLimit)
+		cleanToken.AlertPercent = token.AlertPercent
+		cleanToken.AlertQuota = token.AlertQuota
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -2,11 +2,13 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"one-api/common"
+	"one-api/common/notify"
 	"one-api/model"
-	"os"
+	"one-api/payment"
 	"strconv"
 	"time"
 
@@ -190,18 +192,6 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	cleanTimes := model.FreeTimes{
-		UserId:     id,
-		ChangeTime: common.GetTimestamp(),
-		Times:      30,
-	}
-	if err := cleanTimes.Insert(); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"message": "can't create default times for new user",
-		})
-		return
-	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -345,6 +335,12 @@ func GetAffCode(c *gin.Context) {
 	})
 }
 
+// SelfResponse 当前用户的信息，附带免费模型在当前窗口的剩余次数
+type SelfResponse struct {
+	*model.User
+	FreeTier []*model.FreeTierUsage `json:"free_tier"`
+}
+
 func GetSelf(c *gin.Context) {
 	id := c.GetInt("id")
 	user, err := model.GetUserById(id, false)
@@ -355,10 +351,18 @@ func GetSelf(c *gin.Context) {
 		})
 		return
 	}
+	freeTier, err := model.GetUserFreeTierUsages(id, user.Group)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
-		"data":    user,
+		"data":    SelfResponse{User: user, FreeTier: freeTier},
 	})
 }
 
@@ -409,6 +413,9 @@ func UpdateUser(c *gin.Context) {
 		updatedUser.Password = "" // rollback to what it should be
 	}
 	updatePassword := updatedUser.Password != ""
+	// 临时分组由兑换码等流程维护，不随用户信息一起修改
+	updatedUser.BaseGroup = ""
+	updatedUser.GroupExpireTime = 0
 	if err := updatedUser.Update(updatePassword); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -416,6 +423,12 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
+	if originUser.GroupExpireTime > 0 && updatedUser.Group != "" && updatedUser.Group != originUser.Group {
+		// 管理员手动调整分组后不再到期恢复
+		if err := model.UpdateUser(originUser.Id, map[string]interface{}{"base_group": "", "group_expire_time": 0}); err != nil {
+			common.SysError("failed to clear user group expiry: " + err.Error())
+		}
+	}
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(updatedUser.Quota)))
 	}
@@ -471,6 +484,43 @@ func UpdateSelf(c *gin.Context) {
 	})
 }
 
+type notifySettingRequest struct {
+	AlertQuota    int    `json:"alert_quota"`
+	NotifyWebhook string `json:"notify_webhook"`
+}
+
+// UpdateSelfNotify 修改额度提醒的设置，单独更新以便清空 webhook 或恢复默认阈值
+func UpdateSelfNotify(c *gin.Context) {
+	var req notifySettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+	if req.AlertQuota < -1 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("提醒阈值不能小于 -1"))
+		return
+	}
+	if req.NotifyWebhook != "" {
+		if err := notify.ValidateWebhook(req.NotifyWebhook); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+	}
+
+	err := model.UpdateUser(c.GetInt("id"), map[string]interface{}{
+		"alert_quota":    req.AlertQuota,
+		"notify_webhook": req.NotifyWebhook,
+	})
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
 func DeleteUser(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -768,24 +818,28 @@ func TopUp(c *gin.Context) {
 }
 
 type RechargeRequest struct {
-	Amount int    `json:"amount"`
-	Type   string `json:"type"`
+	Amount  int    `json:"amount"`
+	Type    string `json:"type"`
+	Gateway string `json:"gateway"`
 }
 
 func Recharge(c *gin.Context) {
 	req := RechargeRequest{}
-	err1 := c.ShouldBindJSON(&req)
+	err := c.ShouldBindJSON(&req)
 	id := c.GetInt("id")
-	if err1 != nil {
-		model.RecordLog(id, model.LogTypeTopup, err1.Error())
+	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
-			"message": err1.Error(),
+			"message": err.Error(),
 		})
 		return
 	}
+	// 兼容旧版本前端，未指定网关时使用易支付
+	if req.Gateway == "" {
+		req.Gateway = "epay"
+	}
 
-	payUrl, _, err := model.InitRecharge(req.Amount, req.Type, id)
+	result, tradeNo, err := payment.CreateOrder(req.Gateway, req.Type, req.Amount, id, c.ClientIP())
 	if err != nil {
 		model.RecordLog(id, model.LogTypeTopup, err.Error())
 		c.JSON(http.StatusOK, gin.H{
@@ -794,41 +848,35 @@ func Recharge(c *gin.Context) {
 		})
 		return
 	}
+	payUrl := result.PayURL
+	if payUrl == "" {
+		payUrl = result.QRCode
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "",
+		"payurl":   payUrl,
+		"trade_no": tradeNo,
+		"data":     result,
+	})
+}
+
+// GetPaymentGateways 已启用的支付网关及充值金额限制
+func GetPaymentGateways(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
-		"payurl":  payUrl,
+		"data": gin.H{
+			"gateways":      payment.GetEnabledGateways(),
+			"exchange_rate": common.PaymentExchangeRate,
+			"min_amount":    common.PaymentMinAmount,
+			"max_amount":    common.PaymentMaxAmount,
+		},
 	})
-	return
 }
 
+// RechargeNotify 兼容旧版本的易支付回调地址，新订单使用 /api/payment/epay/notify
 func RechargeNotify(c *gin.Context) {
-	// 使用 Query 方法获取查询参数
-	params := map[string]string{
-		"pid":          c.Query("pid"),          // 商户ID
-		"trade_no":     c.Query("trade_no"),     // 易支付订单号
-		"out_trade_no": c.Query("out_trade_no"), // 商户订单号
-		"type":         c.Query("type"),         // 支付方式
-		"name":         c.Query("name"),         // 商品名称
-		"money":        c.Query("money"),        // 商品金额
-		"trade_status": c.Query("trade_status"), // 支付状态
-		"param":        c.Query("param"),        // 业务扩展参数，可选
-		"sign":         c.Query("sign"),         // 签名字符串
-		"sign_type":    c.Query("sign_type"),    // 签名类型
-	}
-	key := os.Getenv("YI_PAY_KEY")
-	mySignature := model.GenerateSignature(params, key)
-	if c.Query("sign") != mySignature {
-		common.LogInfo(c.Request.Context(), fmt.Sprintf("收到未验证签名的支付异步通知:pid=%s,out_trade_no=%s", c.Query("pid"), c.Query("out_trade_no")))
-	} else {
-		c.String(200, "success")
-		if c.Query("trade_status") == "TRADE_SUCCESS" {
-			err, _ := model.CompeleteRecharge(c.Query("out_trade_no"))
-			if err != nil {
-				common.LogInfo(c.Request.Context(), fmt.Sprintf("数据库添加余额失败！:pid=%s,out_trade_no=%s", c.Query("pid"), c.Query("out_trade_no")))
-			}
-		} else {
-			common.LogInfo(c.Request.Context(), "签名验真失败")
-		}
-	}
+	gateway, _ := payment.GetGateway("epay")
+	gateway.HandleNotify(c)
 }
@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
+	"net/http"
 	"one-api/common"
 	"one-api/common/telegram"
+	"one-api/common/tracing"
 	"one-api/controller"
+	"one-api/controller/relay"
 	"one-api/middleware"
 	"one-api/model"
+	"one-api/payment"
 	"one-api/router"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
@@ -84,12 +94,32 @@ func main() {
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
 		model.InitBatchUpdater()
 	}
+	if common.IsMasterNode {
+		go payment.SyncStaleOrders()
+		go model.SyncUserGroupExpiry()
+		go model.SyncSubscriptions()
+		go model.SyncTokenBudgets()
+		go relay.SyncAssistantRuns()
+		go relay.SyncFineTuningJobs()
+	}
 	common.InitTokenEncoders()
 	// Initialize Telegram bot
 	telegram.InitTelegramBot()
+	// Initialize tracing
+	tracing.InitTracer()
 
 	// Initialize HTTP server
 	server := gin.New()
+	// 默认不信任任何代理，ClientIP 直接取连接的地址，部署在反向代理之后时需配置 TRUSTED_PROXIES（逗号分隔的 IP 或 CIDR）
+	var trustedProxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if err := server.SetTrustedProxies(trustedProxies); err != nil {
+		common.FatalLog("failed to set trusted proxies: " + err.Error())
+	}
 	server.Use(gin.Recovery())
 	// This will cause SSE not to work!!!
 	//server.Use(gzip.Gzip(gzip.DefaultCompression))
@@ -104,8 +134,25 @@ func main() {
 	if port == "" {
 		port = strconv.Itoa(*common.Port)
 	}
-	err = server.Run(":" + port)
-	if err != nil {
-		common.FatalLog("failed to start HTTP server: " + err.Error())
+	httpServer := &http.Server{
+		Addr:    ":" + port,
+		Handler: server,
+	}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			common.FatalLog("failed to start HTTP server: " + err.Error())
+		}
+	}()
+
+	// 收到退出信号后停止接收新请求，等待进行中的请求结束，再上报剩余的链路数据
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	common.SysLog("shutting down HTTP server")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(common.GetOrDefault("SHUTDOWN_TIMEOUT", 30))*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		common.SysError("failed to shutdown HTTP server: " + err.Error())
 	}
+	tracing.Shutdown()
 }
@@ -86,40 +86,69 @@ func RootAuth() func(c *gin.Context) {
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		key := c.Request.Header.Get("Authorization")
-		key = strings.TrimPrefix(key, "Bearer ")
-		key = strings.TrimPrefix(key, "sk-")
-		parts := strings.Split(key, "-")
-		key = parts[0]
-		token, err := model.ValidateUserToken(key)
-		if err != nil {
-			abortWithMessage(c, http.StatusUnauthorized, err.Error())
-			return
+		// 兼容 Anthropic 客户端使用的 x-api-key
+		if key == "" {
+			key = c.Request.Header.Get("x-api-key")
 		}
-		userEnabled, err := model.CacheIsUserEnabled(token.UserId)
-		if err != nil {
-			abortWithMessage(c, http.StatusInternalServerError, err.Error())
-			return
+		tokenAuth(c, key)
+	}
+}
+
+// GeminiAuth 兼容 Gemini SDK，令牌可以通过 ?key= 或 x-goog-api-key 传递
+func GeminiAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		key := c.Query("key")
+		if key == "" {
+			key = c.Request.Header.Get("x-goog-api-key")
 		}
-		if !userEnabled {
-			abortWithMessage(c, http.StatusForbidden, "用户已被封禁")
-			return
+		if key == "" {
+			key = c.Request.Header.Get("Authorization")
 		}
-		c.Set("id", token.UserId)
-		c.Set("token_id", token.Id)
-		c.Set("token_name", token.Name)
-		if len(parts) > 1 {
-			if model.IsAdmin(token.UserId) {
-				channelId := common.String2Int(parts[1])
-				if channelId == 0 {
-					abortWithMessage(c, http.StatusForbidden, "无效的渠道 Id")
-					return
-				}
-				c.Set("specific_channel_id", channelId)
-			} else {
-				abortWithMessage(c, http.StatusForbidden, "普通用户不支持指定渠道")
+		tokenAuth(c, key)
+	}
+}
+
+func tokenAuth(c *gin.Context, key string) {
+	key = strings.TrimPrefix(key, "Bearer ")
+	key = strings.TrimPrefix(key, "sk-")
+	parts := strings.Split(key, "-")
+	key = parts[0]
+	token, err := model.ValidateUserToken(key)
+	if err != nil {
+		abortWithMessage(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !token.IsIpAllowed(c.ClientIP()) {
+		abortWithMessage(c, http.StatusForbidden, "该令牌不允许从当前 IP 访问")
+		return
+	}
+	userEnabled, err := model.CacheIsUserEnabled(token.UserId)
+	if err != nil {
+		abortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !userEnabled {
+		abortWithMessage(c, http.StatusForbidden, "用户已被封禁")
+		return
+	}
+	c.Set("id", token.UserId)
+	c.Set("token_id", token.Id)
+	c.Set("token_name", token.Name)
+	c.Set("token_response_cache", token.ResponseCache)
+	c.Set("token_models", token.GetModels())
+	c.Set("token_rate_limit", common.RateLimit{RPM: token.RateLimitRPM, TPM: token.RateLimitTPM, Concurrency: token.ConcurrencyLimit})
+	if len(parts) > 1 {
+		if model.IsAdmin(token.UserId) {
+			channelId := common.String2Int(parts[1])
+			if channelId == 0 {
+				abortWithMessage(c, http.StatusForbidden, "无效的渠道 Id")
 				return
 			}
+			c.Set("specific_channel_id", channelId)
+		} else {
+			abortWithMessage(c, http.StatusForbidden, "普通用户不支持指定渠道")
+			return
 		}
-		c.Next()
 	}
+	c.Next()
 }
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConcurrencyLimit 限制令牌、用户及分组同时处理的请求数，超出上限的请求排队等待
+func ConcurrencyLimit() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userId := c.GetInt("id")
+		userLimit, err := model.CacheGetUserRateLimit(userId)
+		if err != nil {
+			abortWithMessage(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		tokenLimit, _ := c.Value("token_rate_limit").(common.RateLimit)
+		group := c.GetString("group")
+
+		semaphores := []struct {
+			key   string
+			limit int
+		}{
+			{fmt.Sprintf("concurrency:token:%d", c.GetInt("token_id")), tokenLimit.Concurrency},
+			{fmt.Sprintf("concurrency:user:%d", userId), userLimit.Concurrency},
+			{fmt.Sprintf("concurrency:group:%s", group), common.GetGroupRateLimit(group).Concurrency},
+		}
+
+		var releases []func()
+		defer func() {
+			for _, release := range releases {
+				release()
+			}
+		}()
+
+		// 多个限制共用同一个等待时间
+		deadline := time.Now().Add(time.Duration(common.ConcurrencyQueueSeconds) * time.Second)
+		for _, semaphore := range semaphores {
+			if semaphore.limit <= 0 {
+				continue
+			}
+			release, err := common.AcquireSemaphore(c.Request.Context(), semaphore.key, semaphore.limit, common.ConcurrencyQueueSize, time.Until(deadline))
+			if err == nil {
+				releases = append(releases, release)
+				continue
+			}
+
+			switch {
+			case errors.Is(err, context.Canceled):
+				c.Abort()
+				return
+			case errors.Is(err, common.ErrSemaphoreQueueFull), errors.Is(err, common.ErrSemaphoreTimeout):
+				abortWithConcurrencyLimit(c, semaphore.key, semaphore.limit)
+				return
+			default:
+				// 计数失败时放行，避免 Redis 故障导致服务不可用
+				common.LogError(c.Request.Context(), "concurrency limit error: "+err.Error())
+			}
+		}
+
+		c.Next()
+	}
+}
+
+func abortWithConcurrencyLimit(c *gin.Context, key string, limit int) {
+	message := fmt.Sprintf("Concurrency limit reached for %s: Limit %d. Please try again later.", key, limit)
+	abortWithError(c, http.StatusTooManyRequests, gin.H{
+		"message": common.MessageWithRequestId(message, c.GetString(common.RequestIdKey)),
+		"type":    "requests",
+		"code":    "concurrency_limit_exceeded",
+	})
+	common.LogWarn(c.Request.Context(), message)
+}
@@ -1,15 +1,21 @@
 package middleware
 
 import (
+	"one-api/common/tracing"
 	"one-api/model"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		userId := c.GetInt("id")
+		_, span := tracing.Start(c.Request.Context(), "distribute", attribute.Int("user_id", userId))
 		userGroup, _ := model.CacheGetUserGroup(userId)
+		span.SetAttributes(attribute.String("group", userGroup))
+		span.End()
+
 		c.Set("group", userGroup)
 		c.Next()
 	}
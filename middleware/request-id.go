@@ -3,9 +3,12 @@ package middleware
 import (
 	"context"
 	"one-api/common"
+	"one-api/common/tracing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 func RequestId() func(c *gin.Context) {
@@ -14,8 +17,23 @@ func RequestId() func(c *gin.Context) {
 		c.Set(common.RequestIdKey, id)
 		ctx := context.WithValue(c.Request.Context(), common.RequestIdKey, id)
 		ctx = context.WithValue(ctx, "requestStartTime", time.Now())
+
+		ctx, span := tracing.StartServer(ctx, propagation.HeaderCarrier(c.Request.Header), c.Request.Method,
+			attribute.String("request_id", id),
+			attribute.String("http.method", c.Request.Method),
+			attribute.String("http.target", c.Request.URL.Path),
+		)
+		defer span.End()
+
 		c.Request = c.Request.WithContext(ctx)
 		c.Header(common.RequestIdKey, id)
 		c.Next()
+
+		span.SetAttributes(attribute.Int("http.status_code", c.Writer.Status()))
+		// 按路由模板命名，避免路径中的 id 等参数导致 span 名称过多
+		if route := c.FullPath(); route != "" {
+			span.SetName(c.Request.Method + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
 	}
 }
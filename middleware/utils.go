@@ -3,15 +3,29 @@ package middleware
 import (
 	"github.com/gin-gonic/gin"
 	"one-api/common"
+	"one-api/types"
 )
 
 func abortWithMessage(c *gin.Context, statusCode int, message string) {
-	c.JSON(statusCode, gin.H{
-		"error": gin.H{
-			"message": common.MessageWithRequestId(message, c.GetString(common.RequestIdKey)),
-			"type":    "one_api_error",
-		},
+	abortWithError(c, statusCode, gin.H{
+		"message": common.MessageWithRequestId(message, c.GetString(common.RequestIdKey)),
+		"type":    "one_api_error",
 	})
-	c.Abort()
 	common.LogError(c.Request.Context(), message)
 }
+
+// Anthropic 客户端只能解析 {"type":"error","error":{...}} 格式的错误
+func abortWithError(c *gin.Context, statusCode int, err gin.H) {
+	if c.FullPath() == "/v1/messages" {
+		c.JSON(statusCode, &types.MessagesStreamEvent{
+			Type: "error",
+			Error: &types.MessagesError{
+				Type:    types.MessagesErrorType(statusCode),
+				Message: err["message"].(string),
+			},
+		})
+	} else {
+		c.JSON(statusCode, gin.H{"error": err})
+	}
+	c.Abort()
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"errors"
+	"math/rand"
 	"one-api/common"
 	"strings"
 )
@@ -14,8 +16,9 @@ type Ability struct {
 	Weight    *uint  `json:"weight" gorm:"default:1"`
 }
 
-func GetRandomSatisfiedChannel(group string, model string) (*Channel, error) {
-	ability := Ability{}
+// GetRandomSatisfiedChannel 未开启内存缓存时从数据库选择渠道，按优先级从高到低跳过本次请求失败过及熔断中的渠道
+func GetRandomSatisfiedChannel(group string, model string, skipChannelIds ...int) (*Channel, error) {
+	var abilities []Ability
 	groupCol := "`group`"
 	trueVal := "1"
 	if common.UsingPostgreSQL {
@@ -23,23 +26,68 @@ func GetRandomSatisfiedChannel(group string, model string) (*Channel, error) {
 		trueVal = "true"
 	}
 
-	var err error = nil
-	maxPrioritySubQuery := DB.Model(&Ability{}).Select("MAX(priority)").Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model)
-	channelQuery := DB.Where(groupCol+" = ? and model = ? and enabled = "+trueVal+" and priority = (?)", group, model, maxPrioritySubQuery)
-	if common.UsingSQLite || common.UsingPostgreSQL {
-		err = channelQuery.Order("RANDOM()").First(&ability).Error
-	} else {
-		err = channelQuery.Order("RAND()").First(&ability).Error
+	channelQuery := DB.Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model)
+	if len(skipChannelIds) > 0 {
+		channelQuery = channelQuery.Where("channel_id NOT IN ?", skipChannelIds)
 	}
-	if err != nil {
+	if err := channelQuery.Order("priority desc").Find(&abilities).Error; err != nil {
 		return nil, err
 	}
+
+	candidates := make([]int, 0, len(abilities))
+	for i, ability := range abilities {
+		if len(candidates) > 0 && ability.getPriority() != abilities[i-1].getPriority() {
+			break
+		}
+		if ChannelCircuitBreakers.Allow(ability.ChannelId, model) {
+			candidates = append(candidates, ability.ChannelId)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("channel not found")
+	}
+
 	channel := Channel{}
-	channel.Id = ability.ChannelId
-	err = DB.First(&channel, "id = ?", ability.ChannelId).Error
+	err := DB.First(&channel, "id = ?", candidates[rand.Intn(len(candidates))]).Error
 	return &channel, err
 }
 
+func (ability *Ability) getPriority() int64 {
+	if ability.Priority == nil {
+		return 0
+	}
+	return *ability.Priority
+}
+
+func GetRandomSatisfiedChannelByType(group string, channelTypes []int, skipChannelIds ...int) (*Channel, error) {
+	trueVal := "1"
+	if common.UsingPostgreSQL {
+		trueVal = "true"
+	}
+
+	var channels []*Channel
+	abilityQuery := DB.Model(&Ability{}).Select("channel_id").Where(quotePostgresField("group")+" = ? and enabled = "+trueVal, group)
+	channelQuery := DB.Where("type IN ? and status = ? and id IN (?)", channelTypes, common.ChannelStatusEnabled, abilityQuery)
+	if len(skipChannelIds) > 0 {
+		channelQuery = channelQuery.Where("id NOT IN ?", skipChannelIds)
+	}
+	if err := channelQuery.Find(&channels).Error; err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if ChannelCircuitBreakers.AllowChannel(channel.Id) {
+			candidates = append(candidates, channel)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("channel not found")
+	}
+
+	return candidates[rand.Intn(len(candidates))], nil
+}
+
 func GetGroupModels(group string) ([]string, error) {
 	var models []string
 	groupCol := "`group`"
@@ -73,6 +121,19 @@ func (channel *Channel) AddAbilities() error {
 			abilities = append(abilities, ability)
 		}
 	}
+
+	// 保留该渠道上微调出的模型
+	jobs, err := GetChannelFineTunedJobs(channel.Id)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if strings.Contains(","+channel.Group+",", ","+job.Group+",") && strings.Contains(","+channel.Models+",", ","+job.FineTunedModel+",") {
+			continue
+		}
+		abilities = append(abilities, job.toAbility(channel))
+	}
+
 	return DB.Create(&abilities).Error
 }
 
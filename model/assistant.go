@@ -0,0 +1,76 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"time"
+)
+
+const (
+	AssistantObjectAssistant = "assistant"
+	AssistantObjectThread    = "thread"
+	AssistantObjectRun       = "thread.run"
+)
+
+// AssistantMapping 记录 assistant/thread/run 所属的用户及渠道，保证后续请求落到同一个上游
+type AssistantMapping struct {
+	Id          int    `json:"id"`
+	ObjectId    string `json:"object_id" gorm:"type:varchar(64);uniqueIndex"`
+	Object      string `json:"object" gorm:"type:varchar(32);index"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	TokenId     int    `json:"token_id"`
+	ChannelId   int    `json:"channel_id" gorm:"index"`
+	KeyId       int    `json:"key_id" gorm:"default:0"` // 多 key 渠道中创建时使用的 key
+	Model       string `json:"model" gorm:"type:varchar(64);default:''"`
+	ThreadId    string `json:"thread_id" gorm:"type:varchar(64);default:''"` // run 所在的 thread，用于后台查询 run 的状态
+	Billed      bool   `json:"billed" gorm:"default:false"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func (mapping *AssistantMapping) Insert() error {
+	if mapping.CreatedTime == 0 {
+		mapping.CreatedTime = common.GetTimestamp()
+	}
+	return DB.Create(mapping).Error
+}
+
+func (mapping *AssistantMapping) Delete() error {
+	return DB.Delete(mapping).Error
+}
+
+func GetAssistantMapping(objectId string, object string, userId int) (*AssistantMapping, error) {
+	if objectId == "" || userId == 0 {
+		return nil, errors.New("objectId 或 userId 为空！")
+	}
+	mapping := &AssistantMapping{}
+	err := DB.First(mapping, "object_id = ? and object = ? and user_id = ?", objectId, object, userId).Error
+	return mapping, err
+}
+
+// 获取用户最近创建的对象
+func GetLatestAssistantMapping(object string, userId int) (*AssistantMapping, error) {
+	mapping := &AssistantMapping{}
+	err := DB.Where("object = ? and user_id = ?", object, userId).Order("id desc").First(mapping).Error
+	return mapping, err
+}
+
+func GetUserAssistantMappings(object string, userId int) (mappings []*AssistantMapping, err error) {
+	err = DB.Where("object = ? and user_id = ?", object, userId).Order("id desc").Find(&mappings).Error
+	return mappings, err
+}
+
+// 将 run 标记为已计费，只有首次标记成功时返回 true，防止重复扣费
+func MarkAssistantRunBilled(runId string) (bool, error) {
+	result := DB.Model(&AssistantMapping{}).Where("object_id = ? and object = ? and billed = ?", runId, AssistantObjectRun, false).Update("billed", true)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetUnbilledAssistantRuns 按 id 分批获取尚未计费的 run，超过 maxAge 仍未结束的不再查询
+func GetUnbilledAssistantRuns(maxAge time.Duration, afterId int, limit int) (mappings []*AssistantMapping, err error) {
+	err = DB.Where("id > ? and object = ? and billed = ? and thread_id != ? and created_time > ?", afterId, AssistantObjectRun, false, "", common.GetTimestamp()-int64(maxAge.Seconds())).
+		Order("id").Limit(limit).Find(&mappings).Error
+	return mappings, err
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"math/rand"
 	"one-api/common"
+	"one-api/common/metrics"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -20,7 +22,7 @@ type ChannelsChooser struct {
 	Rule     map[string]map[string][][]int // group -> model -> priority -> channelIds
 }
 
-func (cc *ChannelsChooser) Cooldowns(channelId int) bool {
+func (cc *ChannelsChooser) Cooldowns(channelId int, group string) bool {
 	if common.RetryCooldownSeconds == 0 {
 		return false
 	}
@@ -30,10 +32,32 @@ func (cc *ChannelsChooser) Cooldowns(channelId int) bool {
 		return false
 	}
 
-	cc.Channels[channelId].CooldownsTime = time.Now().Unix() + int64(common.RetryCooldownSeconds)
+	cooldownSeconds := int64(common.RetryCooldownSeconds)
+	if common.GetGroupBalancer(group) == common.BalancerStrategyAdaptive {
+		cooldownSeconds = backoffCooldownSeconds(ChannelHealths.Get(channelId).getConsecutiveFailures())
+	}
+
+	cc.Channels[channelId].CooldownsTime = time.Now().Unix() + cooldownSeconds
+	metrics.RecordChannelCooldown(channelId, group)
 	return true
 }
 
+// 连续失败时冷却时间按 2 的幂次增长，最长不超过 RetryCooldownMaxSeconds
+func backoffCooldownSeconds(failures int) int64 {
+	cooldownSeconds := int64(common.RetryCooldownSeconds)
+	maxSeconds := int64(common.RetryCooldownMaxSeconds)
+	if maxSeconds < cooldownSeconds {
+		maxSeconds = cooldownSeconds
+	}
+	for i := 1; i < failures && cooldownSeconds < maxSeconds; i++ {
+		cooldownSeconds *= 2
+	}
+	if cooldownSeconds > maxSeconds {
+		cooldownSeconds = maxSeconds
+	}
+	return cooldownSeconds
+}
+
 func (cc *ChannelsChooser) Balancer(channelIds []int) *Channel {
 	nowTime := time.Now().Unix()
 	totalWeight := 0
@@ -67,9 +91,93 @@ func (cc *ChannelsChooser) Balancer(channelIds []int) *Channel {
 	return nil
 }
 
-func (cc *ChannelsChooser) Next(group, model string) (*Channel, error) {
+// AdaptiveBalancer 在渠道权重的基础上，按最近的成功率、延迟及首字时间调整有效权重
+func (cc *ChannelsChooser) AdaptiveBalancer(channelIds []int) *Channel {
+	nowTime := time.Now().Unix()
+
+	validChannels := make([]*ChannelChoice, 0, len(channelIds))
+	stats := make([]ChannelHealthStats, 0, len(channelIds))
+	var minP50, minP95, minTTFT time.Duration
+	for _, channelId := range channelIds {
+		choice, ok := cc.Channels[channelId]
+		if !ok || choice.CooldownsTime >= nowTime {
+			continue
+		}
+		stat := ChannelHealths.Get(channelId).Stats()
+		minP50 = minDuration(minP50, stat.P50Latency)
+		minP95 = minDuration(minP95, stat.P95Latency)
+		minTTFT = minDuration(minTTFT, stat.P50TTFT)
+		validChannels = append(validChannels, choice)
+		stats = append(stats, stat)
+	}
+
+	if len(validChannels) == 0 {
+		return nil
+	}
+
+	if len(validChannels) == 1 {
+		return validChannels[0].Channel
+	}
+
+	totalWeight := 0.0
+	weights := make([]float64, len(validChannels))
+	for i, choice := range validChannels {
+		stat := stats[i]
+		// 成功率取平方放大差异，样本为空的渠道按满分处理，保证能被探测到
+		score := stat.SuccessRate * stat.SuccessRate
+		score *= (latencyScore(minP50, stat.P50Latency) + latencyScore(minP95, stat.P95Latency) + latencyScore(minTTFT, stat.P50TTFT)) / 3
+		if score < 0.01 {
+			score = 0.01
+		}
+		weights[i] = float64(*choice.Channel.Weight) * score
+		totalWeight += weights[i]
+	}
+
+	choiceWeight := rand.Float64() * totalWeight
+	for i, choice := range validChannels {
+		choiceWeight -= weights[i]
+		if choiceWeight < 0 {
+			return choice.Channel
+		}
+	}
+
+	return validChannels[len(validChannels)-1].Channel
+}
+
+func minDuration(current, value time.Duration) time.Duration {
+	if value == 0 {
+		return current
+	}
+	if current == 0 || value < current {
+		return value
+	}
+	return current
+}
+
+// 与同组中最快的渠道相比得到 0~1 的分数，没有数据时为 1
+func latencyScore(best, value time.Duration) float64 {
+	if best == 0 || value == 0 {
+		return 1
+	}
+	return float64(best) / float64(value)
+}
+
+func (cc *ChannelsChooser) balance(group string, channelIds []int) *Channel {
+	if common.GetGroupBalancer(group) == common.BalancerStrategyAdaptive {
+		return cc.AdaptiveBalancer(channelIds)
+	}
+	return cc.Balancer(channelIds)
+}
+
+// Next 选择可用渠道，skipChannelIds 为本次请求中已经失败的渠道
+func (cc *ChannelsChooser) Next(group, model string, skipChannelIds ...int) (*Channel, error) {
 	if !common.MemoryCacheEnabled {
-		return GetRandomSatisfiedChannel(group, model)
+		channel, err := GetRandomSatisfiedChannel(group, model, skipChannelIds...)
+		if err != nil {
+			return nil, err
+		}
+		ChannelCircuitBreakers.Acquire(channel.Id, model)
+		return channel, nil
 	}
 	cc.RLock()
 	defer cc.RUnlock()
@@ -87,8 +195,17 @@ func (cc *ChannelsChooser) Next(group, model string) (*Channel, error) {
 	}
 
 	for _, priority := range channelsPriority {
-		channel := cc.Balancer(priority)
+		channelIds := make([]int, 0, len(priority))
+		for _, channelId := range priority {
+			if slices.Contains(skipChannelIds, channelId) || !ChannelCircuitBreakers.Allow(channelId, model) {
+				continue
+			}
+			channelIds = append(channelIds, channelId)
+		}
+
+		channel := cc.balance(group, channelIds)
 		if channel != nil {
+			ChannelCircuitBreakers.Acquire(channel.Id, model)
 			return channel, nil
 		}
 	}
@@ -96,6 +213,43 @@ func (cc *ChannelsChooser) Next(group, model string) (*Channel, error) {
 	return nil, errors.New("channel not found")
 }
 
+// NextByType 从分组中选择指定类型的渠道，用于 Files 等请求中不带模型的接口
+func (cc *ChannelsChooser) NextByType(group string, channelTypes []int, skipChannelIds ...int) (*Channel, error) {
+	if !common.MemoryCacheEnabled {
+		return GetRandomSatisfiedChannelByType(group, channelTypes, skipChannelIds...)
+	}
+	cc.RLock()
+	defer cc.RUnlock()
+	if _, ok := cc.Rule[group]; !ok {
+		return nil, errors.New("group not found")
+	}
+
+	seen := make(map[int]bool)
+	channelIds := make([]int, 0)
+	for _, channelsPriority := range cc.Rule[group] {
+		for _, priority := range channelsPriority {
+			for _, channelId := range priority {
+				choice, ok := cc.Channels[channelId]
+				if !ok || seen[channelId] || !slices.Contains(channelTypes, choice.Channel.Type) {
+					continue
+				}
+				if slices.Contains(skipChannelIds, channelId) || !ChannelCircuitBreakers.AllowChannel(channelId) {
+					continue
+				}
+				seen[channelId] = true
+				channelIds = append(channelIds, channelId)
+			}
+		}
+	}
+
+	channel := cc.balance(group, channelIds)
+	if channel == nil {
+		return nil, errors.New("channel not found")
+	}
+
+	return channel, nil
+}
+
 func (cc *ChannelsChooser) GetGroupModels(group string) ([]string, error) {
 	if !common.MemoryCacheEnabled {
 		return GetGroupModels(group)
@@ -164,6 +318,7 @@ func InitChannelGroup() {
 	ChannelGroup.Rule = newGroup
 	ChannelGroup.Channels = newChannels
 	ChannelGroup.Unlock()
+	channelKeyCache.reset()
 	common.SysLog("channels synced from database")
 }
 
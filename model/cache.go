@@ -63,6 +63,26 @@ func CacheGetUserGroup(id int) (group string, err error) {
 	return group, err
 }
 
+func CacheGetUserRateLimit(id int) (limit common.RateLimit, err error) {
+	if !common.RedisEnabled {
+		return GetUserRateLimit(id)
+	}
+	limitString, err := common.RedisGet(fmt.Sprintf("user_rate_limit:%d", id))
+	if err == nil && json.Unmarshal([]byte(limitString), &limit) == nil {
+		return limit, nil
+	}
+	limit, err = GetUserRateLimit(id)
+	if err != nil {
+		return limit, err
+	}
+	jsonBytes, _ := json.Marshal(limit)
+	err = common.RedisSet(fmt.Sprintf("user_rate_limit:%d", id), string(jsonBytes), time.Duration(UserId2GroupCacheSeconds)*time.Second)
+	if err != nil {
+		common.SysError("Redis set user rate limit error: " + err.Error())
+	}
+	return limit, nil
+}
+
 func CacheGetUserQuota(id int) (quota int, err error) {
 	if !common.RedisEnabled {
 		return GetUserQuota(id)
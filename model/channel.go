@@ -3,6 +3,7 @@ package model
 import (
 	"one-api/common"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -28,6 +29,12 @@ type Channel struct {
 	Priority           *int64  `json:"priority" gorm:"bigint;default:0"`
 	Proxy              *string `json:"proxy" gorm:"type:varchar(255);default:''"`
 	TestModel          string  `json:"test_model" form:"test_model" gorm:"type:varchar(50);default:''"`
+	KeyRotation        string  `json:"key_rotation" form:"key_rotation" gorm:"type:varchar(16);default:''"` // 为空时为单 key 渠道
+	FirstByteTimeout   *int    `json:"first_byte_timeout" gorm:"default:0"`                                 // 流式请求等待首个数据块的超时秒数，0 为不限制
+	IdleTimeout        *int    `json:"idle_timeout" gorm:"default:0"`                                       // 流式请求两个数据块之间的超时秒数，0 为不限制
+
+	KeyId int           `json:"-" gorm:"-"`              // 多 key 渠道本次请求使用的 key
+	Keys  []*ChannelKey `json:"keys,omitempty" gorm:"-"` // 多 key 渠道的 key 状态
 }
 
 var allowedChannelOrderFields = map[string]bool{
@@ -175,6 +182,20 @@ func (channel *Channel) GetModelMapping() string {
 	return *channel.ModelMapping
 }
 
+func (channel *Channel) GetFirstByteTimeout() time.Duration {
+	if channel.FirstByteTimeout == nil {
+		return 0
+	}
+	return time.Duration(*channel.FirstByteTimeout) * time.Second
+}
+
+func (channel *Channel) GetIdleTimeout() time.Duration {
+	if channel.IdleTimeout == nil {
+		return 0
+	}
+	return time.Duration(*channel.IdleTimeout) * time.Second
+}
+
 func (channel *Channel) Insert() error {
 	var err error
 	err = DB.Create(channel).Error
@@ -223,7 +244,10 @@ func (channel *Channel) Delete() error {
 		return err
 	}
 	err = channel.DeleteAbilities()
-	return err
+	if err != nil {
+		return err
+	}
+	return DeleteChannelKeys(channel.Id)
 }
 
 func UpdateChannelStatusById(id int, status int) {
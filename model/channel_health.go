@@ -0,0 +1,141 @@
+package model
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	channelHealthWindowSize = 100              // 每个渠道保留的最近请求数
+	channelHealthWindowTime = 10 * time.Minute // 超过该时间的样本不再参与统计
+)
+
+type channelSample struct {
+	Time    time.Time
+	Success bool
+	Latency time.Duration
+	TTFT    time.Duration
+}
+
+// ChannelHealth 渠道最近请求的滚动统计，用于自适应负载均衡
+type ChannelHealth struct {
+	sync.Mutex
+	samples             []channelSample
+	next                int
+	ConsecutiveFailures int
+}
+
+type ChannelHealthStats struct {
+	Samples     int           `json:"samples"`
+	SuccessRate float64       `json:"success_rate"`
+	P50Latency  time.Duration `json:"p50_latency"`
+	P95Latency  time.Duration `json:"p95_latency"`
+	P50TTFT     time.Duration `json:"p50_ttft"`
+}
+
+func (h *ChannelHealth) record(sample channelSample) {
+	h.Lock()
+	defer h.Unlock()
+
+	if sample.Success {
+		h.ConsecutiveFailures = 0
+	} else {
+		h.ConsecutiveFailures++
+	}
+
+	if len(h.samples) < channelHealthWindowSize {
+		h.samples = append(h.samples, sample)
+		return
+	}
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % channelHealthWindowSize
+}
+
+func (h *ChannelHealth) getConsecutiveFailures() int {
+	h.Lock()
+	defer h.Unlock()
+	return h.ConsecutiveFailures
+}
+
+func (h *ChannelHealth) Stats() ChannelHealthStats {
+	h.Lock()
+	defer h.Unlock()
+
+	stats := ChannelHealthStats{}
+	expired := time.Now().Add(-channelHealthWindowTime)
+	success := 0
+	latencies := make([]time.Duration, 0, len(h.samples))
+	ttfts := make([]time.Duration, 0, len(h.samples))
+	for _, sample := range h.samples {
+		if sample.Time.Before(expired) {
+			continue
+		}
+		stats.Samples++
+		if !sample.Success {
+			continue
+		}
+		success++
+		latencies = append(latencies, sample.Latency)
+		if sample.TTFT > 0 {
+			ttfts = append(ttfts, sample.TTFT)
+		}
+	}
+
+	if stats.Samples == 0 {
+		stats.SuccessRate = 1
+		return stats
+	}
+
+	stats.SuccessRate = float64(success) / float64(stats.Samples)
+	stats.P50Latency = percentile(latencies, 0.5)
+	stats.P95Latency = percentile(latencies, 0.95)
+	stats.P50TTFT = percentile(ttfts, 0.5)
+
+	return stats
+}
+
+func percentile(values []time.Duration, p float64) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	slices.Sort(values)
+	return values[int(float64(len(values)-1)*p)]
+}
+
+type channelHealthMonitor struct {
+	sync.RWMutex
+	health map[int]*ChannelHealth
+}
+
+// ChannelHealths 独立于 ChannelGroup 保存，渠道同步时不会丢失统计
+var ChannelHealths = &channelHealthMonitor{
+	health: make(map[int]*ChannelHealth),
+}
+
+func (m *channelHealthMonitor) Get(channelId int) *ChannelHealth {
+	m.RLock()
+	health, ok := m.health[channelId]
+	m.RUnlock()
+	if ok {
+		return health
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	if health, ok = m.health[channelId]; !ok {
+		health = &ChannelHealth{}
+		m.health[channelId] = health
+	}
+	return health
+}
+
+// Record 记录一次请求结果，ttft 为 0 表示非流式请求
+func (m *channelHealthMonitor) Record(channelId int, success bool, latency, ttft time.Duration) {
+	m.Get(channelId).record(channelSample{
+		Time:    time.Now(),
+		Success: success,
+		Latency: latency,
+		TTFT:    ttft,
+	})
+}
@@ -0,0 +1,226 @@
+package model
+
+import (
+	"errors"
+	"math/rand"
+	"one-api/common"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+const (
+	KeyRotationRoundRobin = "round_robin" // 按顺序轮询
+	KeyRotationRandom     = "random"      // 随机选择
+)
+
+// ChannelKey 多 key 渠道中的单个 key，key 失效时只禁用该 key，渠道继续使用其他 key
+type ChannelKey struct {
+	Id             int    `json:"id"`
+	ChannelId      int    `json:"channel_id" gorm:"index"`
+	Key            string `json:"key" gorm:"type:varchar(767);not null"`
+	Status         int    `json:"status" gorm:"default:1"`
+	DisabledReason string `json:"disabled_reason" gorm:"type:varchar(255);default:''"`
+	DisabledTime   int64  `json:"disabled_time" gorm:"bigint"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+}
+
+// 将换行分隔的 key 列表拆分并去重
+func SplitChannelKeys(keys string) []string {
+	result := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, key := range strings.Split(keys, "\n") {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, key)
+	}
+	return result
+}
+
+func GetChannelKeys(channelId int) (keys []*ChannelKey, err error) {
+	err = DB.Where("channel_id = ?", channelId).Order("id asc").Find(&keys).Error
+	return keys, err
+}
+
+// SyncChannelKeys 按新的 key 列表更新渠道的 key，已存在的 key 保留原有状态
+func SyncChannelKeys(channelId int, keys []string) error {
+	existKeys, err := GetChannelKeys(channelId)
+	if err != nil {
+		return err
+	}
+
+	existMap := make(map[string]*ChannelKey, len(existKeys))
+	for _, existKey := range existKeys {
+		existMap[existKey.Key] = existKey
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			if _, ok := existMap[key]; ok {
+				delete(existMap, key)
+				continue
+			}
+			channelKey := &ChannelKey{
+				ChannelId:   channelId,
+				Key:         key,
+				Status:      common.ChannelStatusEnabled,
+				CreatedTime: common.GetTimestamp(),
+			}
+			if err := tx.Create(channelKey).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, removedKey := range existMap {
+			if err := tx.Delete(removedKey).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	channelKeyCache.invalidate(channelId)
+	return err
+}
+
+func DeleteChannelKeys(channelId int) error {
+	channelKeyCache.invalidate(channelId)
+	return DB.Where("channel_id = ?", channelId).Delete(&ChannelKey{}).Error
+}
+
+func UpdateChannelKeyStatus(id int, status int, reason string) (*ChannelKey, error) {
+	channelKey := &ChannelKey{}
+	if err := DB.First(channelKey, "id = ?", id).Error; err != nil {
+		return nil, errors.New("key 不存在")
+	}
+
+	channelKey.Status = status
+	channelKey.DisabledReason = reason
+	channelKey.DisabledTime = 0
+	if status != common.ChannelStatusEnabled {
+		channelKey.DisabledTime = common.GetTimestamp()
+	}
+
+	err := DB.Model(channelKey).Select("status", "disabled_reason", "disabled_time").Updates(channelKey).Error
+	channelKeyCache.invalidate(channelKey.ChannelId)
+	return channelKey, err
+}
+
+// DisableChannelKey 禁用单个 key，返回渠道剩余可用的 key 数量
+func DisableChannelKey(channelId, keyId int, reason string) (int, error) {
+	if _, err := UpdateChannelKeyStatus(keyId, common.ChannelStatusAutoDisabled, reason); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err := DB.Model(&ChannelKey{}).Where("channel_id = ? and status = ?", channelId, common.ChannelStatusEnabled).Count(&count).Error
+	return int(count), err
+}
+
+type channelKeyCacheStore struct {
+	sync.Mutex
+	keys   map[int][]*ChannelKey
+	cursor map[int]int
+}
+
+var channelKeyCache = &channelKeyCacheStore{
+	keys:   make(map[int][]*ChannelKey),
+	cursor: make(map[int]int),
+}
+
+func (s *channelKeyCacheStore) invalidate(channelId int) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.keys, channelId)
+}
+
+func (s *channelKeyCacheStore) reset() {
+	s.Lock()
+	defer s.Unlock()
+	s.keys = make(map[int][]*ChannelKey)
+}
+
+func (s *channelKeyCacheStore) getEnabledKeys(channelId int) ([]*ChannelKey, error) {
+	s.Lock()
+	keys, ok := s.keys[channelId]
+	s.Unlock()
+	if ok && common.MemoryCacheEnabled {
+		return keys, nil
+	}
+
+	err := DB.Where("channel_id = ? and status = ?", channelId, common.ChannelStatusEnabled).Order("id asc").Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	s.keys[channelId] = keys
+	s.Unlock()
+	return keys, nil
+}
+
+func (s *channelKeyCacheStore) next(channelId int, rotation string, count int) int {
+	if rotation == KeyRotationRandom {
+		return rand.Intn(count)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	index := s.cursor[channelId] % count
+	s.cursor[channelId] = index + 1
+	return index
+}
+
+func (channel *Channel) IsMultiKey() bool {
+	return channel.KeyRotation != ""
+}
+
+// SelectKey 多 key 渠道按轮换策略选择一个可用的 key，返回带有该 key 的渠道副本
+// 已通过 PinKey 固定 key 时直接返回，没有可用的 key 时返回 nil
+func (channel *Channel) SelectKey() *Channel {
+	if !channel.IsMultiKey() || channel.KeyId > 0 {
+		return channel
+	}
+
+	keys, err := channelKeyCache.getEnabledKeys(channel.Id)
+	if err != nil {
+		common.SysError("failed to get channel keys: " + err.Error())
+		return nil
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return channel.withKey(keys[channelKeyCache.next(channel.Id, channel.KeyRotation, len(keys))])
+}
+
+// PinKey 返回固定使用 keyId 的渠道副本，文件、assistant 等对象只存在于创建时使用的 key 下
+// keyId 为 0（单 key 渠道或旧数据）时不固定
+func (channel *Channel) PinKey(keyId int) (*Channel, error) {
+	if !channel.IsMultiKey() || keyId == 0 {
+		return channel, nil
+	}
+
+	keys, err := channelKeyCache.getEnabledKeys(channel.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, channelKey := range keys {
+		if channelKey.Id == keyId {
+			return channel.withKey(channelKey), nil
+		}
+	}
+
+	return nil, errors.New("该对象所在的 key 已被禁用")
+}
+
+func (channel *Channel) withKey(channelKey *ChannelKey) *Channel {
+	keyChannel := *channel
+	keyChannel.Key = channelKey.Key
+	keyChannel.KeyId = channelKey.Id
+	return &keyChannel
+}
@@ -0,0 +1,59 @@
+package model_test
+
+import (
+	"one-api/common"
+	"one-api/common/test"
+	_ "one-api/common/test/init"
+	"one-api/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelKeyRotation(t *testing.T) {
+	test.InitTestDB(t)
+
+	keys := model.SplitChannelKeys("sk-1\nsk-2\n sk-2 \n\nsk-3")
+	assert.Equal(t, []string{"sk-1", "sk-2", "sk-3"}, keys)
+	assert.Nil(t, model.SyncChannelKeys(1, keys))
+	channelKeys, _ := model.GetChannelKeys(1)
+	assert.Len(t, channelKeys, 3)
+
+	channel := &model.Channel{Id: 1, Key: "sk-1\nsk-2\nsk-3", KeyRotation: model.KeyRotationRoundRobin}
+	selected := make([]string, 0, 4)
+	for i := 0; i < 4; i++ {
+		keyChannel := channel.SelectKey()
+		assert.NotNil(t, keyChannel)
+		assert.NotZero(t, keyChannel.KeyId)
+		selected = append(selected, keyChannel.Key)
+	}
+	assert.ElementsMatch(t, keys, selected[:3])
+	assert.Equal(t, selected[0], selected[3])
+
+	// 禁用的 key 不再被选中，也不能再固定使用
+	remaining, err := model.DisableChannelKey(1, channelKeys[1].Id, "invalid api key")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, remaining)
+	for i := 0; i < 4; i++ {
+		assert.NotEqual(t, "sk-2", channel.SelectKey().Key)
+	}
+	_, err = channel.PinKey(channelKeys[1].Id)
+	assert.NotNil(t, err)
+	pinned, err := channel.PinKey(channelKeys[0].Id)
+	assert.Nil(t, err)
+	assert.Equal(t, "sk-1", pinned.Key)
+	assert.Equal(t, pinned, pinned.SelectKey())
+
+	// 所有 key 都被禁用后渠道不可用
+	model.DisableChannelKey(1, channelKeys[0].Id, "invalid api key")
+	remaining, _ = model.DisableChannelKey(1, channelKeys[2].Id, "invalid api key")
+	assert.Equal(t, 0, remaining)
+	assert.Nil(t, channel.SelectKey())
+
+	// 更新 key 列表时保留已有 key 的状态
+	assert.Nil(t, model.SyncChannelKeys(1, []string{"sk-1", "sk-4"}))
+	channelKeys, _ = model.GetChannelKeys(1)
+	assert.Len(t, channelKeys, 2)
+	assert.Equal(t, common.ChannelStatusAutoDisabled, channelKeys[0].Status)
+	assert.Equal(t, "sk-4", channel.SelectKey().Key)
+}
@@ -0,0 +1,174 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CircuitStateClosed   = "closed"    // 正常放行
+	CircuitStateOpen     = "open"      // 熔断中，拒绝请求
+	CircuitStateHalfOpen = "half_open" // 熔断超时后放行试探请求
+)
+
+const circuitBreakerMaxOpenMultiple = 16 // 试探失败时熔断时间翻倍的上限倍数
+
+type circuitBreaker struct {
+	State       string
+	Failures    int
+	OpenedAt    time.Time
+	OpenTimeout time.Duration
+	LastTrialAt time.Time
+}
+
+// CircuitBreakers 按 渠道+模型 维度熔断，某个模型失败不影响渠道的其他模型
+type CircuitBreakers struct {
+	sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+var ChannelCircuitBreakers = &CircuitBreakers{
+	breakers: make(map[string]*circuitBreaker),
+}
+
+func circuitBreakerKey(channelId int, modelName string) string {
+	return fmt.Sprintf("%d:%s", channelId, modelName)
+}
+
+func (cb *CircuitBreakers) get(channelId int, modelName string) *circuitBreaker {
+	key := circuitBreakerKey(channelId, modelName)
+	breaker, ok := cb.breakers[key]
+	if !ok {
+		breaker = &circuitBreaker{State: CircuitStateClosed}
+		cb.breakers[key] = breaker
+	}
+	return breaker
+}
+
+func openTimeout() time.Duration {
+	return time.Duration(common.CircuitBreakerOpenSeconds) * time.Second
+}
+
+// Allow 判断是否可以向该渠道的模型发送请求，不会改变熔断状态
+func (cb *CircuitBreakers) Allow(channelId int, modelName string) bool {
+	if !common.CircuitBreakerEnabled {
+		return true
+	}
+	cb.Lock()
+	defer cb.Unlock()
+
+	breaker, ok := cb.breakers[circuitBreakerKey(channelId, modelName)]
+	if !ok {
+		return true
+	}
+
+	return breaker.allow(time.Now())
+}
+
+func (breaker *circuitBreaker) allow(now time.Time) bool {
+	switch breaker.State {
+	case CircuitStateOpen:
+		return now.Sub(breaker.OpenedAt) >= breaker.OpenTimeout
+	case CircuitStateHalfOpen:
+		// 半开状态下每个熔断周期只放行一个试探请求
+		return now.Sub(breaker.LastTrialAt) >= openTimeout()
+	default:
+		return true
+	}
+}
+
+// AllowChannel 用于不带模型的请求（如 Files），渠道下所有有记录的模型都处于熔断中时拒绝
+func (cb *CircuitBreakers) AllowChannel(channelId int) bool {
+	if !common.CircuitBreakerEnabled {
+		return true
+	}
+	cb.Lock()
+	defer cb.Unlock()
+
+	prefix := circuitBreakerKey(channelId, "")
+	now := time.Now()
+	tracked := false
+	for key, breaker := range cb.breakers {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if breaker.allow(now) {
+			return true
+		}
+		tracked = true
+	}
+
+	return !tracked
+}
+
+// Acquire 渠道被选中后调用，熔断超时的渠道进入半开状态并占用试探名额
+func (cb *CircuitBreakers) Acquire(channelId int, modelName string) {
+	if !common.CircuitBreakerEnabled {
+		return
+	}
+	cb.Lock()
+	defer cb.Unlock()
+
+	breaker, ok := cb.breakers[circuitBreakerKey(channelId, modelName)]
+	if !ok || breaker.State == CircuitStateClosed {
+		return
+	}
+
+	if breaker.State == CircuitStateOpen {
+		breaker.State = CircuitStateHalfOpen
+		common.SysLog(fmt.Sprintf("circuit breaker half-open: channel #%d model %s", channelId, modelName))
+	}
+	breaker.LastTrialAt = time.Now()
+}
+
+func (cb *CircuitBreakers) RecordSuccess(channelId int, modelName string) {
+	if !common.CircuitBreakerEnabled {
+		return
+	}
+	cb.Lock()
+	defer cb.Unlock()
+
+	breaker, ok := cb.breakers[circuitBreakerKey(channelId, modelName)]
+	if !ok {
+		return
+	}
+
+	if breaker.State != CircuitStateClosed {
+		common.SysLog(fmt.Sprintf("circuit breaker closed: channel #%d model %s recovered", channelId, modelName))
+	}
+	delete(cb.breakers, circuitBreakerKey(channelId, modelName))
+}
+
+func (cb *CircuitBreakers) RecordFailure(channelId int, modelName string) {
+	if !common.CircuitBreakerEnabled {
+		return
+	}
+	cb.Lock()
+	defer cb.Unlock()
+
+	breaker := cb.get(channelId, modelName)
+	breaker.Failures++
+
+	switch breaker.State {
+	case CircuitStateHalfOpen:
+		// 试探失败，重新熔断并延长熔断时间
+		breaker.OpenTimeout *= 2
+		if maxTimeout := openTimeout() * circuitBreakerMaxOpenMultiple; breaker.OpenTimeout > maxTimeout {
+			breaker.OpenTimeout = maxTimeout
+		}
+	case CircuitStateClosed:
+		if breaker.Failures < common.CircuitBreakerFailureThreshold {
+			return
+		}
+		breaker.OpenTimeout = openTimeout()
+	default:
+		return
+	}
+
+	breaker.State = CircuitStateOpen
+	breaker.OpenedAt = time.Now()
+	common.SysLog(fmt.Sprintf("circuit breaker open: channel #%d model %s, %d failures, retry after %s", channelId, modelName, breaker.Failures, breaker.OpenTimeout))
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"one-api/common"
+	_ "one-api/common/test/init"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	common.CircuitBreakerEnabled = true
+	common.CircuitBreakerFailureThreshold = 3
+	common.CircuitBreakerOpenSeconds = 60
+	defer func() { common.CircuitBreakerEnabled = false }()
+
+	cb := &CircuitBreakers{breakers: make(map[string]*circuitBreaker)}
+	breakerKey := circuitBreakerKey(1, "gpt-4")
+
+	// 连续失败达到阈值后熔断
+	cb.RecordFailure(1, "gpt-4")
+	cb.RecordFailure(1, "gpt-4")
+	assert.True(t, cb.Allow(1, "gpt-4"))
+	cb.RecordFailure(1, "gpt-4")
+	assert.False(t, cb.Allow(1, "gpt-4"))
+	assert.Equal(t, CircuitStateOpen, cb.breakers[breakerKey].State)
+
+	// 只影响同一渠道的该模型
+	assert.True(t, cb.Allow(1, "gpt-3.5-turbo"))
+	assert.True(t, cb.Allow(2, "gpt-4"))
+	assert.False(t, cb.AllowChannel(1))
+	assert.True(t, cb.AllowChannel(2))
+
+	// 熔断超时后放行一个试探请求
+	cb.breakers[breakerKey].OpenedAt = time.Now().Add(-61 * time.Second)
+	assert.True(t, cb.Allow(1, "gpt-4"))
+	cb.Acquire(1, "gpt-4")
+	assert.Equal(t, CircuitStateHalfOpen, cb.breakers[breakerKey].State)
+	assert.False(t, cb.Allow(1, "gpt-4"))
+
+	// 试探失败重新熔断，熔断时间翻倍
+	cb.RecordFailure(1, "gpt-4")
+	assert.Equal(t, CircuitStateOpen, cb.breakers[breakerKey].State)
+	assert.Equal(t, 120*time.Second, cb.breakers[breakerKey].OpenTimeout)
+	cb.breakers[breakerKey].OpenedAt = time.Now().Add(-61 * time.Second)
+	assert.False(t, cb.Allow(1, "gpt-4"))
+
+	// 熔断时间不超过上限
+	for i := 0; i < 10; i++ {
+		cb.breakers[breakerKey].State = CircuitStateHalfOpen
+		cb.RecordFailure(1, "gpt-4")
+	}
+	assert.Equal(t, 60*circuitBreakerMaxOpenMultiple*time.Second, cb.breakers[breakerKey].OpenTimeout)
+
+	// 试探成功后恢复
+	cb.breakers[breakerKey].OpenedAt = time.Now().Add(-time.Hour)
+	cb.Acquire(1, "gpt-4")
+	cb.RecordSuccess(1, "gpt-4")
+	assert.True(t, cb.Allow(1, "gpt-4"))
+	assert.NotContains(t, cb.breakers, breakerKey)
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+)
+
+// File 记录上游文件 ID 所属的用户、令牌及渠道
+type File struct {
+	Id          int    `json:"id"`
+	FileId      string `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	TokenId     int    `json:"token_id"`
+	ChannelId   int    `json:"channel_id" gorm:"index"`
+	KeyId       int    `json:"key_id" gorm:"default:0"` // 多 key 渠道中上传时使用的 key
+	Filename    string `json:"filename" gorm:"type:varchar(255);default:''"`
+	Purpose     string `json:"purpose" gorm:"type:varchar(32);default:''"`
+	Bytes       int64  `json:"bytes" gorm:"bigint;default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func (file *File) Insert() error {
+	if file.CreatedTime == 0 {
+		file.CreatedTime = common.GetTimestamp()
+	}
+	return DB.Create(file).Error
+}
+
+func (file *File) Delete() error {
+	return DB.Delete(file).Error
+}
+
+func GetUserFile(fileId string, userId int) (*File, error) {
+	if fileId == "" || userId == 0 {
+		return nil, errors.New("fileId 或 userId 为空！")
+	}
+	file := &File{}
+	err := DB.First(file, "file_id = ? and user_id = ?", fileId, userId).Error
+	return file, err
+}
+
+func GetUserFiles(userId int, purpose string) (files []*File, err error) {
+	db := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		db = db.Where("purpose = ?", purpose)
+	}
+	err = db.Order("id desc").Find(&files).Error
+	return files, err
+}
@@ -0,0 +1,128 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const FineTuningJobStatusSucceeded = "succeeded"
+
+// 已结束的任务状态，其余状态需要继续同步
+var fineTuningJobFinishedStatus = []string{FineTuningJobStatusSucceeded, "failed", "cancelled"}
+
+// FineTuningJob 记录微调任务所属的用户及渠道，微调完成后模型只能在该渠道上调用
+type FineTuningJob struct {
+	Id             int    `json:"id"`
+	JobId          string `json:"job_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	TokenId        int    `json:"token_id"`
+	ChannelId      int    `json:"channel_id" gorm:"index"`
+	KeyId          int    `json:"key_id" gorm:"default:0"` // 多 key 渠道中创建时使用的 key
+	Group          string `json:"group" gorm:"type:varchar(32);default:'default'"`
+	Model          string `json:"model" gorm:"type:varchar(64);default:''"`
+	FineTunedModel string `json:"fine_tuned_model" gorm:"type:varchar(255);default:''"`
+	Status         string `json:"status" gorm:"type:varchar(32);default:''"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime    int64  `json:"updated_time" gorm:"bigint"`
+}
+
+func (job *FineTuningJob) Insert() error {
+	job.CreatedTime = common.GetTimestamp()
+	job.UpdatedTime = job.CreatedTime
+	return DB.Create(job).Error
+}
+
+// UpdateStatus 同步上游任务状态，任务成功后为所属分组注册微调模型
+func (job *FineTuningJob) UpdateStatus(status string, fineTunedModel string) error {
+	if job.Status == status && job.FineTunedModel == fineTunedModel {
+		return nil
+	}
+
+	registered := job.Status == FineTuningJobStatusSucceeded && job.FineTunedModel != ""
+	job.Status = status
+	job.FineTunedModel = fineTunedModel
+	job.UpdatedTime = common.GetTimestamp()
+	err := DB.Model(job).Select("status", "fine_tuned_model", "updated_time").Updates(job).Error
+	if err != nil {
+		return err
+	}
+
+	if registered || status != FineTuningJobStatusSucceeded || fineTunedModel == "" {
+		return nil
+	}
+
+	return job.addAbility()
+}
+
+func (job *FineTuningJob) addAbility() error {
+	channel, err := GetChannelById(job.ChannelId, true)
+	if err != nil {
+		return err
+	}
+
+	ability := job.toAbility(channel)
+	err = DB.Where(Ability{Group: ability.Group, Model: ability.Model, ChannelId: ability.ChannelId}).FirstOrCreate(&ability).Error
+	if err != nil {
+		return err
+	}
+
+	if common.MemoryCacheEnabled {
+		go InitChannelGroup()
+	}
+
+	return nil
+}
+
+func (job *FineTuningJob) toAbility(channel *Channel) Ability {
+	return Ability{
+		Group:     job.Group,
+		Model:     job.FineTunedModel,
+		ChannelId: channel.Id,
+		Enabled:   channel.Status == common.ChannelStatusEnabled,
+		Priority:  channel.Priority,
+		Weight:    channel.Weight,
+	}
+}
+
+func GetUserFineTuningJob(jobId string, userId int) (*FineTuningJob, error) {
+	if jobId == "" || userId == 0 {
+		return nil, errors.New("jobId 或 userId 为空！")
+	}
+	job := &FineTuningJob{}
+	err := DB.First(job, "job_id = ? and user_id = ?", jobId, userId).Error
+	return job, err
+}
+
+// GetUserFineTuningJobs 按创建时间倒序分页获取用户的任务，after 为上一页最后一个任务的 job id
+func GetUserFineTuningJobs(userId int, after string, limit int) (jobs []*FineTuningJob, err error) {
+	tx := DB.Where("user_id = ?", userId)
+	if after != "" {
+		var afterJob FineTuningJob
+		err = DB.Select("id").Where("job_id = ? and user_id = ?", after, userId).First(&afterJob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return jobs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where("id < ?", afterJob.Id)
+	}
+	err = tx.Order("id desc").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// 获取渠道上已完成的微调任务
+func GetChannelFineTunedJobs(channelId int) (jobs []*FineTuningJob, err error) {
+	err = DB.Where("channel_id = ? and status = ? and fine_tuned_model != ?", channelId, FineTuningJobStatusSucceeded, "").Find(&jobs).Error
+	return jobs, err
+}
+
+// GetUnfinishedFineTuningJobs 按 id 分批获取尚未结束的任务，超过 maxAge 的不再同步
+func GetUnfinishedFineTuningJobs(maxAge time.Duration, afterId int, limit int) (jobs []*FineTuningJob, err error) {
+	err = DB.Where("id > ? and status NOT IN ? and created_time > ?", afterId, fineTuningJobFinishedStatus, common.GetTimestamp()-int64(maxAge.Seconds())).
+		Order("id").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"sort"
+	"time"
+)
+
+var ErrFreeTierExhausted = errors.New("free calls are used out")
+
+// FreeTierUsage 用户在一条免费规则下的剩余次数
+type FreeTierUsage struct {
+	Model     string `json:"model"`
+	Window    string `json:"window"`
+	Limit     int    `json:"limit"`
+	Remaining int    `json:"remaining"`
+	ResetTime int64  `json:"reset_time"`
+}
+
+// FreeTierCounter 本次请求占用的免费次数，请求失败时退回
+type FreeTierCounter struct {
+	key string
+	ttl time.Duration
+}
+
+// getFreeTierRules 分组生效的免费规则，分组内的配置覆盖 * 中同名的模型
+func getFreeTierRules(group string) map[string]common.FreeTierRule {
+	rules := make(map[string]common.FreeTierRule)
+	for model, rule := range common.FreeTier["*"] {
+		rules[model] = rule
+	}
+	for model, rule := range common.FreeTier[group] {
+		rules[model] = rule
+	}
+	return rules
+}
+
+// GetFreeTierRule 优先精确匹配模型名，否则取最长的通配规则
+func GetFreeTierRule(group string, modelName string) (pattern string, rule common.FreeTierRule, ok bool) {
+	rules := getFreeTierRules(group)
+	if rule, ok := rules[modelName]; ok {
+		return modelName, rule, true
+	}
+	for key, value := range rules {
+		if MatchModelPattern(key, modelName) && len(key) > len(pattern) {
+			pattern, rule, ok = key, value, true
+		}
+	}
+	return
+}
+
+func freeTierKey(userId int, pattern string, rule common.FreeTierRule, now time.Time) (string, time.Time) {
+	start, end := common.FreeTierWindow(rule.Window, now)
+	return fmt.Sprintf("free_tier:%d:%s:%s:%d", userId, rule.Window, pattern, start.Unix()), end
+}
+
+// ConsumeFreeTier 为免费模型的调用占用一次免费次数，没有匹配规则或规则不限次数时返回 nil
+func ConsumeFreeTier(userId int, group string, modelName string) (*FreeTierCounter, error) {
+	pattern, rule, ok := GetFreeTierRule(group, modelName)
+	if !ok || rule.Limit <= 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	key, end := freeTierKey(userId, pattern, rule, now)
+	ttl := end.Sub(now)
+	_, allowed, err := common.WindowLimiterAllow(key, 1, int64(rule.Limit), ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrFreeTierExhausted
+	}
+	return &FreeTierCounter{key: key, ttl: ttl}, nil
+}
+
+func (counter *FreeTierCounter) Refund() error {
+	return common.WindowLimiterAdd(counter.key, -1, counter.ttl)
+}
+
+// GetUserFreeTierUsages 用户所在分组每条免费规则在当前窗口的剩余次数
+func GetUserFreeTierUsages(userId int, group string) ([]*FreeTierUsage, error) {
+	now := time.Now()
+	usages := make([]*FreeTierUsage, 0)
+	for pattern, rule := range getFreeTierRules(group) {
+		if rule.Limit <= 0 {
+			continue
+		}
+		key, end := freeTierKey(userId, pattern, rule, now)
+		used, err := common.WindowLimiterGet(key)
+		if err != nil {
+			return nil, err
+		}
+		remaining := rule.Limit - int(used)
+		if remaining < 0 {
+			remaining = 0
+		}
+		usages = append(usages, &FreeTierUsage{
+			Model:     pattern,
+			Window:    rule.Window,
+			Limit:     rule.Limit,
+			Remaining: remaining,
+			ResetTime: end.Unix(),
+		})
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].Model < usages[j].Model
+	})
+	return usages, nil
+}
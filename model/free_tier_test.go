@@ -0,0 +1,79 @@
+package model_test
+
+import (
+	"one-api/common"
+	_ "one-api/common/test/init"
+	"one-api/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumeFreeTier(t *testing.T) {
+	common.RedisEnabled = false
+	defer func() { common.FreeTier = map[string]map[string]common.FreeTierRule{} }()
+
+	// 默认不限制免费模型的调用次数
+	counter, err := model.ConsumeFreeTier(1001, "default", "glm-4")
+	assert.Nil(t, err)
+	assert.Nil(t, counter)
+
+	assert.NotNil(t, common.UpdateFreeTierByJSONString(`{"*":{"*":{"limit":2,"window":"week"}}}`))
+	assert.Nil(t, common.UpdateFreeTierByJSONString(`{
+		"*": {"*": {"limit": 2, "window": "day"}},
+		"default": {"gpt-3.5*": {"limit": 1, "window": "hour"}},
+		"vip": {"*": {"limit": 0, "window": "day"}}
+	}`))
+
+	// 最长的通配规则优先
+	counter, err = model.ConsumeFreeTier(1001, "default", "gpt-3.5-turbo")
+	assert.Nil(t, err)
+	assert.NotNil(t, counter)
+	_, err = model.ConsumeFreeTier(1001, "default", "gpt-3.5-turbo")
+	assert.ErrorIs(t, err, model.ErrFreeTierExhausted)
+
+	// 请求失败时退回
+	assert.Nil(t, counter.Refund())
+	_, err = model.ConsumeFreeTier(1001, "default", "gpt-3.5-turbo")
+	assert.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = model.ConsumeFreeTier(1001, "default", "glm-4")
+		assert.Nil(t, err)
+	}
+	_, err = model.ConsumeFreeTier(1001, "default", "glm-4")
+	assert.ErrorIs(t, err, model.ErrFreeTierExhausted)
+
+	// 按用户分别计数
+	_, err = model.ConsumeFreeTier(1002, "default", "glm-4")
+	assert.Nil(t, err)
+
+	// 分组中 limit 为 0 时覆盖 * 的规则
+	counter, err = model.ConsumeFreeTier(1001, "vip", "glm-4")
+	assert.Nil(t, err)
+	assert.Nil(t, counter)
+
+	usages, err := model.GetUserFreeTierUsages(1001, "default")
+	assert.Nil(t, err)
+	assert.Len(t, usages, 2)
+	assert.Equal(t, "*", usages[0].Model)
+	assert.Equal(t, 0, usages[0].Remaining)
+	assert.Equal(t, "gpt-3.5*", usages[1].Model)
+	assert.Equal(t, 0, usages[1].Remaining)
+	assert.Greater(t, usages[1].ResetTime, time.Now().Unix())
+	assert.LessOrEqual(t, usages[1].ResetTime, time.Now().Add(time.Hour).Unix())
+}
+
+func TestFreeTierWindow(t *testing.T) {
+	assert.Nil(t, common.UpdateFreeTierTimezone("Asia/Shanghai"))
+	now := time.Date(2024, 3, 1, 23, 30, 0, 0, time.UTC) // 北京时间 3 月 2 日 07:30
+
+	start, end := common.FreeTierWindow(common.FreeTierWindowDay, now)
+	assert.Equal(t, "2024-03-02 00:00", start.Format("2006-01-02 15:04"))
+	assert.Equal(t, 24*time.Hour, end.Sub(start))
+
+	start, end = common.FreeTierWindow(common.FreeTierWindowHour, now)
+	assert.Equal(t, "2024-03-02 07:00", start.Format("2006-01-02 15:04"))
+	assert.Equal(t, time.Hour, end.Sub(start))
+}
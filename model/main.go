@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"one-api/common"
+	"one-api/common/metrics"
 	"os"
 	"strings"
 	"time"
@@ -74,6 +75,9 @@ func InitDB() (err error) {
 			db = db.Debug()
 		}
 		DB = db
+		if err := DB.Use(&metrics.GormPlugin{}); err != nil {
+			return err
+		}
 		sqlDB, err := DB.DB()
 		if err != nil {
 			return err
@@ -122,7 +126,48 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
-		err = db.AutoMigrate(&FreeTimes{})
+		// 支持多个支付网关之前的订单都来自易支付
+		err = db.Model(&RechargeLog{}).Where("gateway = '' OR gateway IS NULL").Update("gateway", "epay").Error
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&RedemptionCampaign{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&RedemptionRecord{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&Plan{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&Subscription{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&AssistantMapping{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&File{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&FineTuningJob{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&ChannelKey{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&TokenModelUsage{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&QuotaAlert{})
 		if err != nil {
 			return err
 		}
@@ -78,6 +78,29 @@ func InitOptionMap() {
 	common.OptionMap["QuotaPerUnit"] = strconv.FormatFloat(common.QuotaPerUnit, 'f', -1, 64)
 	common.OptionMap["RetryTimes"] = strconv.Itoa(common.RetryTimes)
 	common.OptionMap["RetryCooldownSeconds"] = strconv.Itoa(common.RetryCooldownSeconds)
+	common.OptionMap["RetryCooldownMaxSeconds"] = strconv.Itoa(common.RetryCooldownMaxSeconds)
+	common.OptionMap["GroupBalancer"] = common.GroupBalancer2JSONString()
+	common.OptionMap["CircuitBreakerEnabled"] = strconv.FormatBool(common.CircuitBreakerEnabled)
+	common.OptionMap["CircuitBreakerFailureThreshold"] = strconv.Itoa(common.CircuitBreakerFailureThreshold)
+	common.OptionMap["CircuitBreakerOpenSeconds"] = strconv.Itoa(common.CircuitBreakerOpenSeconds)
+	common.OptionMap["ResponseCacheEnabled"] = strconv.FormatBool(common.ResponseCacheEnabled)
+	common.OptionMap["ResponseCacheSeconds"] = strconv.Itoa(common.ResponseCacheSeconds)
+	common.OptionMap["ResponseCacheRatio"] = strconv.FormatFloat(common.ResponseCacheRatio, 'f', -1, 64)
+	common.OptionMap["ResponseCacheGroups"] = strings.Join(common.ResponseCacheGroups, ",")
+	common.OptionMap["GroupRateLimit"] = common.GroupRateLimit2JSONString()
+	common.OptionMap["FreeTier"] = common.FreeTier2JSONString()
+	common.OptionMap["FreeTierTimezone"] = common.FreeTierTimezone
+	common.OptionMap["ConcurrencyQueueSize"] = strconv.Itoa(common.ConcurrencyQueueSize)
+	common.OptionMap["ConcurrencyQueueSeconds"] = strconv.Itoa(common.ConcurrencyQueueSeconds)
+	common.OptionMap["PaymentExchangeRate"] = strconv.FormatFloat(common.PaymentExchangeRate, 'f', -1, 64)
+	common.OptionMap["PaymentMinAmount"] = strconv.Itoa(common.PaymentMinAmount)
+	common.OptionMap["PaymentMaxAmount"] = strconv.Itoa(common.PaymentMaxAmount)
+	common.OptionMap["PaymentCallbackAddress"] = common.PaymentCallbackAddress
+	common.OptionMap["PaymentOrderExpireMinutes"] = strconv.Itoa(common.PaymentOrderExpireMinutes)
+	common.OptionMap["EpayEnabled"] = strconv.FormatBool(common.EpayEnabled)
+	common.OptionMap["EpayAddress"] = common.EpayAddress
+	common.OptionMap["EpayPid"] = common.EpayPid
+	common.OptionMap["EpaySecret"] = ""
 
 	common.OptionMapRWMutex.Unlock()
 	initModelRatio()
@@ -137,18 +160,27 @@ func UpdateOption(key string, value string) error {
 }
 
 var optionIntMap = map[string]*int{
-	"FileUploadPermission":    &common.FileUploadPermission,
-	"FileDownloadPermission":  &common.FileDownloadPermission,
-	"ImageUploadPermission":   &common.ImageUploadPermission,
-	"ImageDownloadPermission": &common.ImageDownloadPermission,
-	"SMTPPort":                &common.SMTPPort,
-	"QuotaForNewUser":         &common.QuotaForNewUser,
-	"QuotaForInviter":         &common.QuotaForInviter,
-	"QuotaForInvitee":         &common.QuotaForInvitee,
-	"QuotaRemindThreshold":    &common.QuotaRemindThreshold,
-	"PreConsumedQuota":        &common.PreConsumedQuota,
-	"RetryTimes":              &common.RetryTimes,
-	"RetryCooldownSeconds":    &common.RetryCooldownSeconds,
+	"FileUploadPermission":           &common.FileUploadPermission,
+	"FileDownloadPermission":         &common.FileDownloadPermission,
+	"ImageUploadPermission":          &common.ImageUploadPermission,
+	"ImageDownloadPermission":        &common.ImageDownloadPermission,
+	"SMTPPort":                       &common.SMTPPort,
+	"QuotaForNewUser":                &common.QuotaForNewUser,
+	"QuotaForInviter":                &common.QuotaForInviter,
+	"QuotaForInvitee":                &common.QuotaForInvitee,
+	"QuotaRemindThreshold":           &common.QuotaRemindThreshold,
+	"PreConsumedQuota":               &common.PreConsumedQuota,
+	"RetryTimes":                     &common.RetryTimes,
+	"RetryCooldownSeconds":           &common.RetryCooldownSeconds,
+	"RetryCooldownMaxSeconds":        &common.RetryCooldownMaxSeconds,
+	"CircuitBreakerFailureThreshold": &common.CircuitBreakerFailureThreshold,
+	"CircuitBreakerOpenSeconds":      &common.CircuitBreakerOpenSeconds,
+	"ResponseCacheSeconds":           &common.ResponseCacheSeconds,
+	"ConcurrencyQueueSize":           &common.ConcurrencyQueueSize,
+	"ConcurrencyQueueSeconds":        &common.ConcurrencyQueueSeconds,
+	"PaymentMinAmount":               &common.PaymentMinAmount,
+	"PaymentMaxAmount":               &common.PaymentMaxAmount,
+	"PaymentOrderExpireMinutes":      &common.PaymentOrderExpireMinutes,
 }
 
 var optionBoolMap = map[string]*bool{
@@ -166,6 +198,9 @@ var optionBoolMap = map[string]*bool{
 	"LogConsumeEnabled":              &common.LogConsumeEnabled,
 	"DisplayInCurrencyEnabled":       &common.DisplayInCurrencyEnabled,
 	"DisplayTokenStatEnabled":        &common.DisplayTokenStatEnabled,
+	"CircuitBreakerEnabled":          &common.CircuitBreakerEnabled,
+	"ResponseCacheEnabled":           &common.ResponseCacheEnabled,
+	"EpayEnabled":                    &common.EpayEnabled,
 }
 
 var optionStringMap = map[string]*string{
@@ -186,6 +221,10 @@ var optionStringMap = map[string]*string{
 	"TurnstileSecretKey":          &common.TurnstileSecretKey,
 	"TopUpLink":                   &common.TopUpLink,
 	"ChatLink":                    &common.ChatLink,
+	"PaymentCallbackAddress":      &common.PaymentCallbackAddress,
+	"EpayAddress":                 &common.EpayAddress,
+	"EpayPid":                     &common.EpayPid,
+	"EpaySecret":                  &common.EpaySecret,
 }
 
 func updateOptionMap(key string, value string) (err error) {
@@ -214,10 +253,24 @@ func updateOptionMap(key string, value string) (err error) {
 		err = common.UpdateModelRatioByJSONString(value)
 	case "GroupRatio":
 		err = common.UpdateGroupRatioByJSONString(value)
+	case "GroupBalancer":
+		err = common.UpdateGroupBalancerByJSONString(value)
 	case "ChannelDisableThreshold":
 		common.ChannelDisableThreshold, _ = strconv.ParseFloat(value, 64)
 	case "QuotaPerUnit":
 		common.QuotaPerUnit, _ = strconv.ParseFloat(value, 64)
+	case "PaymentExchangeRate":
+		common.PaymentExchangeRate, _ = strconv.ParseFloat(value, 64)
+	case "ResponseCacheRatio":
+		common.ResponseCacheRatio, _ = strconv.ParseFloat(value, 64)
+	case "ResponseCacheGroups":
+		common.ResponseCacheGroups = common.SplitAndTrim(value, ",")
+	case "GroupRateLimit":
+		err = common.UpdateGroupRateLimitByJSONString(value)
+	case "FreeTier":
+		err = common.UpdateFreeTierByJSONString(value)
+	case "FreeTierTimezone":
+		err = common.UpdateFreeTierTimezone(value)
 	}
 	return err
 }
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+)
+
+// Plan 订阅套餐，用户购买后在订阅期间切换到 Group，并在每个周期开始时获得 Quota
+type Plan struct {
+	Id          int     `json:"id"`
+	Name        string  `json:"name" gorm:"type:varchar(64)"`
+	Description string  `json:"description" gorm:"type:varchar(255);default:''"`
+	Status      int     `json:"status" gorm:"default:1"`
+	Group       string  `json:"group" gorm:"type:varchar(32);default:''"` // 订阅期间用户所在的分组，为空时不切换
+	Quota       int     `json:"quota"`                                    // 每个周期发放的额度
+	Price       float64 `json:"price"`                                    // 每个周期的价格，单位 $，下单时按 PaymentExchangeRate 换算
+	CycleDays   int     `json:"cycle_days"`
+	CreatedTime int64   `json:"created_time" gorm:"bigint"`
+}
+
+var allowedPlansOrderFields = map[string]bool{
+	"id":           true,
+	"name":         true,
+	"status":       true,
+	"price":        true,
+	"created_time": true,
+}
+
+func GetPlansList(params *GenericParams) (*DataResult[Plan], error) {
+	var plans []*Plan
+	db := DB
+	if params.Keyword != "" {
+		db = db.Where("id = ? or name LIKE ?", common.String2Int(params.Keyword), params.Keyword+"%")
+	}
+
+	return PaginateAndOrder[Plan](db, &params.PaginationParams, &plans, allowedPlansOrderFields)
+}
+
+// GetEnabledPlans 用户可购买的套餐，按价格升序
+func GetEnabledPlans() (plans []*Plan, err error) {
+	err = DB.Where("status = ?", common.PlanStatusEnabled).Order("price, id").Find(&plans).Error
+	return plans, err
+}
+
+func GetPlanById(id int) (*Plan, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	plan := Plan{Id: id}
+	err := DB.First(&plan, "id = ?", id).Error
+	return &plan, err
+}
+
+func (plan *Plan) Validate() error {
+	if len(plan.Name) == 0 || len(plan.Name) > 64 {
+		return errors.New("套餐名称长度必须在1-64之间")
+	}
+	if plan.Price <= 0 {
+		return errors.New("套餐价格必须大于0")
+	}
+	if plan.CycleDays <= 0 {
+		return errors.New("周期天数必须大于0")
+	}
+	if plan.Quota < 0 {
+		return errors.New("额度不能为负数")
+	}
+	if plan.Group != "" {
+		if _, ok := common.GroupRatio[plan.Group]; !ok {
+			return errors.New("分组不存在")
+		}
+	}
+	return nil
+}
+
+// dailyPrice 每天的价格，用于判断是否为升级
+func (plan *Plan) dailyPrice() float64 {
+	return plan.Price / float64(plan.CycleDays)
+}
+
+func (plan *Plan) Insert() error {
+	plan.CreatedTime = common.GetTimestamp()
+	return DB.Create(plan).Error
+}
+
+// Update 已有的订阅保存了购买时的分组、额度及周期，修改套餐只影响之后的购买
+func (plan *Plan) Update() error {
+	return DB.Model(plan).Select("name", "description", "status", "group", "quota", "price", "cycle_days").Updates(plan).Error
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"one-api/common"
+
+	"gorm.io/gorm/clause"
+)
+
+// 额度提醒的类型
+const (
+	QuotaAlertUserQuotaLow  = "user_quota_low"
+	QuotaAlertUserExhausted = "user_quota_exhausted"
+	QuotaAlertTokenQuotaLow = "token_quota_low"
+	QuotaAlertTokenBudget   = "token_budget"
+)
+
+// QuotaAlert 已发送的额度提醒，同一用户（令牌）的同一类提醒在一个周期内只发送一次
+type QuotaAlert struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"uniqueIndex:idx_quota_alert"`
+	TokenId     int    `json:"token_id" gorm:"uniqueIndex:idx_quota_alert"` // 用户级别的提醒为 0
+	Type        string `json:"type" gorm:"type:varchar(32);uniqueIndex:idx_quota_alert"`
+	Period      string `json:"period" gorm:"type:varchar(32);uniqueIndex:idx_quota_alert"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+// RecordQuotaAlert 记录本周期的提醒，已经记录过时返回 false
+func RecordQuotaAlert(userId int, tokenId int, alertType string, period string) (bool, error) {
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&QuotaAlert{
+		UserId:      userId,
+		TokenId:     tokenId,
+		Type:        alertType,
+		Period:      period,
+		CreatedTime: common.GetTimestamp(),
+	})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,214 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"one-api/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RechargeLog 在线充值订单，状态见 common.RechargeStatus*
+// 待支付 -> 已支付 -> 已退款，待支付 -> 已关闭，关闭后仍收到支付成功的通知时照常入账
+type RechargeLog struct {
+	Id             int     `json:"id"`
+	UserId         int     `json:"user_id" gorm:"index"`
+	TradeNo        string  `json:"trade_no" gorm:"type:char(20);uniqueIndex"`
+	Status         int     `json:"status" gorm:"default:1;index"`
+	Name           string  `json:"name" gorm:"index"`
+	RedeemedTime   int64   `json:"redeemed_time" gorm:"bigint"` // 支付完成时间
+	CreatedTime    int64   `json:"created_time" gorm:"bigint"`
+	UpdatedTime    int64   `json:"updated_time" gorm:"bigint"` // 最近一次状态变更的时间
+	Quota          int     `json:"quota"`                      // 充值的额度，订阅订单为 0
+	Gateway        string  `json:"gateway" gorm:"type:varchar(32)"`
+	PayType        string  `json:"pay_type" gorm:"type:varchar(32)"`
+	Money          float64 `json:"money"`                                    // 应付金额
+	GatewayTradeNo string  `json:"gateway_trade_no" gorm:"type:varchar(64)"` // 网关的交易号
+	PlanId         int     `json:"plan_id" gorm:"default:0"`                 // 订阅套餐的订单，支付后开通订阅而不是充值额度
+	Cycles         int     `json:"cycles" gorm:"default:0"`
+}
+
+var ErrRechargePaid = errors.New("订单已支付！")
+
+var allowedRechargeLogsOrderFields = map[string]bool{
+	"id":            true,
+	"user_id":       true,
+	"status":        true,
+	"quota":         true,
+	"money":         true,
+	"created_time":  true,
+	"redeemed_time": true,
+}
+
+type RechargeLogsListParams struct {
+	PaginationParams
+	UserId  int    `form:"user_id"`
+	Status  int    `form:"status"`
+	TradeNo string `form:"trade_no"`
+	Gateway string `form:"gateway"`
+}
+
+func GetRechargeLogsList(params *RechargeLogsListParams) (*DataResult[RechargeLog], error) {
+	var rechargeLogs []*RechargeLog
+	tx := DB
+	if params.UserId != 0 {
+		tx = tx.Where("user_id = ?", params.UserId)
+	}
+	return PaginateAndOrder[RechargeLog](filterRechargeLogs(tx, params), &params.PaginationParams, &rechargeLogs, allowedRechargeLogsOrderFields)
+}
+
+func GetUserRechargeLogsList(userId int, params *RechargeLogsListParams) (*DataResult[RechargeLog], error) {
+	var rechargeLogs []*RechargeLog
+	tx := DB.Where("user_id = ?", userId)
+	return PaginateAndOrder[RechargeLog](filterRechargeLogs(tx, params), &params.PaginationParams, &rechargeLogs, allowedRechargeLogsOrderFields)
+}
+
+func filterRechargeLogs(tx *gorm.DB, params *RechargeLogsListParams) *gorm.DB {
+	if params.Status != 0 {
+		tx = tx.Where("status = ?", params.Status)
+	}
+	if params.TradeNo != "" {
+		tx = tx.Where("trade_no = ?", params.TradeNo)
+	}
+	if params.Gateway != "" {
+		tx = tx.Where("gateway = ?", params.Gateway)
+	}
+	return tx
+}
+
+func GetRechargeLogByTradeNo(tradeNo string) (*RechargeLog, error) {
+	var rechargeLog RechargeLog
+	err := DB.Where("trade_no = ?", tradeNo).First(&rechargeLog).Error
+	return &rechargeLog, err
+}
+
+// CreateRechargeLog 生成交易号并创建待支付的订单
+func CreateRechargeLog(rechargeLog *RechargeLog) error {
+	if rechargeLog.UserId == 0 {
+		return errors.New("无效的 user id")
+	}
+	rand.Seed(time.Now().UnixNano())
+	// 生成交易号：年月日时分秒+随机数
+	rechargeLog.TradeNo = fmt.Sprintf("%s%d", time.Now().Format("20060102150405"), rand.Intn(999999-100000)+100000)
+	rechargeLog.Status = common.RechargeStatusPending
+	if rechargeLog.Name == "" {
+		rechargeLog.Name = "充值"
+	}
+	rechargeLog.CreatedTime = common.GetTimestamp()
+	rechargeLog.UpdatedTime = rechargeLog.CreatedTime
+	return DB.Create(rechargeLog).Error
+}
+
+// CompleteRecharge 支付成功后入账，订单状态以条件更新的方式切换，重复的通知不会重复入账
+// money 为网关通知的实付金额，为 0 时不校验
+func CompleteRecharge(tradeNo string, gatewayTradeNo string, money float64) (*RechargeLog, error) {
+	rechargeLog, err := GetRechargeLogByTradeNo(tradeNo)
+	if err != nil {
+		return nil, errors.New("订单不存在")
+	}
+	if rechargeLog.Status == common.RechargeStatusPaid || rechargeLog.Status == common.RechargeStatusRefunded {
+		return rechargeLog, ErrRechargePaid
+	}
+	if money > 0 && rechargeLog.Money > 0 && math.Abs(money-rechargeLog.Money) >= 0.01 {
+		return rechargeLog, fmt.Errorf("支付金额 %.2f 与订单金额 %.2f 不符", money, rechargeLog.Money)
+	}
+
+	now := common.GetTimestamp()
+	logContent := fmt.Sprintf("在线充值成功，充值额度: %s，支付金额: %.2f，订单号: %s", common.LogQuota(rechargeLog.Quota), rechargeLog.Money, tradeNo)
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&RechargeLog{}).
+			Where("id = ? AND status IN ?", rechargeLog.Id, []int{common.RechargeStatusPending, common.RechargeStatusExpired}).
+			Updates(map[string]interface{}{
+				"status":           common.RechargeStatusPaid,
+				"gateway_trade_no": gatewayTradeNo,
+				"redeemed_time":    now,
+				"updated_time":     now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRechargePaid
+		}
+		if rechargeLog.PlanId != 0 {
+			content, err := activateSubscription(tx, rechargeLog)
+			logContent = content
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", rechargeLog.UserId).Update("quota", gorm.Expr("quota + ?", rechargeLog.Quota)).Error
+	})
+	if err != nil {
+		return rechargeLog, err
+	}
+
+	rechargeLog.Status = common.RechargeStatusPaid
+	rechargeLog.GatewayTradeNo = gatewayTradeNo
+	rechargeLog.RedeemedTime = now
+	rechargeLog.UpdatedTime = now
+	if err := CacheUpdateUserQuota(rechargeLog.UserId); err != nil {
+		common.SysError("failed to update user quota cache: " + err.Error())
+	}
+	if rechargeLog.PlanId != 0 {
+		ClearUserGroupCache(rechargeLog.UserId)
+	}
+	RecordLog(rechargeLog.UserId, LogTypeTopup, logContent)
+	return rechargeLog, nil
+}
+
+// ExpireRechargeLog 关闭超时未支付的订单
+func ExpireRechargeLog(id int) error {
+	return DB.Model(&RechargeLog{}).Where("id = ? AND status = ?", id, common.RechargeStatusPending).Updates(map[string]interface{}{
+		"status":       common.RechargeStatusExpired,
+		"updated_time": common.GetTimestamp(),
+	}).Error
+}
+
+// RefundRechargeLog 将已支付的订单标记为退款并扣回充值的额度，网关侧的退款需要另行处理
+func RefundRechargeLog(id int) (*RechargeLog, error) {
+	var rechargeLog RechargeLog
+	if err := DB.First(&rechargeLog, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if rechargeLog.Status != common.RechargeStatusPaid {
+		return nil, errors.New("只有已支付的订单可以退款")
+	}
+	if rechargeLog.PlanId != 0 {
+		return nil, errors.New("订阅订单不支持退款")
+	}
+
+	now := common.GetTimestamp()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&RechargeLog{}).Where("id = ? AND status = ?", id, common.RechargeStatusPaid).Updates(map[string]interface{}{
+			"status":       common.RechargeStatusRefunded,
+			"updated_time": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("订单状态已变更")
+		}
+		return tx.Model(&User{}).Where("id = ?", rechargeLog.UserId).Update("quota", gorm.Expr("quota - ?", rechargeLog.Quota)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rechargeLog.Status = common.RechargeStatusRefunded
+	rechargeLog.UpdatedTime = now
+	if err := CacheUpdateUserQuota(rechargeLog.UserId); err != nil {
+		common.SysError("failed to update user quota cache: " + err.Error())
+	}
+	RecordLog(rechargeLog.UserId, LogTypeManage, fmt.Sprintf("充值订单 %s 已退款，扣除额度 %s", rechargeLog.TradeNo, common.LogQuota(rechargeLog.Quota)))
+	return &rechargeLog, nil
+}
+
+// GetStaleRechargeLogs 创建时间早于 before 仍未支付的订单
+func GetStaleRechargeLogs(before int64, afterId int, limit int) (rechargeLogs []*RechargeLog, err error) {
+	err = DB.Where("status = ? AND created_time < ? AND id > ?", common.RechargeStatusPending, before, afterId).
+		Order("id").Limit(limit).Find(&rechargeLogs).Error
+	return rechargeLogs, err
+}
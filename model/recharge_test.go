@@ -0,0 +1,65 @@
+package model_test
+
+import (
+	"one-api/common"
+	"one-api/common/test"
+	_ "one-api/common/test/init"
+	"one-api/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompleteRecharge(t *testing.T) {
+	test.InitTestDB(t)
+	user := test.CreateTestUser(t, "recharge", 0)
+
+	rechargeLog := &model.RechargeLog{UserId: user.Id, Quota: 500000, Gateway: "epay", Money: 1}
+	assert.Nil(t, model.CreateRechargeLog(rechargeLog))
+	assert.Equal(t, common.RechargeStatusPending, rechargeLog.Status)
+
+	// 实付金额不符时不入账
+	_, err := model.CompleteRecharge(rechargeLog.TradeNo, "gw-1", 0.5)
+	assert.NotNil(t, err)
+	quota, _ := model.GetUserQuota(user.Id)
+	assert.Equal(t, 0, quota)
+
+	paid, err := model.CompleteRecharge(rechargeLog.TradeNo, "gw-1", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, common.RechargeStatusPaid, paid.Status)
+	assert.Equal(t, "gw-1", paid.GatewayTradeNo)
+
+	// 重复的通知不会重复入账
+	_, err = model.CompleteRecharge(rechargeLog.TradeNo, "gw-1", 1)
+	assert.ErrorIs(t, err, model.ErrRechargePaid)
+	quota, _ = model.GetUserQuota(user.Id)
+	assert.Equal(t, 500000, quota)
+
+	_, err = model.CompleteRecharge("not-exists", "gw-2", 1)
+	assert.NotNil(t, err)
+}
+
+func TestCompleteExpiredRecharge(t *testing.T) {
+	test.InitTestDB(t)
+	user := test.CreateTestUser(t, "expired", 0)
+
+	rechargeLog := &model.RechargeLog{UserId: user.Id, Quota: 1000, Gateway: "epay", Money: 2}
+	assert.Nil(t, model.CreateRechargeLog(rechargeLog))
+	assert.Nil(t, model.ExpireRechargeLog(rechargeLog.Id))
+
+	// 关闭后仍收到支付成功的通知时照常入账
+	_, err := model.CompleteRecharge(rechargeLog.TradeNo, "gw-1", 0)
+	assert.Nil(t, err)
+	quota, _ := model.GetUserQuota(user.Id)
+	assert.Equal(t, 1000, quota)
+
+	refunded, err := model.RefundRechargeLog(rechargeLog.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, common.RechargeStatusRefunded, refunded.Status)
+	quota, _ = model.GetUserQuota(user.Id)
+	assert.Equal(t, 0, quota)
+
+	// 已退款的订单不能再次入账
+	_, err = model.CompleteRecharge(rechargeLog.TradeNo, "gw-1", 0)
+	assert.ErrorIs(t, err, model.ErrRechargePaid)
+}
@@ -1,22 +1,12 @@
 package model
 
 import (
-	"crypto/md5"
-	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"math/rand"
-	"net/http"
-	"net/url"
 	"one-api/common"
-	"os"
-	"slices"
-	"strings"
-	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Redemption struct {
@@ -28,18 +18,14 @@ type Redemption struct {
 	Quota        int    `json:"quota" gorm:"default:100"`
 	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
 	RedeemedTime int64  `json:"redeemed_time" gorm:"bigint"`
-	Count        int    `json:"count" gorm:"-:all"` // only for api request
+	CampaignId   int    `json:"campaign_id" gorm:"index;default:0"` // 所属的活动，0 为单独生成的兑换码
+	UsedCount    int    `json:"used_count" gorm:"default:0"`        // 已被兑换的次数
+	Count        int    `json:"count" gorm:"-:all"`                 // only for api request
 }
 
-type RechargeLog struct {
-	Id           int    `json:"id"`
-	UserId       int    `json:"user_id"`
-	TradeNo      string `json:"trade_no" gorm:"type:char(20);uniqueIndex"`
-	Status       int    `json:"status" gorm:"default:1"` //1:未支付，2：已支付
-	Name         string `json:"name" gorm:"index"`
-	RedeemedTime int64  `json:"redeemed_time" gorm:"bigint"`
-	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
-	Quota        int    `json:"quota" gorm:"default:100"`
+type RedemptionsListParams struct {
+	GenericParams
+	CampaignId int `form:"campaign_id"`
 }
 
 var allowedRedemptionslOrderFields = map[string]bool{
@@ -51,12 +37,15 @@ var allowedRedemptionslOrderFields = map[string]bool{
 	"redeemed_time": true,
 }
 
-func GetRedemptionsList(params *GenericParams) (*DataResult[Redemption], error) {
+func GetRedemptionsList(params *RedemptionsListParams) (*DataResult[Redemption], error) {
 	var redemptions []*Redemption
 	db := DB
 	if params.Keyword != "" {
 		db = db.Where("id = ? or name LIKE ?", common.String2Int(params.Keyword), params.Keyword+"%")
 	}
+	if params.CampaignId != 0 {
+		db = db.Where("campaign_id = ?", params.CampaignId)
+	}
 
 	return PaginateAndOrder[Redemption](db, &params.PaginationParams, &redemptions, allowedRedemptionslOrderFields)
 }
@@ -71,6 +60,7 @@ func GetRedemptionById(id int) (*Redemption, error) {
 	return &redemption, err
 }
 
+// Redeem 兑换码按次数扣减，活动的兑换码还需校验有效期、每个用户的兑换次数，并按配置临时升级分组
 func Redeem(key string, userId int) (quota int, err error) {
 	if key == "" {
 		return 0, errors.New("未提供兑换码")
@@ -79,6 +69,8 @@ func Redeem(key string, userId int) (quota int, err error) {
 		return 0, errors.New("无效的 user id")
 	}
 	redemption := &Redemption{}
+	campaign := &RedemptionCampaign{MaxUses: 1}
+	var groupExpireTime int64
 
 	keyCol := "`key`"
 	if common.UsingPostgreSQL {
@@ -86,210 +78,85 @@ func Redeem(key string, userId int) (quota int, err error) {
 	}
 
 	err = DB.Transaction(func(tx *gorm.DB) error {
-		err := tx.Set("gorm:query_option", "FOR UPDATE").Where(keyCol+" = ?", key).First(redemption).Error
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(keyCol+" = ?", key).First(redemption).Error
 		if err != nil {
 			return errors.New("无效的兑换码")
 		}
 		if redemption.Status != common.RedemptionCodeStatusEnabled {
 			return errors.New("该兑换码已被使用")
 		}
-		err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
-		if err != nil {
-			return err
+		if redemption.CampaignId != 0 {
+			if err := tx.First(campaign, "id = ?", redemption.CampaignId).Error; err != nil {
+				return errors.New("兑换码所属的活动不存在")
+			}
+			if campaign.Status != common.RedemptionCodeStatusEnabled {
+				return errors.New("该活动已结束")
+			}
+			if campaign.IsExpired() {
+				return errors.New("该兑换码已过期")
+			}
+			if campaign.MaxUsesPerUser > 0 {
+				// 锁住用户行，同一用户并发兑换同一活动的兑换码时依次计数
+				if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&User{}, "id = ?", userId).Error; err != nil {
+					return errors.New("用户不存在")
+				}
+				var count int64
+				err := tx.Model(&RedemptionRecord{}).Where("campaign_id = ? AND user_id = ?", campaign.Id, userId).Count(&count).Error
+				if err != nil {
+					return err
+				}
+				if count >= int64(campaign.MaxUsesPerUser) {
+					return errors.New("已达到该活动的兑换次数上限")
+				}
+			}
 		}
-		redemption.RedeemedTime = common.GetTimestamp()
-		redemption.Status = common.RedemptionCodeStatusUsed
-		err = tx.Save(redemption).Error
-		return err
-	})
-	if err != nil {
-		return 0, errors.New("兑换失败，" + err.Error())
-	}
-	RecordLog(userId, LogTypeTopup, fmt.Sprintf("通过兑换码充值 %s", common.LogQuota(redemption.Quota)))
-	return redemption.Quota, nil
-}
-
-type RechargeResponse struct {
-	Code      int    `json:"code"`
-	Msg       string `json:"msg,omitempty"`
-	TradeNo   string `json:"trade_no"`
-	PayUrl    string `json:"payurl,omitempty"`
-	QRcode    string `json:"qrcode,omitempty"`
-	UrlScheme string `json:"urlscheme,omitempty"`
-}
-
-func InitRecharge(quota int, payType string, userId int) (payUrl string, TradeNo string, err error) {
-	if quota < 1 {
-		RecordLog(userId, LogTypeTopup, "至少充值1$")
-		return "", "", errors.New("至少充值1$")
-
-	}
-	if quota > 50 {
-		RecordLog(userId, LogTypeTopup, "单次最多充值50$")
-		return "", "", errors.New("单次最多充值50$")
-	}
-	if userId == 0 {
-		RecordLog(userId, LogTypeTopup, "无效的 user id")
-		return "", "", errors.New("无效的 user id")
-	}
-	if (payType != "wxpay") && (payType != "alipay") {
-		RecordLog(userId, LogTypeTopup, "无效的支付方式")
-		return "", "", errors.New("无效的支付方式")
-	}
-	RecordLog(userId, LogTypeTopup, fmt.Sprintf("创建充值请求，请求金额: %d$", quota))
-	rand.Seed(time.Now().UnixNano())
-	// 生成交易号：年月日时分秒+随机数
-	transactionID := fmt.Sprintf("%s%d", time.Now().Format("20060102150405"), rand.Intn(999999-100000)+100000)
-	initRechargeLog := RechargeLog{
-		UserId:      userId,
-		TradeNo:     transactionID,
-		Status:      1,
-		Name:        "充值",
-		Quota:       quota * int(common.QuotaPerUnit),
-		CreatedTime: common.GetTimestamp(),
-	}
-	DB.Create(&initRechargeLog)
-	paymentData := map[string]string{
-		"pid":          os.Getenv("YI_PAY_PID"),
-		"type":         payType,
-		"out_trade_no": transactionID,
-		"notify_url":   "https://platform.hustgpt.com/api/user/rechargenotify",
-		"return_url":   "https://platform.hustgpt.com/panel/topup",
-		"name":         "VIP会员",
-		"money":        fmt.Sprintf("%.2f", float64(quota)*7.3),
-		"clientip":     "192.168.1.1",
-		//"param":       "", // 业务扩展参数，没有可以留空
-		"sign":      "", // 这一行在签名生成后会被更新
-		"sign_type": "MD5",
-	}
-
-	// 商户密钥KEY（这里用XXXX代替真实的KEY）
-	key := os.Getenv("YI_PAY_KEY")
-
-	// 生成签名
-	signature := GenerateSignature(paymentData, key)
-	paymentData["sign"] = signature
-
-	data := url.Values{}
-	for k, v := range paymentData {
-		data.Set(k, v)
-	}
 
-	resp, err := http.PostForm("https://yi-pay.com/mapi.php", data)
-	if err != nil {
-		RecordLog(userId, LogTypeTopup, "支付通道异常1")
-		return "", "", errors.New("支付通道异常1")
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body) // 读取响应体
-	if err != nil {
-		// 处理错误
-		RecordLog(userId, LogTypeTopup, "支付通道异常2")
-		return "", "", errors.New("支付通道异常2")
-	}
-	respJson := RechargeResponse{}
-	err = json.Unmarshal(body, &respJson)
-	if err != nil {
-		RecordLog(userId, LogTypeTopup, "支付通道异常3")
-		return "", "", errors.New("支付通道异常3")
-	}
-
-	if respJson.Code != 1 {
-		RecordLog(userId, LogTypeTopup, "支付通道异常4: "+respJson.Msg)
-		return "", "", errors.New("支付通道异常4: " + respJson.Msg)
-	}
-	if respJson.PayUrl != "" {
-		return respJson.PayUrl, transactionID, nil
-	} else if respJson.QRcode != "" {
-		return respJson.QRcode, transactionID, nil
-	}
-	return "", "", errors.New("返回的是小程序支付链接")
-}
-
-func CompeleteRecharge(TradeNo string) (reterr error, id int) {
-	keyCol := "`trade_no`"
-	if common.UsingPostgreSQL {
-		keyCol = `"trade_no"`
-	}
-	rechargeLog := &RechargeLog{}
-	err := DB.Transaction(func(tx *gorm.DB) error {
-		err := tx.Set("gorm:query_option", "FOR UPDATE").Where(keyCol+" = ?", TradeNo).First(rechargeLog).Error
-		if err != nil {
-			RecordLog(rechargeLog.UserId, LogTypeTopup, "未创建订单！")
-			return errors.New("未创建订单！")
+		now := common.GetTimestamp()
+		updates := map[string]interface{}{
+			"used_count":    redemption.UsedCount + 1,
+			"redeemed_time": now,
 		}
-		if rechargeLog.Status != 1 {
-			RecordLog(rechargeLog.UserId, LogTypeTopup, "订单已支付！")
-			return errors.New("订单已支付！")
+		if campaign.MaxUses > 0 && redemption.UsedCount+1 >= campaign.MaxUses {
+			updates["status"] = common.RedemptionCodeStatusUsed
 		}
-		err = tx.Model(&User{}).Where("id = ?", rechargeLog.UserId).Update("quota", gorm.Expr("quota + ?", rechargeLog.Quota)).Error
-		if err != nil {
-			RecordLog(rechargeLog.UserId, LogTypeTopup, err.Error())
-			return err
+		// 以兑换次数作为条件更新，避免并发兑换时超过次数上限
+		result := tx.Model(&Redemption{}).Where("id = ? AND used_count = ?", redemption.Id, redemption.UsedCount).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("兑换码正在被使用，请重试")
 		}
-		rechargeLog.RedeemedTime = common.GetTimestamp()
-		rechargeLog.Status = 2
-		err = tx.Save(rechargeLog).Error
-		return err
-	})
-	if err != nil {
-		RecordLog(rechargeLog.UserId, LogTypeTopup, "充值失败！"+err.Error())
-		return err, -1
-	}
-	RecordLog(rechargeLog.UserId, LogTypeTopup, fmt.Sprintf("完成充值 %stkens", common.LogQuota(rechargeLog.Quota)))
-	return nil, rechargeLog.UserId
-}
-
-//	{err = DB.Transaction(func(tx *gorm.DB) error {
-//		err := tx.Set("gorm:query_option", "FOR UPDATE").Where(keyCol+" = ?", key).First(redemption).Error
-//		if err != nil {
-//			return errors.New("无效的兑换码")
-//		}
-//		if redemption.Status != common.RedemptionCodeStatusEnabled {
-//			return errors.New("该兑换码已被使用")
-//		}
-//		err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
-//		if err != nil {
-//			return err
-//		}
-//		redemption.RedeemedTime = common.GetTimestamp()
-//		redemption.Status = common.RedemptionCodeStatusUsed
-//		err = tx.Save(redemption).Error
-//		return err
-//	})
-//	if err != nil {
-//		return 0, errors.New("兑换失败，" + err.Error())
-//	}
-//	RecordLog(userId, LogTypeTopup, fmt.Sprintf("通过兑换码充值 %s", common.LogQuota(redemption.Quota)))
-//	return redemption.Quota, nil
-//}
 
-func GenerateSignature(parameters map[string]string, key string) string {
-	// 1. 将参数按照键名ASCII升序排序
-	keys := make([]string, 0, len(parameters))
-	for k := range parameters {
-		if k != "sign" && k != "sign_type" {
-			keys = append(keys, k)
+		if redemption.Quota > 0 {
+			err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
+			if err != nil {
+				return err
+			}
 		}
-	}
-	slices.Sort(keys)
-	// 2. 将排序后的参数拼接成URL键值对的格式，不进行URL编码
-	var concatenatedParams string
-	for _, k := range keys {
-		value := parameters[k]
-		if value != "" {
-			concatenatedParams += fmt.Sprintf("%s=%s&", k, value)
+		if campaign.Group != "" && campaign.GroupDays > 0 {
+			groupExpireTime, err = UpgradeUserGroup(tx, userId, campaign.Group, int64(campaign.GroupDays)*86400)
+			if err != nil {
+				return err
+			}
 		}
+		return tx.Create(&RedemptionRecord{
+			RedemptionId: redemption.Id,
+			CampaignId:   redemption.CampaignId,
+			UserId:       userId,
+			Quota:        redemption.Quota,
+			CreatedTime:  now,
+		}).Error
+	})
+	if err != nil {
+		return 0, errors.New("兑换失败，" + err.Error())
 	}
-	concatenatedParams = strings.TrimRight(concatenatedParams, "&") // 删除最后一个"&"
-	signStr := concatenatedParams + key                             // 拼接密钥KEY
-
-	// 3. 使用MD5算法加密
-	hasher := md5.New()
-	hasher.Write([]byte(signStr))
-	sign := hex.EncodeToString(hasher.Sum(nil)) // 结果为小写
-
-	return sign
+	RecordLog(userId, LogTypeTopup, fmt.Sprintf("通过兑换码充值 %s", common.LogQuota(redemption.Quota)))
+	if groupExpireTime > 0 {
+		ClearUserGroupCache(userId)
+		RecordLog(userId, LogTypeSystem, fmt.Sprintf("通过兑换码升级到分组 %s，有效期至 %s", campaign.Group, formatTimestamp(groupExpireTime)))
+	}
+	return redemption.Quota, nil
 }
 
 func (redemption *Redemption) Insert() error {
@@ -328,35 +195,49 @@ func DeleteRedemptionById(id int) (err error) {
 	return redemption.Delete()
 }
 
+// RedemptionStatistics 按兑换记录统计，同一个兑换码可被多次兑换
 type RedemptionStatistics struct {
-	Count  int64 `json:"count"`
-	Quota  int64 `json:"quota"`
-	Status int   `json:"status"`
+	CampaignId   int    `json:"campaign_id"` // 0 为单独生成的兑换码
+	CampaignName string `json:"campaign_name"`
+	Count        int64  `json:"count"` // 兑换次数
+	Quota        int64  `json:"quota"`
+	UserCount    int64  `json:"user_count"`
 }
 
+// GetStatisticsRedemption 按活动统计兑换次数、兑换的额度及兑换的用户数
 func GetStatisticsRedemption() (redemptionStatistics []*RedemptionStatistics, err error) {
-	err = DB.Model(&Redemption{}).Select("status", "count(*) as count", "sum(quota) as quota").Where("status != ?", 2).Group("status").Scan(&redemptionStatistics).Error
+	err = DB.Table("redemption_records").
+		Select("redemption_records.campaign_id, COALESCE(redemption_campaigns.name, '') as campaign_name, count(*) as count, sum(redemption_records.quota) as quota, count(distinct redemption_records.user_id) as user_count").
+		Joins("LEFT JOIN redemption_campaigns ON redemption_campaigns.id = redemption_records.campaign_id").
+		Group("redemption_records.campaign_id, redemption_campaigns.name").
+		Order("redemption_records.campaign_id").
+		Scan(&redemptionStatistics).Error
 	return redemptionStatistics, err
 }
 
 type RedemptionStatisticsGroup struct {
-	Date      string `json:"date"`
-	Quota     int64  `json:"quota"`
-	UserCount int64  `json:"user_count"`
+	Date         string `json:"date"`
+	CampaignId   int    `json:"campaign_id"`
+	CampaignName string `json:"campaign_name"`
+	Quota        int64  `json:"quota"`
+	UserCount    int64  `json:"user_count"`
 }
 
+// GetStatisticsRedemptionByPeriod 按天及活动统计兑换的额度和用户数
 func GetStatisticsRedemptionByPeriod(startTimestamp, endTimestamp int64) (redemptionStatistics []*RedemptionStatisticsGroup, err error) {
-	groupSelect := getTimestampGroupsSelect("redeemed_time", "day", "date")
+	groupSelect := getTimestampGroupsSelect("redemption_records.created_time", "day", "date")
 
 	err = DB.Raw(`
 		SELECT `+groupSelect+`,
-		sum(quota) as quota,
-		count(distinct user_id) as user_count
-		FROM redemptions
-		WHERE status=3
-		AND redeemed_time BETWEEN ? AND ?
-		GROUP BY date
-		ORDER BY date
+		redemption_records.campaign_id,
+		COALESCE(redemption_campaigns.name, '') as campaign_name,
+		sum(redemption_records.quota) as quota,
+		count(distinct redemption_records.user_id) as user_count
+		FROM redemption_records
+		LEFT JOIN redemption_campaigns ON redemption_campaigns.id = redemption_records.campaign_id
+		WHERE redemption_records.created_time BETWEEN ? AND ?
+		GROUP BY date, redemption_records.campaign_id, redemption_campaigns.name
+		ORDER BY date, redemption_records.campaign_id
 	`, startTimestamp, endTimestamp).Scan(&redemptionStatistics).Error
 
 	return redemptionStatistics, err
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+// RedemptionCampaignMaxBatch 单批生成兑换码的个数上限
+const RedemptionCampaignMaxBatch = 1000
+
+// RedemptionCampaign 兑换码活动，活动下的兑换码共用额度、有效期及兑换次数限制
+type RedemptionCampaign struct {
+	Id             int    `json:"id"`
+	Name           string `json:"name" gorm:"type:varchar(64);index"`
+	Status         int    `json:"status" gorm:"default:1"`
+	Quota          int    `json:"quota" gorm:"default:0"`
+	Group          string `json:"group" gorm:"type:varchar(32);default:''"` // 兑换后临时切换到的分组，为空时不切换
+	GroupDays      int    `json:"group_days" gorm:"default:0"`
+	MaxUses        int    `json:"max_uses"`          // 每个兑换码可被兑换的次数，0 为不限制
+	MaxUsesPerUser int    `json:"max_uses_per_user"` // 每个用户在活动中可兑换的次数，0 为不限制
+	ExpiredTime    int64  `json:"expired_time" gorm:"bigint;default:-1"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	Count          int    `json:"count" gorm:"-:all"` // only for api request
+}
+
+// RedemptionRecord 每次兑换的记录，用于统计活动的兑换人数及限制单个用户的兑换次数
+type RedemptionRecord struct {
+	Id           int   `json:"id"`
+	RedemptionId int   `json:"redemption_id" gorm:"index"`
+	CampaignId   int   `json:"campaign_id" gorm:"index:idx_campaign_user"`
+	UserId       int   `json:"user_id" gorm:"index:idx_campaign_user"`
+	Quota        int   `json:"quota"`
+	CreatedTime  int64 `json:"created_time" gorm:"bigint"`
+}
+
+var allowedRedemptionCampaignsOrderFields = map[string]bool{
+	"id":           true,
+	"name":         true,
+	"status":       true,
+	"quota":        true,
+	"expired_time": true,
+	"created_time": true,
+}
+
+func GetRedemptionCampaignsList(params *GenericParams) (*DataResult[RedemptionCampaign], error) {
+	var campaigns []*RedemptionCampaign
+	db := DB
+	if params.Keyword != "" {
+		db = db.Where("id = ? or name LIKE ?", common.String2Int(params.Keyword), params.Keyword+"%")
+	}
+
+	return PaginateAndOrder[RedemptionCampaign](db, &params.PaginationParams, &campaigns, allowedRedemptionCampaignsOrderFields)
+}
+
+func GetRedemptionCampaignById(id int) (*RedemptionCampaign, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	campaign := RedemptionCampaign{Id: id}
+	err := DB.First(&campaign, "id = ?", id).Error
+	return &campaign, err
+}
+
+func (campaign *RedemptionCampaign) Validate() error {
+	if len(campaign.Name) == 0 || len(campaign.Name) > 64 {
+		return errors.New("活动名称长度必须在1-64之间")
+	}
+	if campaign.Quota < 0 || campaign.MaxUses < 0 || campaign.MaxUsesPerUser < 0 {
+		return errors.New("额度及兑换次数不能为负数")
+	}
+	if campaign.Quota == 0 && campaign.Group == "" {
+		return errors.New("兑换码至少需要赠送额度或升级分组")
+	}
+	if campaign.Group != "" {
+		if _, ok := common.GroupRatio[campaign.Group]; !ok {
+			return errors.New("分组不存在")
+		}
+		if campaign.GroupDays <= 0 {
+			return errors.New("升级分组的天数必须大于0")
+		}
+	}
+	return nil
+}
+
+func (campaign *RedemptionCampaign) IsExpired() bool {
+	return campaign.ExpiredTime != -1 && campaign.ExpiredTime < common.GetTimestamp()
+}
+
+// Insert 创建活动并生成第一批兑换码
+func (campaign *RedemptionCampaign) Insert(userId int) (keys []string, err error) {
+	campaign.CreatedTime = common.GetTimestamp()
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(campaign).Error; err != nil {
+			return err
+		}
+		keys, err = campaign.generateRedemptions(tx, userId, campaign.Count)
+		return err
+	})
+	return keys, err
+}
+
+// AddBatch 为活动追加一批兑换码
+func (campaign *RedemptionCampaign) AddBatch(userId int, count int) ([]string, error) {
+	return campaign.generateRedemptions(DB, userId, count)
+}
+
+func (campaign *RedemptionCampaign) generateRedemptions(tx *gorm.DB, userId int, count int) ([]string, error) {
+	if count <= 0 || count > RedemptionCampaignMaxBatch {
+		return nil, errors.New("兑换码个数必须在1-1000之间")
+	}
+	now := common.GetTimestamp()
+	keys := make([]string, 0, count)
+	// quota 字段的默认值为 100，用结构体创建时 0 会被替换为默认值，只升级分组的兑换码需要以 map 写入 0
+	redemptions := make([]map[string]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		key := common.GetUUID()
+		keys = append(keys, key)
+		redemptions = append(redemptions, map[string]interface{}{
+			"user_id":      userId,
+			"campaign_id":  campaign.Id,
+			"name":         campaign.Name,
+			"key":          key,
+			"status":       common.RedemptionCodeStatusEnabled,
+			"quota":        campaign.Quota,
+			"created_time": now,
+		})
+	}
+	if err := tx.Model(&Redemption{}).CreateInBatches(redemptions, 100).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Update 兑换码生成后额度及分组不再修改，只允许调整名称、状态、有效期及兑换次数
+func (campaign *RedemptionCampaign) Update() error {
+	return DB.Model(campaign).Select("name", "status", "expired_time", "max_uses", "max_uses_per_user").Updates(campaign).Error
+}
+
+// GetCampaignRedemptions 活动下所有的兑换码，用于导出
+func GetCampaignRedemptions(campaignId int) (redemptions []*Redemption, err error) {
+	err = DB.Where("campaign_id = ?", campaignId).Order("id").Find(&redemptions).Error
+	return redemptions, err
+}
@@ -0,0 +1,95 @@
+package model_test
+
+import (
+	"one-api/common"
+	"one-api/common/test"
+	_ "one-api/common/test/init"
+	"one-api/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedeemCampaignLimits(t *testing.T) {
+	test.InitTestDB(t)
+	userA := test.CreateTestUser(t, "redeem_a", 0)
+	userB := test.CreateTestUser(t, "redeem_b", 0)
+	userC := test.CreateTestUser(t, "redeem_c", 0)
+
+	campaign := &model.RedemptionCampaign{Name: "launch", Status: common.RedemptionCodeStatusEnabled, Quota: 100, MaxUses: 2, MaxUsesPerUser: 1, ExpiredTime: -1, Count: 2}
+	keys, err := campaign.Insert(1)
+	assert.Nil(t, err)
+	assert.Len(t, keys, 2)
+
+	quota, err := model.Redeem(keys[0], userA.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, 100, quota)
+
+	// 每个用户在活动中只能兑换一次
+	_, err = model.Redeem(keys[1], userA.Id)
+	assert.NotNil(t, err)
+
+	// 兑换码达到次数上限后不能再兑换
+	_, err = model.Redeem(keys[0], userB.Id)
+	assert.Nil(t, err)
+	_, err = model.Redeem(keys[0], userC.Id)
+	assert.NotNil(t, err)
+	_, err = model.Redeem(keys[1], userC.Id)
+	assert.Nil(t, err)
+
+	quota, _ = model.GetUserQuota(userA.Id)
+	assert.Equal(t, 100, quota)
+
+	statistics, err := model.GetStatisticsRedemption()
+	assert.Nil(t, err)
+	assert.Len(t, statistics, 1)
+	assert.Equal(t, campaign.Id, statistics[0].CampaignId)
+	assert.Equal(t, "launch", statistics[0].CampaignName)
+	assert.Equal(t, int64(3), statistics[0].Count)
+	assert.Equal(t, int64(300), statistics[0].Quota)
+	assert.Equal(t, int64(3), statistics[0].UserCount)
+
+	now := common.GetTimestamp()
+	groups, err := model.GetStatisticsRedemptionByPeriod(now-86400, now+86400)
+	assert.Nil(t, err)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, int64(300), groups[0].Quota)
+	assert.Equal(t, int64(3), groups[0].UserCount)
+}
+
+func TestRedeemExpiredCampaign(t *testing.T) {
+	test.InitTestDB(t)
+	user := test.CreateTestUser(t, "redeem", 0)
+
+	campaign := &model.RedemptionCampaign{Name: "expired", Status: common.RedemptionCodeStatusEnabled, Quota: 100, ExpiredTime: -1, Count: 1}
+	keys, err := campaign.Insert(1)
+	assert.Nil(t, err)
+
+	campaign.ExpiredTime = common.GetTimestamp() - 1
+	assert.Nil(t, campaign.Update())
+	_, err = model.Redeem(keys[0], user.Id)
+	assert.NotNil(t, err)
+	quota, _ := model.GetUserQuota(user.Id)
+	assert.Equal(t, 0, quota)
+}
+
+func TestRedeemGroupCampaign(t *testing.T) {
+	test.InitTestDB(t)
+	user := test.CreateTestUser(t, "redeem", 0)
+
+	// 只升级分组的兑换码不赠送额度
+	campaign := &model.RedemptionCampaign{Name: "vip", Status: common.RedemptionCodeStatusEnabled, Group: "vip", GroupDays: 7, ExpiredTime: -1, Count: 1}
+	keys, err := campaign.Insert(1)
+	assert.Nil(t, err)
+
+	quota, err := model.Redeem(keys[0], user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, quota)
+
+	var upgraded model.User
+	assert.Nil(t, model.DB.First(&upgraded, "id = ?", user.Id).Error)
+	assert.Equal(t, "vip", upgraded.Group)
+	assert.Equal(t, "default", upgraded.BaseGroup)
+	assert.InDelta(t, common.GetTimestamp()+7*86400, upgraded.GroupExpireTime, 5)
+	assert.Equal(t, 0, upgraded.Quota)
+}
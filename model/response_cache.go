@@ -0,0 +1,77 @@
+package model
+
+import (
+	"one-api/common"
+	"sync"
+	"time"
+)
+
+const responseCacheMaxItems = 10000 // 内存缓存的最大条目数
+
+type responseCacheItem struct {
+	value     string
+	expiredAt time.Time
+}
+
+type responseCacheStore struct {
+	sync.RWMutex
+	items map[string]responseCacheItem
+}
+
+// 未启用 Redis 时响应缓存保存在内存中，仅对当前节点有效
+var responseCacheMemory = &responseCacheStore{
+	items: make(map[string]responseCacheItem),
+}
+
+func GetResponseCache(key string) (string, bool) {
+	if common.RedisEnabled {
+		value, err := common.RedisGet(key)
+		if err != nil {
+			return "", false
+		}
+		return value, true
+	}
+
+	responseCacheMemory.RLock()
+	item, ok := responseCacheMemory.items[key]
+	responseCacheMemory.RUnlock()
+	if !ok || time.Now().After(item.expiredAt) {
+		return "", false
+	}
+	return item.value, true
+}
+
+func SetResponseCache(key string, value string, expiration time.Duration) error {
+	if common.RedisEnabled {
+		return common.RedisSet(key, value, expiration)
+	}
+
+	responseCacheMemory.Lock()
+	defer responseCacheMemory.Unlock()
+
+	if len(responseCacheMemory.items) >= responseCacheMaxItems {
+		responseCacheMemory.evict()
+	}
+	responseCacheMemory.items[key] = responseCacheItem{
+		value:     value,
+		expiredAt: time.Now().Add(expiration),
+	}
+	return nil
+}
+
+// 先清理过期的条目，仍然已满时随机淘汰一条
+func (s *responseCacheStore) evict() {
+	now := time.Now()
+	for key, item := range s.items {
+		if now.After(item.expiredAt) {
+			delete(s.items, key)
+		}
+	}
+	if len(s.items) < responseCacheMaxItems {
+		return
+	}
+	for key := range s.items {
+		delete(s.items, key)
+		return
+	}
+}
@@ -0,0 +1,346 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"one-api/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SubscriptionMaxCycles 单次购买的周期数上限
+const SubscriptionMaxCycles = 12
+
+const subscriptionSyncInterval = time.Minute
+
+// Subscription 用户的订阅，保存购买时套餐的分组、额度及周期，每个用户同时只有一个生效的订阅
+type Subscription struct {
+	Id            int     `json:"id"`
+	UserId        int     `json:"user_id" gorm:"index"`
+	PlanId        int     `json:"plan_id" gorm:"index"`
+	PlanName      string  `json:"plan_name" gorm:"type:varchar(64)"`
+	TradeNo       string  `json:"trade_no" gorm:"type:char(20)"` // 最近一次购买或续费的订单号
+	Status        int     `json:"status" gorm:"default:1;index"`
+	Group         string  `json:"group" gorm:"type:varchar(32);default:''"`
+	Quota         int     `json:"quota"`
+	CycleDays     int     `json:"cycle_days"`
+	Money         float64 `json:"money"` // 订阅的总价值，升级时按剩余时间折算抵扣
+	StartTime     int64   `json:"start_time" gorm:"bigint"`
+	ExpiredTime   int64   `json:"expired_time" gorm:"bigint;index"`
+	NextGrantTime int64   `json:"next_grant_time" gorm:"bigint;index"` // 下一个周期发放额度的时间
+	UpdatedTime   int64   `json:"updated_time" gorm:"bigint"`
+}
+
+var allowedSubscriptionsOrderFields = map[string]bool{
+	"id":           true,
+	"user_id":      true,
+	"status":       true,
+	"start_time":   true,
+	"expired_time": true,
+}
+
+type SubscriptionsListParams struct {
+	PaginationParams
+	UserId int `form:"user_id"`
+	PlanId int `form:"plan_id"`
+	Status int `form:"status"`
+}
+
+func GetSubscriptionsList(params *SubscriptionsListParams) (*DataResult[Subscription], error) {
+	var subscriptions []*Subscription
+	tx := DB
+	if params.UserId != 0 {
+		tx = tx.Where("user_id = ?", params.UserId)
+	}
+	if params.PlanId != 0 {
+		tx = tx.Where("plan_id = ?", params.PlanId)
+	}
+	if params.Status != 0 {
+		tx = tx.Where("status = ?", params.Status)
+	}
+	return PaginateAndOrder[Subscription](tx, &params.PaginationParams, &subscriptions, allowedSubscriptionsOrderFields)
+}
+
+// GetActiveSubscription 用户当前生效的订阅，没有时返回 gorm.ErrRecordNotFound
+func GetActiveSubscription(userId int) (*Subscription, error) {
+	return getActiveSubscription(DB, userId)
+}
+
+func getActiveSubscription(tx *gorm.DB, userId int) (*Subscription, error) {
+	var subscription Subscription
+	err := tx.Where("user_id = ? AND status = ? AND expired_time > ?", userId, common.SubscriptionStatusActive, common.GetTimestamp()).
+		Order("id desc").First(&subscription).Error
+	return &subscription, err
+}
+
+// remainingValue 按剩余时间折算的订阅价值
+func (subscription *Subscription) remainingValue(now int64) float64 {
+	total := subscription.ExpiredTime - subscription.StartTime
+	if total <= 0 || subscription.ExpiredTime <= now {
+		return 0
+	}
+	return subscription.Money * float64(subscription.ExpiredTime-now) / float64(total)
+}
+
+// unusedCycleQuota 本周期已发放的额度中按剩余时间折算未使用的部分，升级时从新套餐的额度中扣除
+func (subscription *Subscription) unusedCycleQuota(now int64) int {
+	cycle := int64(subscription.CycleDays) * 86400
+	remaining := subscription.NextGrantTime - now
+	if cycle <= 0 || remaining <= 0 {
+		return 0
+	}
+	if remaining > cycle {
+		remaining = cycle
+	}
+	return int(int64(subscription.Quota) * remaining / cycle)
+}
+
+// SubscriptionQuote 购买套餐的报价，已订阅其他套餐时只能升级，差价按当前订阅的剩余价值抵扣
+type SubscriptionQuote struct {
+	PlanId int     `json:"plan_id"`
+	Cycles int     `json:"cycles"`
+	Renew  bool    `json:"renew"` // 续费当前的套餐
+	Price  float64 `json:"price"`
+	Credit float64 `json:"credit"`
+	Money  float64 `json:"money"` // 实付金额
+}
+
+func GetSubscriptionQuote(userId int, plan *Plan, cycles int) (*SubscriptionQuote, error) {
+	if cycles <= 0 || cycles > SubscriptionMaxCycles {
+		return nil, fmt.Errorf("购买的周期数必须在1-%d之间", SubscriptionMaxCycles)
+	}
+	quote := &SubscriptionQuote{
+		PlanId: plan.Id,
+		Cycles: cycles,
+		Price:  roundMoney(plan.Price * float64(cycles) * common.PaymentExchangeRate),
+	}
+
+	current, err := GetActiveSubscription(userId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		if current.PlanId == plan.Id {
+			quote.Renew = true
+		} else {
+			if currentPlan, err := GetPlanById(current.PlanId); err == nil && plan.dailyPrice() <= currentPlan.dailyPrice() {
+				return nil, errors.New("当前订阅未到期，只能续费或升级到更高的套餐")
+			}
+			quote.Credit = roundMoney(current.remainingValue(common.GetTimestamp()))
+		}
+	}
+
+	quote.Money = roundMoney(quote.Price - quote.Credit)
+	if quote.Money < 0.01 {
+		return nil, errors.New("抵扣后的金额过低，请增加购买的周期数")
+	}
+	return quote, nil
+}
+
+func roundMoney(money float64) float64 {
+	return math.Round(money*100) / 100
+}
+
+// activateSubscription 订阅订单支付成功后，续费当前的订阅或创建新的订阅，返回充值日志的内容
+func activateSubscription(tx *gorm.DB, rechargeLog *RechargeLog) (string, error) {
+	var plan Plan
+	if err := tx.First(&plan, "id = ?", rechargeLog.PlanId).Error; err != nil {
+		return "", errors.New("套餐不存在")
+	}
+	now := common.GetTimestamp()
+	duration := int64(rechargeLog.Cycles) * int64(plan.CycleDays) * 86400
+
+	current, err := getActiveSubscription(tx, rechargeLog.UserId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if err == nil && current.PlanId == plan.Id {
+		expiredTime := current.ExpiredTime + duration
+		err = tx.Model(current).Updates(map[string]interface{}{
+			"expired_time": expiredTime,
+			"money":        current.Money + rechargeLog.Money,
+			"trade_no":     rechargeLog.TradeNo,
+			"updated_time": now,
+		}).Error
+		if err != nil {
+			return "", err
+		}
+		if current.Group != "" {
+			if _, err := SetUserGroupUntil(tx, rechargeLog.UserId, current.Group, expiredTime); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("续费订阅 %s %d 个周期，到期时间 %s，订单号: %s", plan.Name, rechargeLog.Cycles, formatTimestamp(expiredTime), rechargeLog.TradeNo), nil
+	}
+
+	var credit float64
+	grantQuota := plan.Quota
+	content := fmt.Sprintf("订阅 %s %d 个周期", plan.Name, rechargeLog.Cycles)
+	var upgraded *Subscription
+	if err == nil {
+		// 下单后订阅可能已变更，不是升级的订单不再替换当前订阅，支付的金额按充值额度入账
+		var currentPlan Plan
+		if err := tx.First(&currentPlan, "id = ?", current.PlanId).Error; err == nil && plan.dailyPrice() <= currentPlan.dailyPrice() {
+			return creditSubscriptionOrder(tx, rechargeLog, current)
+		}
+
+		credit = current.remainingValue(now)
+		// 抵扣的金额包含当前周期剩余的时间，这部分已发放的额度也要从新套餐的额度中扣除
+		grantQuota -= current.unusedCycleQuota(now)
+		if grantQuota < 0 {
+			grantQuota = 0
+		}
+		err = tx.Model(current).Updates(map[string]interface{}{
+			"status":       common.SubscriptionStatusUpgraded,
+			"updated_time": now,
+		}).Error
+		if err != nil {
+			return "", err
+		}
+		upgraded = current
+		content = fmt.Sprintf("从 %s 升级到 %s %d 个周期，抵扣 %.2f，扣除上个套餐本周期未使用的额度 %s", current.PlanName, plan.Name, rechargeLog.Cycles, credit, common.LogQuota(plan.Quota-grantQuota))
+	}
+
+	subscription := &Subscription{
+		UserId:        rechargeLog.UserId,
+		PlanId:        plan.Id,
+		PlanName:      plan.Name,
+		TradeNo:       rechargeLog.TradeNo,
+		Status:        common.SubscriptionStatusActive,
+		Group:         plan.Group,
+		Quota:         plan.Quota,
+		CycleDays:     plan.CycleDays,
+		Money:         roundMoney(rechargeLog.Money + credit),
+		StartTime:     now,
+		ExpiredTime:   now + duration,
+		NextGrantTime: now + int64(plan.CycleDays)*86400,
+		UpdatedTime:   now,
+	}
+	if err := tx.Create(subscription).Error; err != nil {
+		return "", err
+	}
+	if grantQuota > 0 {
+		err = tx.Model(&User{}).Where("id = ?", rechargeLog.UserId).Update("quota", gorm.Expr("quota + ?", grantQuota)).Error
+		if err != nil {
+			return "", err
+		}
+	}
+	if plan.Group != "" {
+		if _, err := SetUserGroupUntil(tx, rechargeLog.UserId, plan.Group, subscription.ExpiredTime); err != nil {
+			return "", err
+		}
+	} else if upgraded != nil && upgraded.Group != "" {
+		// 新套餐不切换分组，结束升级前套餐的临时分组
+		if err := EndUserGroup(tx, rechargeLog.UserId, upgraded.Group, upgraded.ExpiredTime); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%s，发放额度 %s，到期时间 %s，订单号: %s", content, common.LogQuota(grantQuota), formatTimestamp(subscription.ExpiredTime), rechargeLog.TradeNo), nil
+}
+
+// creditSubscriptionOrder 无法开通的订阅订单按支付金额折算为额度入账
+func creditSubscriptionOrder(tx *gorm.DB, rechargeLog *RechargeLog, current *Subscription) (string, error) {
+	quota := 0
+	if common.PaymentExchangeRate > 0 {
+		quota = int(rechargeLog.Money / common.PaymentExchangeRate * common.QuotaPerUnit)
+	}
+	if err := tx.Model(rechargeLog).Update("quota", quota).Error; err != nil {
+		return "", err
+	}
+	rechargeLog.Quota = quota
+	if quota > 0 {
+		err := tx.Model(&User{}).Where("id = ?", rechargeLog.UserId).Update("quota", gorm.Expr("quota + ?", quota)).Error
+		if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("当前已订阅 %s，订单不是升级无法开通，支付金额 %.2f 按充值额度 %s 入账，订单号: %s", current.PlanName, rechargeLog.Money, common.LogQuota(quota), rechargeLog.TradeNo), nil
+}
+
+func formatTimestamp(timestamp int64) string {
+	return time.Unix(timestamp, 0).Format("2006-01-02 15:04:05")
+}
+
+// grantSubscriptionQuota 发放一个周期的额度，以 next_grant_time 作为条件更新，多次执行不会重复发放
+func grantSubscriptionQuota(subscription *Subscription) error {
+	nextGrantTime := subscription.NextGrantTime + int64(subscription.CycleDays)*86400
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Subscription{}).
+			Where("id = ? AND status = ? AND next_grant_time = ?", subscription.Id, common.SubscriptionStatusActive, subscription.NextGrantTime).
+			Updates(map[string]interface{}{
+				"next_grant_time": nextGrantTime,
+				"updated_time":    common.GetTimestamp(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&User{}).Where("id = ?", subscription.UserId).Update("quota", gorm.Expr("quota + ?", subscription.Quota)).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := CacheUpdateUserQuota(subscription.UserId); err != nil {
+		common.SysError("failed to update user quota cache: " + err.Error())
+	}
+	RecordLog(subscription.UserId, LogTypeTopup, fmt.Sprintf("订阅 %s 发放本周期额度 %s", subscription.PlanName, common.LogQuota(subscription.Quota)))
+	return nil
+}
+
+func grantSubscriptionQuotas() {
+	var subscriptions []*Subscription
+	err := DB.Where("status = ? AND next_grant_time <= ? AND next_grant_time < expired_time", common.SubscriptionStatusActive, common.GetTimestamp()).
+		Find(&subscriptions).Error
+	if err != nil {
+		common.SysError("failed to fetch subscriptions to grant: " + err.Error())
+		return
+	}
+	for _, subscription := range subscriptions {
+		if subscription.Quota <= 0 {
+			continue
+		}
+		if err := grantSubscriptionQuota(subscription); err != nil {
+			common.SysError("failed to grant subscription quota: " + err.Error())
+		}
+	}
+}
+
+// expireSubscriptions 标记到期的订阅，分组由 SyncUserGroupExpiry 恢复
+func expireSubscriptions() {
+	var subscriptions []*Subscription
+	err := DB.Where("status = ? AND expired_time <= ?", common.SubscriptionStatusActive, common.GetTimestamp()).Find(&subscriptions).Error
+	if err != nil {
+		common.SysError("failed to fetch expired subscriptions: " + err.Error())
+		return
+	}
+	for _, subscription := range subscriptions {
+		result := DB.Model(&Subscription{}).Where("id = ? AND status = ?", subscription.Id, common.SubscriptionStatusActive).Updates(map[string]interface{}{
+			"status":       common.SubscriptionStatusExpired,
+			"updated_time": common.GetTimestamp(),
+		})
+		if result.Error != nil {
+			common.SysError("failed to expire subscription: " + result.Error.Error())
+			continue
+		}
+		if result.RowsAffected > 0 {
+			RecordLog(subscription.UserId, LogTypeSystem, fmt.Sprintf("订阅 %s 已到期", subscription.PlanName))
+		}
+	}
+}
+
+// SyncSubscriptions 定时发放订阅的周期额度并处理到期的订阅
+func SyncSubscriptions() {
+	for {
+		grantSubscriptionQuotas()
+		expireSubscriptions()
+		time.Sleep(subscriptionSyncInterval)
+	}
+}
@@ -0,0 +1,107 @@
+package model_test
+
+import (
+	"one-api/common"
+	"one-api/common/test"
+	_ "one-api/common/test/init"
+	"one-api/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func paySubscription(t *testing.T, userId int, plan *model.Plan, money float64) *model.RechargeLog {
+	t.Helper()
+	rechargeLog := &model.RechargeLog{UserId: userId, Gateway: "epay", PlanId: plan.Id, Cycles: 1, Money: money}
+	assert.Nil(t, model.CreateRechargeLog(rechargeLog))
+	paid, err := model.CompleteRecharge(rechargeLog.TradeNo, "gw-"+rechargeLog.TradeNo, money)
+	assert.Nil(t, err)
+	return paid
+}
+
+func TestSubscriptionUpgrade(t *testing.T) {
+	test.InitTestDB(t)
+	common.PaymentExchangeRate = 1
+	defer func() { common.PaymentExchangeRate = 7.3 }()
+	user := test.CreateTestUser(t, "subscriber", 0)
+
+	basic := &model.Plan{Name: "basic", Status: common.PlanStatusEnabled, Group: "vip", Quota: 1000, Price: 10, CycleDays: 30}
+	pro := &model.Plan{Name: "pro", Status: common.PlanStatusEnabled, Quota: 3000, Price: 30, CycleDays: 30}
+	assert.Nil(t, basic.Insert())
+	assert.Nil(t, pro.Insert())
+
+	_, err := model.GetSubscriptionQuote(user.Id, basic, 0)
+	assert.NotNil(t, err)
+	_, err = model.GetSubscriptionQuote(user.Id, basic, model.SubscriptionMaxCycles+1)
+	assert.NotNil(t, err)
+	quote, err := model.GetSubscriptionQuote(user.Id, basic, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 20.0, quote.Money)
+
+	paySubscription(t, user.Id, basic, 10)
+	var subscriber model.User
+	model.DB.First(&subscriber, "id = ?", user.Id)
+	assert.Equal(t, 1000, subscriber.Quota)
+	assert.Equal(t, "vip", subscriber.Group)
+
+	quote, err = model.GetSubscriptionQuote(user.Id, basic, 1)
+	assert.Nil(t, err)
+	assert.True(t, quote.Renew)
+
+	// 订阅过半时升级，按剩余时间抵扣，并扣除本周期未使用的额度
+	now := common.GetTimestamp()
+	current, _ := model.GetActiveSubscription(user.Id)
+	model.DB.Model(current).Updates(map[string]interface{}{
+		"start_time":      now - 15*86400,
+		"expired_time":    now + 15*86400,
+		"next_grant_time": now + 15*86400,
+	})
+	model.DB.Model(&model.User{}).Where("id = ?", user.Id).Update("group_expire_time", now+15*86400)
+
+	quote, err = model.GetSubscriptionQuote(user.Id, pro, 1)
+	assert.Nil(t, err)
+	assert.False(t, quote.Renew)
+	assert.Equal(t, 5.0, quote.Credit)
+	assert.Equal(t, 25.0, quote.Money)
+
+	paySubscription(t, user.Id, pro, quote.Money)
+	model.DB.First(&subscriber, "id = ?", user.Id)
+	assert.InDelta(t, 1000+3000-500, subscriber.Quota, 1)
+	// 新套餐不切换分组，恢复为订阅前的分组
+	assert.Equal(t, "default", subscriber.Group)
+	assert.Equal(t, int64(0), subscriber.GroupExpireTime)
+
+	upgraded, _ := model.GetActiveSubscription(user.Id)
+	assert.Equal(t, pro.Id, upgraded.PlanId)
+	assert.Equal(t, 30.0, upgraded.Money)
+
+	// 不能降级
+	_, err = model.GetSubscriptionQuote(user.Id, basic, 1)
+	assert.NotNil(t, err)
+}
+
+func TestSubscriptionStaleDowngradeOrder(t *testing.T) {
+	test.InitTestDB(t)
+	common.PaymentExchangeRate = 1
+	defer func() { common.PaymentExchangeRate = 7.3 }()
+	user := test.CreateTestUser(t, "subscriber", 0)
+
+	basic := &model.Plan{Name: "basic", Status: common.PlanStatusEnabled, Quota: 1000, Price: 10, CycleDays: 30}
+	pro := &model.Plan{Name: "pro", Status: common.PlanStatusEnabled, Quota: 3000, Price: 30, CycleDays: 30}
+	assert.Nil(t, basic.Insert())
+	assert.Nil(t, pro.Insert())
+
+	// 下单后先订阅了更高的套餐，之前的订单支付时按充值额度入账
+	stale := &model.RechargeLog{UserId: user.Id, Gateway: "epay", PlanId: basic.Id, Cycles: 1, Money: 10}
+	assert.Nil(t, model.CreateRechargeLog(stale))
+	paySubscription(t, user.Id, pro, 30)
+
+	paid, err := model.CompleteRecharge(stale.TradeNo, "gw-stale", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, int(10*common.QuotaPerUnit), paid.Quota)
+
+	quota, _ := model.GetUserQuota(user.Id)
+	assert.Equal(t, 3000+int(10*common.QuotaPerUnit), quota)
+	current, _ := model.GetActiveSubscription(user.Id)
+	assert.Equal(t, pro.Id, current.PlanId)
+}
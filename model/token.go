@@ -2,24 +2,38 @@ package model
 
 import (
 	"errors"
-	"fmt"
 	"one-api/common"
 
 	"gorm.io/gorm"
 )
 
 type Token struct {
-	Id             int    `json:"id"`
-	UserId         int    `json:"user_id"`
-	Key            string `json:"key" gorm:"type:char(48);uniqueIndex"`
-	Status         int    `json:"status" gorm:"default:1"`
-	Name           string `json:"name" gorm:"index" `
-	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
-	AccessedTime   int64  `json:"accessed_time" gorm:"bigint"`
-	ExpiredTime    int64  `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
-	RemainQuota    int    `json:"remain_quota" gorm:"default:0"`
-	UnlimitedQuota bool   `json:"unlimited_quota" gorm:"default:false"`
-	UsedQuota      int    `json:"used_quota" gorm:"default:0"` // used quota
+	Id               int    `json:"id"`
+	UserId           int    `json:"user_id"`
+	Key              string `json:"key" gorm:"type:char(48);uniqueIndex"`
+	Status           int    `json:"status" gorm:"default:1"`
+	Name             string `json:"name" gorm:"index" `
+	CreatedTime      int64  `json:"created_time" gorm:"bigint"`
+	AccessedTime     int64  `json:"accessed_time" gorm:"bigint"`
+	ExpiredTime      int64  `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
+	RemainQuota      int    `json:"remain_quota" gorm:"default:0"`
+	UnlimitedQuota   bool   `json:"unlimited_quota" gorm:"default:false"`
+	UsedQuota        int    `json:"used_quota" gorm:"default:0"`         // used quota
+	ResponseCache    bool   `json:"response_cache" gorm:"default:false"` // 相同请求返回缓存的响应
+	Models           string `json:"models" gorm:"type:text"`             // 允许调用的模型，逗号分隔，支持 * 通配符，为空时不限制
+	AllowIps         string `json:"allow_ips" gorm:"type:text"`          // IP 白名单，逗号分隔，支持 CIDR，为空时不限制
+	ModelLimits      string `json:"model_limits" gorm:"type:text"`       // 单个模型的额度上限，JSON 对象，例如 {"gpt-4*": 500000}
+	RateLimitRPM     int    `json:"rate_limit_rpm" gorm:"default:0"`     // 每分钟请求数上限，0 为不限制
+	RateLimitTPM     int    `json:"rate_limit_tpm" gorm:"default:0"`     // 每分钟 token 数上限，0 为不限制
+	ConcurrencyLimit int    `json:"concurrency_limit" gorm:"default:0"`  // 同时处理的请求数上限，0 为不限制
+	BudgetPeriod     string `json:"budget_period" gorm:"default:''"`     // 预算周期：daily、weekly、monthly，为空时不启用
+	BudgetLimit      int    `json:"budget_limit" gorm:"default:0"`       // 每个周期可用的额度
+	BudgetUsedQuota  int    `json:"budget_used_quota" gorm:"default:0"`  // 当前周期已用额度
+	BudgetResetTime  int64  `json:"budget_reset_time" gorm:"bigint"`     // 下次重置时间
+	AlertPercent     int    `json:"alert_percent" gorm:"default:0"`      // 周期预算用量达到该百分比时提醒，0 为不提醒
+	AlertQuota       int    `json:"alert_quota" gorm:"default:0"`        // 剩余额度低于该值时提醒，0 为不提醒
+
+	ModelUsages []*TokenModelUsage `json:"model_usages,omitempty" gorm:"-"` // 各模型限额的已用额度
 }
 
 var allowedTokenOrderFields = map[string]bool{
@@ -123,7 +137,7 @@ func (token *Token) Insert() error {
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (token *Token) Update() error {
 	var err error
-	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota").Updates(token).Error
+	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "response_cache", "models", "allow_ips", "model_limits", "rate_limit_rpm", "rate_limit_tpm", "concurrency_limit", "budget_period", "budget_limit", "budget_used_quota", "budget_reset_time", "alert_percent", "alert_quota").Updates(token).Error
 	return err
 }
 
@@ -135,7 +149,10 @@ func (token *Token) SelectUpdate() error {
 func (token *Token) Delete() error {
 	var err error
 	err = DB.Delete(token).Error
-	return err
+	if err != nil {
+		return err
+	}
+	return deleteTokenModelUsages(token.Id)
 }
 
 func DeleteTokenById(id int, userId int) (err error) {
@@ -213,11 +230,6 @@ func PreConsumeTokenQuota(tokenId int, quota int) (err error) {
 	if userQuota < quota {
 		return errors.New("用户额度不足")
 	}
-	quotaTooLow := userQuota >= common.QuotaRemindThreshold && userQuota-quota < common.QuotaRemindThreshold
-	noMoreQuota := userQuota-quota <= 0
-	if quotaTooLow || noMoreQuota {
-		go sendQuotaWarningEmail(token.UserId, userQuota, noMoreQuota)
-	}
 	if !token.UnlimitedQuota {
 		err = DecreaseTokenQuota(tokenId, quota)
 		if err != nil {
@@ -228,25 +240,6 @@ func PreConsumeTokenQuota(tokenId int, quota int) (err error) {
 	return err
 }
 
-func sendQuotaWarningEmail(userId int, userQuota int, noMoreQuota bool) {
-	email, err := GetUserEmail(userId)
-	if err != nil {
-		common.SysError("failed to fetch user email: " + err.Error())
-	}
-	prompt := "您的额度即将用尽"
-	if noMoreQuota {
-		prompt = "您的额度已用尽"
-	}
-	if email != "" {
-		topUpLink := fmt.Sprintf("%s/topup", common.ServerAddress)
-		err = common.SendEmail(prompt, email,
-			fmt.Sprintf("%s，当前剩余额度为 %d，为了不影响您的使用，请及时充值。<br/>充值链接：<a href='%s'>%s</a>", prompt, userQuota, topUpLink, topUpLink))
-		if err != nil {
-			common.SysError("failed to send email" + err.Error())
-		}
-	}
-}
-
 func PostConsumeTokenQuota(tokenId int, quota int) (err error) {
 	token, err := GetTokenById(tokenId)
 	if quota > 0 {
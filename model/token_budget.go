@@ -0,0 +1,133 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 令牌的周期预算，每个周期开始时已用额度清零，按服务器所在时区计算周期
+const (
+	TokenBudgetPeriodDaily   = "daily"
+	TokenBudgetPeriodWeekly  = "weekly" // 每周一零点重置
+	TokenBudgetPeriodMonthly = "monthly"
+)
+
+const tokenBudgetResetInterval = time.Minute
+
+func isValidBudgetPeriod(period string) bool {
+	switch period {
+	case "", TokenBudgetPeriodDaily, TokenBudgetPeriodWeekly, TokenBudgetPeriodMonthly:
+		return true
+	}
+	return false
+}
+
+// NextBudgetResetTime 下一个周期的开始时间
+func NextBudgetResetTime(period string, now time.Time) int64 {
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	switch period {
+	case TokenBudgetPeriodDaily:
+		return today.AddDate(0, 0, 1).Unix()
+	case TokenBudgetPeriodWeekly:
+		days := (int(time.Monday) - int(today.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return today.AddDate(0, 0, days).Unix()
+	case TokenBudgetPeriodMonthly:
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, now.Location()).Unix()
+	}
+	return 0
+}
+
+// HasBudget 是否设置了周期预算
+func (token *Token) HasBudget() bool {
+	return token.BudgetPeriod != "" && token.BudgetLimit > 0
+}
+
+// GetBudgetUsedQuota 当前周期已用额度，已到重置时间但定时任务尚未执行时视为 0
+func (token *Token) GetBudgetUsedQuota() int {
+	if token.BudgetResetTime <= common.GetTimestamp() {
+		return 0
+	}
+	return token.BudgetUsedQuota
+}
+
+func (token *Token) validateBudget() error {
+	if !isValidBudgetPeriod(token.BudgetPeriod) {
+		return errors.New("预算周期只能是 daily、weekly 或 monthly")
+	}
+	if token.BudgetLimit < 0 {
+		return errors.New("周期预算不能为负数")
+	}
+	if token.AlertPercent < 0 || token.AlertPercent > 100 {
+		return errors.New("预算提醒的百分比应在 0 到 100 之间")
+	}
+	return nil
+}
+
+// SetBudget 修改预算周期时从新周期开始重新计算已用额度
+func (token *Token) SetBudget(period string, limit int) {
+	if period != token.BudgetPeriod {
+		token.BudgetUsedQuota = 0
+		token.BudgetResetTime = NextBudgetResetTime(period, time.Now())
+	}
+	token.BudgetPeriod = period
+	token.BudgetLimit = limit
+}
+
+// ResetTokenBudget 到达重置时间时清零已用额度，以原重置时间为条件，多个节点同时执行时只会重置一次
+func ResetTokenBudget(token *Token) error {
+	if token.BudgetPeriod == "" || token.BudgetResetTime > common.GetTimestamp() {
+		return nil
+	}
+	nextResetTime := NextBudgetResetTime(token.BudgetPeriod, time.Now())
+	err := DB.Model(&Token{}).Where("id = ? AND budget_reset_time = ?", token.Id, token.BudgetResetTime).Updates(
+		map[string]interface{}{
+			"budget_used_quota": 0,
+			"budget_reset_time": nextResetTime,
+		},
+	).Error
+	if err != nil {
+		return err
+	}
+	token.BudgetUsedQuota = 0
+	token.BudgetResetTime = nextResetTime
+	return nil
+}
+
+func IncreaseTokenBudgetUsedQuota(id int, quota int) error {
+	return DB.Model(&Token{}).Where("id = ?", id).
+		Update("budget_used_quota", gorm.Expr("budget_used_quota + ?", quota)).Error
+}
+
+func resetTokenBudgets() {
+	var tokens []*Token
+	err := DB.Select("id", "budget_period", "budget_reset_time").
+		Where("budget_period <> ? AND budget_reset_time <= ?", "", common.GetTimestamp()).Find(&tokens).Error
+	if err != nil {
+		common.SysError("failed to fetch token budgets: " + err.Error())
+		return
+	}
+	for _, token := range tokens {
+		if err := ResetTokenBudget(token); err != nil {
+			common.SysError("failed to reset token budget: " + err.Error())
+		}
+	}
+	if len(tokens) > 0 {
+		common.SysLog(fmt.Sprintf("%d token budgets reset", len(tokens)))
+	}
+}
+
+// SyncTokenBudgets 定时重置到期的周期预算
+func SyncTokenBudgets() {
+	for {
+		resetTokenBudgets()
+		time.Sleep(tokenBudgetResetInterval)
+	}
+}
@@ -0,0 +1,79 @@
+package model_test
+
+import (
+	"one-api/common"
+	"one-api/common/test"
+	_ "one-api/common/test/init"
+	"one-api/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextBudgetResetTime(t *testing.T) {
+	wednesday := time.Date(2024, 1, 31, 15, 30, 0, 0, time.Local)
+	monday := time.Date(2024, 2, 5, 0, 0, 0, 0, time.Local)
+
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local).Unix(), model.NextBudgetResetTime(model.TokenBudgetPeriodDaily, wednesday))
+	assert.Equal(t, monday.Unix(), model.NextBudgetResetTime(model.TokenBudgetPeriodWeekly, wednesday))
+	// 周一当天重置到下周一
+	assert.Equal(t, monday.AddDate(0, 0, 7).Unix(), model.NextBudgetResetTime(model.TokenBudgetPeriodWeekly, monday))
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local).Unix(), model.NextBudgetResetTime(model.TokenBudgetPeriodMonthly, wednesday))
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local).Unix(), model.NextBudgetResetTime(model.TokenBudgetPeriodMonthly, time.Date(2024, 12, 15, 0, 0, 0, 0, time.Local)))
+	assert.Equal(t, int64(0), model.NextBudgetResetTime("", wednesday))
+}
+
+func TestResetTokenBudget(t *testing.T) {
+	test.InitTestDB(t)
+	user := test.CreateTestUser(t, "budget", 0)
+
+	now := common.GetTimestamp()
+	token := &model.Token{
+		UserId:          user.Id,
+		Key:             common.GenerateKey(),
+		Name:            "budget",
+		CreatedTime:     now,
+		ExpiredTime:     -1,
+		BudgetPeriod:    model.TokenBudgetPeriodDaily,
+		BudgetLimit:     1000,
+		BudgetUsedQuota: 800,
+		BudgetResetTime: now - 1,
+	}
+	assert.Nil(t, model.DB.Create(token).Error)
+
+	// 已到重置时间但尚未重置时视为 0
+	assert.Equal(t, 0, token.GetBudgetUsedQuota())
+
+	stale := *token
+	assert.Nil(t, model.ResetTokenBudget(token))
+	assert.Equal(t, 0, token.BudgetUsedQuota)
+	assert.Greater(t, token.BudgetResetTime, now)
+
+	// 其他节点以旧的重置时间再次执行时不会清零新周期的用量
+	assert.Nil(t, model.IncreaseTokenBudgetUsedQuota(token.Id, 300))
+	assert.Nil(t, model.ResetTokenBudget(&stale))
+	saved, err := model.GetTokenById(token.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, 300, saved.BudgetUsedQuota)
+	assert.Equal(t, 300, saved.GetBudgetUsedQuota())
+	assert.Equal(t, token.BudgetResetTime, saved.BudgetResetTime)
+
+	// 未到重置时间时不重置
+	assert.Nil(t, model.ResetTokenBudget(saved))
+	assert.Equal(t, 300, saved.BudgetUsedQuota)
+}
+
+func TestSetTokenBudget(t *testing.T) {
+	token := &model.Token{BudgetPeriod: model.TokenBudgetPeriodDaily, BudgetLimit: 1000, BudgetUsedQuota: 800, BudgetResetTime: 1}
+
+	token.SetBudget(model.TokenBudgetPeriodDaily, 2000)
+	assert.Equal(t, 800, token.BudgetUsedQuota)
+	assert.Equal(t, int64(1), token.BudgetResetTime)
+
+	// 修改周期时从新周期开始计算
+	token.SetBudget(model.TokenBudgetPeriodWeekly, 2000)
+	assert.Equal(t, 0, token.BudgetUsedQuota)
+	assert.Equal(t, model.NextBudgetResetTime(model.TokenBudgetPeriodWeekly, time.Now()), token.BudgetResetTime)
+	assert.True(t, token.HasBudget())
+}
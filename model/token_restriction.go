@@ -0,0 +1,183 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// TokenModelUsage 令牌在单个模型限额下已使用的额度，model 为 ModelLimits 中配置的模型名称或通配符
+type TokenModelUsage struct {
+	Id        int    `json:"id"`
+	TokenId   int    `json:"token_id" gorm:"uniqueIndex:idx_token_model"`
+	Model     string `json:"model" gorm:"type:varchar(255);uniqueIndex:idx_token_model"`
+	UsedQuota int    `json:"used_quota" gorm:"default:0"`
+}
+
+// 将逗号或换行分隔的列表拆分
+func splitTokenList(value string) []string {
+	result := make([]string, 0)
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// MatchModelPattern 模型名称匹配，pattern 中的 * 匹配任意字符，例如 gpt-4*、*-turbo
+func MatchModelPattern(pattern string, modelName string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == modelName
+	}
+
+	if !strings.HasPrefix(modelName, parts[0]) {
+		return false
+	}
+	modelName = modelName[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		index := strings.Index(modelName, part)
+		if index < 0 {
+			return false
+		}
+		modelName = modelName[index+len(part):]
+	}
+	return strings.HasSuffix(modelName, parts[len(parts)-1])
+}
+
+// GetModels 令牌允许调用的模型，为空时不限制
+func (token *Token) GetModels() []string {
+	return splitTokenList(token.Models)
+}
+
+// IsModelAllowed 检查模型是否在令牌允许的列表中，列表为空时不限制
+func IsModelAllowed(patterns []string, modelName string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if MatchModelPattern(pattern, modelName) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIpAllowed 检查客户端 IP 是否在令牌的白名单内，支持单个 IP 及 CIDR，为空时不限制
+func (token *Token) IsIpAllowed(ip string) bool {
+	subnets := splitTokenList(token.AllowIps)
+	if len(subnets) == 0 {
+		return true
+	}
+
+	clientIp := net.ParseIP(ip)
+	if clientIp == nil {
+		return false
+	}
+	for _, subnet := range subnets {
+		if !strings.Contains(subnet, "/") {
+			if allowIp := net.ParseIP(subnet); allowIp != nil && allowIp.Equal(clientIp) {
+				return true
+			}
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(subnet)
+		if err == nil && ipNet.Contains(clientIp) {
+			return true
+		}
+	}
+	return false
+}
+
+func (token *Token) getModelLimits() (map[string]int, error) {
+	limits := make(map[string]int)
+	if strings.TrimSpace(token.ModelLimits) == "" {
+		return limits, nil
+	}
+	err := json.Unmarshal([]byte(token.ModelLimits), &limits)
+	return limits, err
+}
+
+// GetModelLimit 返回模型对应的限额配置，精确匹配优先，其次为最长的通配符
+func (token *Token) GetModelLimit(modelName string) (pattern string, limit int, ok bool) {
+	limits, err := token.getModelLimits()
+	if err != nil {
+		return "", 0, false
+	}
+	if limit, ok := limits[modelName]; ok {
+		return modelName, limit, true
+	}
+	for key, value := range limits {
+		if MatchModelPattern(key, modelName) && len(key) > len(pattern) {
+			pattern, limit, ok = key, value, true
+		}
+	}
+	return
+}
+
+// ValidateRestrictions 保存令牌前校验 IP 白名单、模型限额及周期预算的格式
+func (token *Token) ValidateRestrictions() error {
+	for _, subnet := range splitTokenList(token.AllowIps) {
+		if strings.Contains(subnet, "/") {
+			if _, _, err := net.ParseCIDR(subnet); err != nil {
+				return fmt.Errorf("无效的 IP 段：%s", subnet)
+			}
+		} else if net.ParseIP(subnet) == nil {
+			return fmt.Errorf("无效的 IP：%s", subnet)
+		}
+	}
+
+	limits, err := token.getModelLimits()
+	if err != nil {
+		return errors.New("模型限额格式错误，应为 JSON 对象，例如 {\"gpt-4*\": 500000}")
+	}
+	for key, value := range limits {
+		if value < 0 {
+			return fmt.Errorf("模型 %s 的限额不能为负数", key)
+		}
+	}
+	return token.validateBudget()
+}
+
+func GetTokenModelUsedQuota(tokenId int, pattern string) (int, error) {
+	var usage TokenModelUsage
+	err := DB.Where("token_id = ? AND model = ?", tokenId, pattern).First(&usage).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return usage.UsedQuota, err
+}
+
+func GetTokenModelUsages(tokenId int) (usages []*TokenModelUsage, err error) {
+	err = DB.Where("token_id = ?", tokenId).Find(&usages).Error
+	return usages, err
+}
+
+// IncreaseTokenModelUsedQuota 累加令牌在模型限额下的用量，记录不存在时创建
+func IncreaseTokenModelUsedQuota(tokenId int, pattern string, quota int) error {
+	result := DB.Model(&TokenModelUsage{}).Where("token_id = ? AND model = ?", tokenId, pattern).
+		Update("used_quota", gorm.Expr("used_quota + ?", quota))
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+
+	err := DB.Create(&TokenModelUsage{TokenId: tokenId, Model: pattern, UsedQuota: quota}).Error
+	if err != nil {
+		// 并发创建时唯一索引冲突，再累加一次
+		return DB.Model(&TokenModelUsage{}).Where("token_id = ? AND model = ?", tokenId, pattern).
+			Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error
+	}
+	return nil
+}
+
+func deleteTokenModelUsages(tokenId int) error {
+	return DB.Where("token_id = ?", tokenId).Delete(&TokenModelUsage{}).Error
+}
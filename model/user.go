@@ -31,6 +31,13 @@ type User struct {
 	AffCode          string `json:"aff_code" gorm:"type:varchar(32);column:aff_code;uniqueIndex"`
 	InviterId        int    `json:"inviter_id" gorm:"type:int;column:inviter_id;index"`
 	CreatedTime      int64  `json:"created_time" gorm:"bigint"`
+	RateLimitRPM     *int   `json:"rate_limit_rpm" gorm:"default:0"`                    // 每分钟请求数上限，0 时使用分组的配置，-1 为不限制
+	RateLimitTPM     *int   `json:"rate_limit_tpm" gorm:"default:0"`                    // 每分钟 token 数上限，0 时使用分组的配置，-1 为不限制
+	ConcurrencyLimit *int   `json:"concurrency_limit" gorm:"default:0"`                 // 同时处理的请求数上限，0 为不限制
+	AlertQuota       *int   `json:"alert_quota" gorm:"default:0"`                       // 余额低于该额度时提醒，0 时使用系统的 QuotaRemindThreshold，-1 为不提醒
+	NotifyWebhook    string `json:"notify_webhook" gorm:"type:varchar(255);default:''"` // 额度提醒同时推送到该地址
+	BaseGroup        string `json:"base_group" gorm:"type:varchar(32);default:''"`      // 临时分组到期后恢复的分组
+	GroupExpireTime  int64  `json:"group_expire_time" gorm:"bigint;default:0"`          // 临时分组的到期时间，0 为长期有效
 }
 
 type UserUpdates func(*User)
@@ -306,6 +313,25 @@ func GetUserEmail(id int) (email string, err error) {
 	return email, err
 }
 
+// GetUserRateLimit 用户单独设置的限流，未设置的项为 0
+func GetUserRateLimit(id int) (limit common.RateLimit, err error) {
+	var user User
+	err = DB.Select("rate_limit_rpm", "rate_limit_tpm", "concurrency_limit").First(&user, "id = ?", id).Error
+	if err != nil {
+		return limit, err
+	}
+	if user.RateLimitRPM != nil {
+		limit.RPM = *user.RateLimitRPM
+	}
+	if user.RateLimitTPM != nil {
+		limit.TPM = *user.RateLimitTPM
+	}
+	if user.ConcurrencyLimit != nil {
+		limit.Concurrency = *user.ConcurrencyLimit
+	}
+	return limit, nil
+}
+
 func GetUserGroup(id int) (group string, err error) {
 	groupCol := "`group`"
 	if common.UsingPostgreSQL {
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const userGroupExpireInterval = time.Minute
+
+// UpgradeUserGroup 将用户临时切换到 group，duration 秒后恢复为升级前的分组
+// 已临时处于同一分组时顺延到期时间，处于其他临时分组时从现在重新计算，恢复的分组不变
+// 用户本身就在 group 中时不做变更，返回的到期时间为 0
+func UpgradeUserGroup(tx *gorm.DB, userId int, group string, duration int64) (expireTime int64, err error) {
+	if duration <= 0 {
+		return 0, errors.New("无效的有效期")
+	}
+	return setTemporaryGroup(tx, userId, group, func(current int64, now int64) int64 {
+		if current > now {
+			return current + duration
+		}
+		return now + duration
+	})
+}
+
+// SetUserGroupUntil 将用户临时切换到 group 直到 expireTime，已临时处于同一分组且到期更晚时保留原到期时间
+func SetUserGroupUntil(tx *gorm.DB, userId int, group string, expireTime int64) (int64, error) {
+	return setTemporaryGroup(tx, userId, group, func(current int64, now int64) int64 {
+		if current > expireTime {
+			return current
+		}
+		return expireTime
+	})
+}
+
+// setTemporaryGroup 的 nextExpireTime 根据同一临时分组当前的到期时间（没有时为 0）计算新的到期时间
+func setTemporaryGroup(tx *gorm.DB, userId int, group string, nextExpireTime func(current int64, now int64) int64) (int64, error) {
+	if group == "" {
+		return 0, errors.New("无效的分组")
+	}
+	var user User
+	err := tx.Select("id", "group", "base_group", "group_expire_time").First(&user, "id = ?", userId).Error
+	if err != nil {
+		return 0, err
+	}
+
+	now := common.GetTimestamp()
+	baseGroup := user.Group
+	var current int64
+	if user.GroupExpireTime > 0 {
+		baseGroup = user.BaseGroup
+		if user.Group == group {
+			current = user.GroupExpireTime
+		}
+	}
+	if baseGroup == group {
+		return 0, nil
+	}
+
+	expireTime := nextExpireTime(current, now)
+	result := tx.Model(&User{}).Where("id = ? AND group_expire_time = ?", userId, user.GroupExpireTime).Updates(map[string]interface{}{
+		"group":             group,
+		"base_group":        baseGroup,
+		"group_expire_time": expireTime,
+	})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, errors.New("用户分组已变更，请重试")
+	}
+	return expireTime, nil
+}
+
+// EndUserGroup 提前结束临时分组，只有到期时间不晚于 expireTime 时才恢复，避免结束其他来源延长的分组
+func EndUserGroup(tx *gorm.DB, userId int, group string, expireTime int64) error {
+	return tx.Model(&User{}).
+		Where("id = ? AND "+quotePostgresField("group")+" = ? AND group_expire_time > 0 AND group_expire_time <= ?", userId, group, expireTime).
+		Updates(map[string]interface{}{
+			"group":             gorm.Expr("base_group"),
+			"base_group":        "",
+			"group_expire_time": 0,
+		}).Error
+}
+
+// ClearUserGroupCache 分组变更后清除缓存
+func ClearUserGroupCache(userId int) {
+	if !common.RedisEnabled {
+		return
+	}
+	if err := common.RedisDel(fmt.Sprintf("user_group:%d", userId)); err != nil {
+		common.SysError("Redis del user group error: " + err.Error())
+	}
+}
+
+// RestoreUserGroup 临时分组到期后恢复为升级前的分组
+func RestoreUserGroup(user *User) error {
+	result := DB.Model(&User{}).Where("id = ? AND group_expire_time = ?", user.Id, user.GroupExpireTime).Updates(map[string]interface{}{
+		"group":             user.BaseGroup,
+		"base_group":        "",
+		"group_expire_time": 0,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	ClearUserGroupCache(user.Id)
+	RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("分组 %s 已到期，恢复为 %s", user.Group, user.BaseGroup))
+	return nil
+}
+
+func restoreExpiredUserGroups() {
+	var users []*User
+	err := DB.Select("id", "group", "base_group", "group_expire_time").
+		Where("group_expire_time > 0 AND group_expire_time <= ?", common.GetTimestamp()).Find(&users).Error
+	if err != nil {
+		common.SysError("failed to fetch expired user groups: " + err.Error())
+		return
+	}
+	for _, user := range users {
+		if err := RestoreUserGroup(user); err != nil {
+			common.SysError("failed to restore user group: " + err.Error())
+		}
+	}
+	if len(users) > 0 {
+		common.SysLog(fmt.Sprintf("%d user groups restored", len(users)))
+	}
+}
+
+// SyncUserGroupExpiry 定时恢复到期的临时分组
+func SyncUserGroupExpiry() {
+	for {
+		restoreExpiredUserGroups()
+		time.Sleep(userGroupExpireInterval)
+	}
+}
@@ -0,0 +1,177 @@
+package payment
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Epay 易支付及兼容易支付协议的聚合支付
+type Epay struct{}
+
+type epayResponse struct {
+	Code      int    `json:"code"`
+	Msg       string `json:"msg,omitempty"`
+	TradeNo   string `json:"trade_no"`
+	PayUrl    string `json:"payurl,omitempty"`
+	QRcode    string `json:"qrcode,omitempty"`
+	UrlScheme string `json:"urlscheme,omitempty"`
+}
+
+var epayClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+func init() {
+	register(&Epay{})
+}
+
+func (e *Epay) Name() string {
+	return "epay"
+}
+
+func (e *Epay) Enabled() bool {
+	return common.EpayEnabled && common.EpayAddress != "" && common.EpayPid != "" && common.EpaySecret != ""
+}
+
+func (e *Epay) PayTypes() []string {
+	return []string{"alipay", "wxpay"}
+}
+
+func (e *Epay) CreatePayment(order *Order) (*PayResult, error) {
+	params := map[string]string{
+		"pid":          common.EpayPid,
+		"type":         order.PayType,
+		"out_trade_no": order.TradeNo,
+		"notify_url":   order.NotifyURL,
+		"return_url":   order.ReturnURL,
+		"name":         order.Subject,
+		"money":        fmt.Sprintf("%.2f", order.Money),
+		"clientip":     order.ClientIP,
+		"sign_type":    "MD5",
+	}
+	params["sign"] = EpaySignature(params, common.EpaySecret)
+
+	data := url.Values{}
+	for k, v := range params {
+		data.Set(k, v)
+	}
+	resp, err := epayClient.PostForm(strings.TrimSuffix(common.EpayAddress, "/")+"/mapi.php", data)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var epayResp epayResponse
+	if err := json.NewDecoder(resp.Body).Decode(&epayResp); err != nil {
+		return nil, err
+	}
+	if epayResp.Code != 1 {
+		return nil, errors.New(epayResp.Msg)
+	}
+	if epayResp.PayUrl != "" {
+		return &PayResult{PayURL: epayResp.PayUrl}, nil
+	}
+	if epayResp.QRcode != "" {
+		return &PayResult{QRCode: epayResp.QRcode}, nil
+	}
+	return nil, errors.New("返回的是小程序支付链接")
+}
+
+// HandleNotify 易支付以 GET 方式通知，部分兼容实现使用 POST 表单，返回 success 后不再重复通知
+func (e *Epay) HandleNotify(c *gin.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		c.String(http.StatusBadRequest, "fail")
+		return
+	}
+	params := make(map[string]string)
+	for k := range c.Request.Form {
+		params[k] = c.Request.Form.Get(k)
+	}
+
+	tradeNo := params["out_trade_no"]
+	if params["pid"] != common.EpayPid || subtle.ConstantTimeCompare([]byte(params["sign"]), []byte(EpaySignature(params, common.EpaySecret))) != 1 {
+		common.LogError(c.Request.Context(), fmt.Sprintf("收到未验证签名的支付异步通知: pid=%s, out_trade_no=%s", params["pid"], tradeNo))
+		c.String(http.StatusOK, "fail")
+		return
+	}
+	if params["trade_status"] != "TRADE_SUCCESS" {
+		c.String(http.StatusOK, "success")
+		return
+	}
+	money, _ := strconv.ParseFloat(params["money"], 64)
+	if err := CompleteOrder(tradeNo, params["trade_no"], money); err != nil {
+		common.LogError(c.Request.Context(), fmt.Sprintf("充值失败: out_trade_no=%s, %s", tradeNo, err.Error()))
+		c.String(http.StatusOK, "fail")
+		return
+	}
+	c.String(http.StatusOK, "success")
+}
+
+type epayQueryResponse struct {
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	TradeNo string `json:"trade_no"`
+	Money   string `json:"money"`
+	Status  int    `json:"status"` // 1 为已支付
+}
+
+func (e *Epay) QueryOrder(rechargeLog *model.RechargeLog) (*QueryResult, error) {
+	query := url.Values{}
+	query.Set("act", "order")
+	query.Set("pid", common.EpayPid)
+	query.Set("key", common.EpaySecret)
+	query.Set("out_trade_no", rechargeLog.TradeNo)
+	resp, err := epayClient.Get(strings.TrimSuffix(common.EpayAddress, "/") + "/api.php?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var epayResp epayQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&epayResp); err != nil {
+		return nil, err
+	}
+	// 订单不存在时同样返回非 1 的 code，视为未支付
+	if epayResp.Code != 1 || epayResp.Status != 1 {
+		return &QueryResult{}, nil
+	}
+	money, _ := strconv.ParseFloat(epayResp.Money, 64)
+	return &QueryResult{Paid: true, GatewayTradeNo: epayResp.TradeNo, Money: money}, nil
+}
+
+func (e *Epay) RegisterRoutes(router gin.IRouter) {
+	router.GET("/notify", e.HandleNotify)
+	router.POST("/notify", e.HandleNotify)
+}
+
+// EpaySignature 参数按键名升序拼接（不含空值及 sign、sign_type）后加上密钥取 MD5
+func EpaySignature(params map[string]string, key string) string {
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k != "sign" && k != "sign_type" && v != "" {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+params[k])
+	}
+	hash := md5.Sum([]byte(strings.Join(pairs, "&") + key))
+	return hex.EncodeToString(hash[:])
+}
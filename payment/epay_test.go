@@ -0,0 +1,67 @@
+package payment_test
+
+import (
+	"net/url"
+	"one-api/common"
+	"one-api/common/test"
+	_ "one-api/common/test/init"
+	"one-api/model"
+	"one-api/payment"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEpayNotify(t *testing.T) {
+	test.InitTestDB(t)
+	common.EpayPid = "1001"
+	common.EpaySecret = "secret"
+	user := test.CreateTestUser(t, "epay", 0)
+
+	rechargeLog := &model.RechargeLog{UserId: user.Id, Quota: 1000, Gateway: "epay", Money: 7.3}
+	assert.Nil(t, model.CreateRechargeLog(rechargeLog))
+
+	notify := func(params map[string]string) string {
+		query := url.Values{}
+		for k, v := range params {
+			query.Set(k, v)
+		}
+		c, w := test.GetContext("GET", "/api/payment/epay/notify?"+query.Encode(), nil, nil)
+		(&payment.Epay{}).HandleNotify(c)
+		return w.Body.String()
+	}
+	params := map[string]string{
+		"pid":          common.EpayPid,
+		"out_trade_no": rechargeLog.TradeNo,
+		"trade_no":     "2024010112345",
+		"trade_status": "TRADE_SUCCESS",
+		"money":        "7.30",
+		"sign_type":    "MD5",
+	}
+
+	// 签名错误时不入账
+	params["sign"] = "0123456789abcdef0123456789abcdef"
+	assert.Equal(t, "fail", notify(params))
+	quota, _ := model.GetUserQuota(user.Id)
+	assert.Equal(t, 0, quota)
+
+	params["sign"] = payment.EpaySignature(params, common.EpaySecret)
+	assert.Equal(t, "success", notify(params))
+	// 网关重复通知时同样返回 success，不重复入账
+	assert.Equal(t, "success", notify(params))
+	quota, _ = model.GetUserQuota(user.Id)
+	assert.Equal(t, 1000, quota)
+}
+
+func TestEpaySignature(t *testing.T) {
+	params := map[string]string{
+		"pid":       "1001",
+		"name":      "充值",
+		"money":     "1.00",
+		"empty":     "",
+		"sign":      "ignored",
+		"sign_type": "MD5",
+	}
+	// md5("money=1.00&name=充值&pid=1001secret")
+	assert.Equal(t, "808503dc189da7bc94bcf234cf72eb8b", payment.EpaySignature(params, "secret"))
+}
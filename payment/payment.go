@@ -0,0 +1,213 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"one-api/common"
+	"one-api/model"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentGateway 支付网关，新增网关时实现该接口并在 init 中调用 register
+type PaymentGateway interface {
+	// Name 网关标识，同时作为回调路由的前缀 /api/payment/:name
+	Name() string
+	Enabled() bool
+	// PayTypes 支持的支付方式，例如 alipay、wxpay
+	PayTypes() []string
+	// CreatePayment 向网关下单，返回支付链接或二维码内容
+	CreatePayment(order *Order) (*PayResult, error)
+	// HandleNotify 处理网关的异步通知，验证通过后调用 CompleteOrder
+	HandleNotify(c *gin.Context)
+	// RegisterRoutes 注册网关自己的回调路由
+	RegisterRoutes(router gin.IRouter)
+}
+
+// OrderQuerier 支持主动查询订单状态的网关，用于补偿丢失的异步通知
+type OrderQuerier interface {
+	QueryOrder(rechargeLog *model.RechargeLog) (*QueryResult, error)
+}
+
+type QueryResult struct {
+	Paid           bool
+	GatewayTradeNo string
+	Money          float64
+}
+
+type Order struct {
+	TradeNo   string
+	UserId    int
+	PayType   string
+	Subject   string
+	Amount    int     // 充值金额，单位与 QuotaPerUnit 一致，订阅订单为 0
+	Money     float64 // 实付金额
+	NotifyURL string
+	ReturnURL string
+	ClientIP  string
+}
+
+type PayResult struct {
+	PayURL string `json:"pay_url,omitempty"`
+	QRCode string `json:"qrcode,omitempty"`
+}
+
+// GatewayInfo 前端展示的网关信息
+type GatewayInfo struct {
+	Name     string   `json:"name"`
+	PayTypes []string `json:"pay_types"`
+}
+
+var gateways = make(map[string]PaymentGateway)
+
+func register(gateway PaymentGateway) {
+	gateways[gateway.Name()] = gateway
+}
+
+func GetGateway(name string) (PaymentGateway, bool) {
+	gateway, ok := gateways[name]
+	return gateway, ok
+}
+
+// GetEnabledGateways 已启用的网关，按名称排序
+func GetEnabledGateways() []*GatewayInfo {
+	infos := make([]*GatewayInfo, 0, len(gateways))
+	for _, gateway := range gateways {
+		if gateway.Enabled() {
+			infos = append(infos, &GatewayInfo{Name: gateway.Name(), PayTypes: gateway.PayTypes()})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// SetRouter 为每个网关注册回调路由
+func SetRouter(router gin.IRouter) {
+	for name, gateway := range gateways {
+		gateway.RegisterRoutes(router.Group("/" + name))
+	}
+}
+
+// CallbackURL 网关回调及跳转的完整地址
+func CallbackURL(path string) string {
+	address := common.PaymentCallbackAddress
+	if address == "" {
+		address = common.ServerAddress
+	}
+	return strings.TrimSuffix(address, "/") + path
+}
+
+func getEnabledGateway(gatewayName string, payType string) (PaymentGateway, error) {
+	gateway, ok := GetGateway(gatewayName)
+	if !ok || !gateway.Enabled() {
+		return nil, errors.New("支付方式未启用")
+	}
+	if !slices.Contains(gateway.PayTypes(), payType) {
+		return nil, errors.New("无效的支付方式")
+	}
+	return gateway, nil
+}
+
+// CreateOrder 校验金额后创建充值订单并向网关下单
+func CreateOrder(gatewayName string, payType string, amount int, userId int, clientIP string) (*PayResult, string, error) {
+	gateway, err := getEnabledGateway(gatewayName, payType)
+	if err != nil {
+		return nil, "", err
+	}
+	if amount < common.PaymentMinAmount {
+		return nil, "", fmt.Errorf("至少充值 %d$", common.PaymentMinAmount)
+	}
+	if common.PaymentMaxAmount > 0 && amount > common.PaymentMaxAmount {
+		return nil, "", fmt.Errorf("单次最多充值 %d$", common.PaymentMaxAmount)
+	}
+
+	money := float64(amount) * common.PaymentExchangeRate
+	rechargeLog := &model.RechargeLog{
+		UserId:  userId,
+		Quota:   amount * int(common.QuotaPerUnit),
+		Gateway: gatewayName,
+		PayType: payType,
+		Money:   math.Round(money*100) / 100,
+	}
+	if err := model.CreateRechargeLog(rechargeLog); err != nil {
+		return nil, "", err
+	}
+	model.RecordLog(userId, model.LogTypeTopup, fmt.Sprintf("创建充值请求，请求金额: %d$，支付方式: %s", amount, gatewayName))
+
+	result, err := createPayment(gateway, rechargeLog, "VIP会员", amount, clientIP)
+	if err != nil {
+		return nil, "", err
+	}
+	return result, rechargeLog.TradeNo, nil
+}
+
+// CreatePlanOrder 创建订阅套餐的订单，已有订阅时按续费或升级报价
+func CreatePlanOrder(gatewayName string, payType string, planId int, cycles int, userId int, clientIP string) (*PayResult, *model.RechargeLog, error) {
+	gateway, err := getEnabledGateway(gatewayName, payType)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := model.GetPlanById(planId)
+	if err != nil || plan.Status != common.PlanStatusEnabled {
+		return nil, nil, errors.New("套餐不存在或已下架")
+	}
+	quote, err := model.GetSubscriptionQuote(userId, plan, cycles)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rechargeLog := &model.RechargeLog{
+		UserId:  userId,
+		Name:    "订阅",
+		Quota:   0,
+		Gateway: gatewayName,
+		PayType: payType,
+		Money:   quote.Money,
+		PlanId:  plan.Id,
+		Cycles:  cycles,
+	}
+	if err := model.CreateRechargeLog(rechargeLog); err != nil {
+		return nil, nil, err
+	}
+	model.RecordLog(userId, model.LogTypeTopup, fmt.Sprintf("创建订阅请求，套餐: %s，周期数: %d，支付金额: %.2f，支付方式: %s", plan.Name, cycles, quote.Money, gatewayName))
+
+	result, err := createPayment(gateway, rechargeLog, plan.Name, 0, clientIP)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, rechargeLog, nil
+}
+
+func createPayment(gateway PaymentGateway, rechargeLog *model.RechargeLog, subject string, amount int, clientIP string) (*PayResult, error) {
+	result, err := gateway.CreatePayment(&Order{
+		TradeNo:   rechargeLog.TradeNo,
+		UserId:    rechargeLog.UserId,
+		PayType:   rechargeLog.PayType,
+		Subject:   subject,
+		Amount:    amount,
+		Money:     rechargeLog.Money,
+		NotifyURL: CallbackURL(fmt.Sprintf("/api/payment/%s/notify", gateway.Name())),
+		ReturnURL: CallbackURL("/panel/topup"),
+		ClientIP:  clientIP,
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("payment %s create order %s failed: %s", gateway.Name(), rechargeLog.TradeNo, err.Error()))
+		return nil, errors.New("支付通道异常，请稍后再试")
+	}
+	return result, nil
+}
+
+// CompleteOrder 网关确认支付成功后为用户充值，重复通知时直接返回成功
+func CompleteOrder(tradeNo string, gatewayTradeNo string, money float64) error {
+	_, err := model.CompleteRecharge(tradeNo, gatewayTradeNo, money)
+	if errors.Is(err, model.ErrRechargePaid) {
+		return nil
+	}
+	return err
+}
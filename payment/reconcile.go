@@ -0,0 +1,71 @@
+package payment
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"time"
+)
+
+const (
+	reconcileInterval  = time.Minute
+	reconcileBatchSize = 100
+	reconcileGiveUp    = 24 * time.Hour
+)
+
+// SyncStaleOrders 定时处理超时未支付的订单，网关支持查询时先查询，已支付则补单，否则关闭订单
+func SyncStaleOrders() {
+	for {
+		time.Sleep(reconcileInterval)
+		reconcileStaleOrders()
+	}
+}
+
+// 按 id 分批处理，查询失败暂时保留的订单不会阻塞之后的订单
+func reconcileStaleOrders() {
+	before := time.Now().Add(-time.Duration(common.PaymentOrderExpireMinutes) * time.Minute).Unix()
+	afterId, reconciled := 0, 0
+	for {
+		rechargeLogs, err := model.GetStaleRechargeLogs(before, afterId, reconcileBatchSize)
+		if err != nil {
+			common.SysError("failed to fetch stale recharge orders: " + err.Error())
+			break
+		}
+		for _, rechargeLog := range rechargeLogs {
+			afterId = rechargeLog.Id
+			if reconcileStaleOrder(rechargeLog) {
+				reconciled++
+			}
+		}
+		if len(rechargeLogs) < reconcileBatchSize {
+			break
+		}
+	}
+	if reconciled > 0 {
+		common.SysLog(fmt.Sprintf("%d stale recharge orders reconciled", reconciled))
+	}
+}
+
+// reconcileStaleOrder 查询失败且未超过一天的订单保留到下次再试，返回 false
+func reconcileStaleOrder(rechargeLog *model.RechargeLog) bool {
+	if gateway, ok := GetGateway(rechargeLog.Gateway); ok {
+		if querier, ok := gateway.(OrderQuerier); ok && gateway.Enabled() {
+			result, err := querier.QueryOrder(rechargeLog)
+			if err != nil {
+				common.SysError(fmt.Sprintf("failed to query recharge order %s: %s", rechargeLog.TradeNo, err.Error()))
+				if rechargeLog.CreatedTime > time.Now().Add(-reconcileGiveUp).Unix() {
+					return false
+				}
+			} else if result.Paid {
+				if err := CompleteOrder(rechargeLog.TradeNo, result.GatewayTradeNo, result.Money); err != nil {
+					common.SysError(fmt.Sprintf("failed to complete recharge order %s: %s", rechargeLog.TradeNo, err.Error()))
+				}
+				return true
+			}
+		}
+	}
+	if err := model.ExpireRechargeLog(rechargeLog.Id); err != nil {
+		common.SysError(fmt.Sprintf("failed to expire recharge order %s: %s", rechargeLog.TradeNo, err.Error()))
+	}
+	return true
+}
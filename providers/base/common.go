@@ -25,6 +25,8 @@ type ProviderConfig struct {
 	ImagesGenerations   string
 	ImagesEdit          string
 	ImagesVariations    string
+	Files               string
+	FineTuningJobs      string
 }
 
 type BaseProvider struct {
@@ -71,6 +73,9 @@ func (p *BaseProvider) SetUsage(usage *types.Usage) {
 
 func (p *BaseProvider) SetContext(c *gin.Context) {
 	p.Context = c
+	if p.Requester != nil {
+		p.Requester.SetContext(c.Request.Context())
+	}
 }
 
 func (p *BaseProvider) SetOriginalModel(ModelName string) {
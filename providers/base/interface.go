@@ -98,6 +98,32 @@ type ImageVariationsInterface interface {
 	CreateImageVariations(request *types.ImageEditRequest) (*types.ImageResponse, *types.OpenAIErrorWithStatusCode)
 }
 
+// Assistants 接口
+type AssistantsInterface interface {
+	ProviderInterface
+	SendAssistantsRequest(method, uri string, body any) (*http.Response, *types.OpenAIErrorWithStatusCode)
+}
+
+// 文件接口
+type FilesInterface interface {
+	ProviderInterface
+	CreateFile(request *types.FileRequest) (*types.File, *types.OpenAIErrorWithStatusCode)
+	ListFiles(purpose string) (*types.FilesList, *types.OpenAIErrorWithStatusCode)
+	RetrieveFile(fileId string) (*types.File, *types.OpenAIErrorWithStatusCode)
+	DeleteFile(fileId string) (*types.FileDeleteResponse, *types.OpenAIErrorWithStatusCode)
+	RetrieveFileContent(fileId string) (*http.Response, *types.OpenAIErrorWithStatusCode)
+}
+
+// 微调接口
+type FineTuningInterface interface {
+	ProviderInterface
+	CreateFineTuningJob(request *types.FineTuningJobRequest) (*types.FineTuningJob, *types.OpenAIErrorWithStatusCode)
+	ListFineTuningJobs(params *types.FineTuningListParams) (*types.FineTuningJobList, *types.OpenAIErrorWithStatusCode)
+	RetrieveFineTuningJob(jobId string) (*types.FineTuningJob, *types.OpenAIErrorWithStatusCode)
+	CancelFineTuningJob(jobId string) (*types.FineTuningJob, *types.OpenAIErrorWithStatusCode)
+	ListFineTuningJobEvents(jobId string, params *types.FineTuningListParams) (*types.FineTuningJobEventList, *types.OpenAIErrorWithStatusCode)
+}
+
 // 余额接口
 type BalanceInterface interface {
 	Balance() (float64, error)
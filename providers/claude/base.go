@@ -79,6 +79,8 @@ func stopReasonClaude2OpenAI(reason string) string {
 		return types.FinishReasonStop
 	case "max_tokens":
 		return types.FinishReasonLength
+	case "tool_use":
+		return types.FinishReasonToolCalls
 	default:
 		return reason
 	}
@@ -86,7 +88,7 @@ func stopReasonClaude2OpenAI(reason string) string {
 
 func convertRole(role string) string {
 	switch role {
-	case "user":
+	case "user", "tool":
 		return types.ChatMessageRoleUser
 	default:
 		return types.ChatMessageRoleAssistant
@@ -15,6 +15,8 @@ type claudeStreamHandler struct {
 	Id      string
 	Usage   *types.Usage
 	Request *types.ChatCompletionRequest
+	// claude content block index => openai tool_calls index
+	ToolIndex map[int]int
 }
 
 func (p *ClaudeProvider) CreateChatCompletion(request *types.ChatCompletionRequest) (*types.ChatCompletionResponse, *types.OpenAIErrorWithStatusCode) {
@@ -48,8 +50,9 @@ func (p *ClaudeProvider) CreateChatCompletionStream(request *types.ChatCompletio
 	}
 
 	chatHandler := &claudeStreamHandler{
-		Usage:   p.Usage,
-		Request: request,
+		Usage:     p.Usage,
+		Request:   request,
+		ToolIndex: make(map[int]int),
 	}
 
 	return requester.RequestStream[string](p.Requester, resp, chatHandler.handlerStream)
@@ -107,6 +110,10 @@ func convertFromChatOpenai(request *types.ChatCompletionRequest) (*ClaudeRequest
 		claudeRequest.MaxTokens = 4096
 	}
 
+	if request.Tools != nil {
+		claudeRequest.Tools, claudeRequest.ToolChoice = convertToolsFromOpenai(request.Tools, request.ToolChoice)
+	}
+
 	for _, message := range request.Messages {
 		if message.Role == "system" {
 			systemp := message.Content.(string)
@@ -118,37 +125,111 @@ func convertFromChatOpenai(request *types.ChatCompletionRequest) (*ClaudeRequest
 			Content: []MessageContent{},
 		}
 
-		openaiContent := message.ParseContent()
-		for _, part := range openaiContent {
-			if part.Type == types.ContentTypeText {
-				content.Content = append(content.Content, MessageContent{
-					Type: "text",
-					Text: part.Text,
-				})
-				continue
-			}
+		if message.Role == types.ChatMessageRoleTool {
+			content.Content = append(content.Content, MessageContent{
+				Type:      "tool_result",
+				ToolUseId: message.ToolCallID,
+				Content:   message.StringContent(),
+			})
+		} else {
+			openaiContent := message.ParseContent()
+			for _, part := range openaiContent {
+				if part.Type == types.ContentTypeText {
+					// 带工具调用的 assistant 消息 content 可能为空，claude 不接受空文本
+					if part.Text == "" && len(message.ToolCalls) > 0 {
+						continue
+					}
+					content.Content = append(content.Content, MessageContent{
+						Type: "text",
+						Text: part.Text,
+					})
+					continue
+				}
 
-			if part.Type == types.ContentTypeImageURL {
-				mimeType, data, err := image.GetImageFromUrl(part.ImageURL.URL)
-				if err != nil {
-					return nil, common.ErrorWrapper(err, "image_url_invalid", http.StatusBadRequest)
+				if part.Type == types.ContentTypeImageURL {
+					mimeType, data, err := image.GetImageFromUrl(part.ImageURL.URL)
+					if err != nil {
+						return nil, common.ErrorWrapper(err, "image_url_invalid", http.StatusBadRequest)
+					}
+					content.Content = append(content.Content, MessageContent{
+						Type: "image",
+						Source: &ContentSource{
+							Type:      "base64",
+							MediaType: mimeType,
+							Data:      data,
+						},
+					})
 				}
-				content.Content = append(content.Content, MessageContent{
-					Type: "image",
-					Source: &ContentSource{
-						Type:      "base64",
-						MediaType: mimeType,
-						Data:      data,
-					},
-				})
 			}
 		}
+
+		for _, toolCall := range message.ToolCalls {
+			if toolCall.Function == nil {
+				continue
+			}
+			input := json.RawMessage(toolCall.Function.Arguments)
+			if !json.Valid(input) {
+				input = json.RawMessage("{}")
+			}
+			content.Content = append(content.Content, MessageContent{
+				Type:  "tool_use",
+				Id:    toolCall.Id,
+				Name:  toolCall.Function.Name,
+				Input: input,
+			})
+		}
+
+		// claude 要求 user/assistant 交替出现，多个工具结果需合并到同一条 user 消息中
+		lastIndex := len(claudeRequest.Messages) - 1
+		if lastIndex >= 0 && claudeRequest.Messages[lastIndex].Role == content.Role {
+			claudeRequest.Messages[lastIndex].Content = append(claudeRequest.Messages[lastIndex].Content, content.Content...)
+			continue
+		}
 		claudeRequest.Messages = append(claudeRequest.Messages, content)
 	}
 
 	return &claudeRequest, nil
 }
 
+func convertToolsFromOpenai(tools []*types.ChatCompletionTool, toolChoice any) ([]Tool, *ToolChoice) {
+	var claudeToolChoice *ToolChoice
+	switch choice := toolChoice.(type) {
+	case string:
+		switch choice {
+		case "none":
+			return nil, nil
+		case "required":
+			claudeToolChoice = &ToolChoice{Type: "any"}
+		case "auto":
+			claudeToolChoice = &ToolChoice{Type: "auto"}
+		}
+	case map[string]any:
+		if function, ok := choice["function"].(map[string]any); ok {
+			if name, ok := function["name"].(string); ok && name != "" {
+				claudeToolChoice = &ToolChoice{Type: "tool", Name: name}
+			}
+		}
+	}
+
+	claudeTools := make([]Tool, 0, len(tools))
+	for _, tool := range tools {
+		inputSchema := tool.Function.Parameters
+		if inputSchema == nil {
+			inputSchema = map[string]any{
+				"type":       "object",
+				"properties": map[string]any{},
+			}
+		}
+		claudeTools = append(claudeTools, Tool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: inputSchema,
+		})
+	}
+
+	return claudeTools, claudeToolChoice
+}
+
 func (p *ClaudeProvider) convertToChatOpenai(response *ClaudeResponse, request *types.ChatCompletionRequest) (openaiResponse *types.ChatCompletionResponse, errWithCode *types.OpenAIErrorWithStatusCode) {
 	error := errorHandle(&response.Error)
 	if error != nil {
@@ -162,12 +243,32 @@ func (p *ClaudeProvider) convertToChatOpenai(response *ClaudeResponse, request *
 	choice := types.ChatCompletionChoice{
 		Index: 0,
 		Message: types.ChatCompletionMessage{
-			Role:    response.Role,
-			Content: strings.TrimPrefix(response.Content[0].Text, " "),
-			Name:    nil,
+			Role: response.Role,
+			Name: nil,
 		},
 		FinishReason: stopReasonClaude2OpenAI(response.StopReason),
 	}
+
+	var content string
+	for _, resContent := range response.Content {
+		switch resContent.Type {
+		case "text":
+			content += resContent.Text
+		case "tool_use":
+			choice.Message.ToolCalls = append(choice.Message.ToolCalls, &types.ChatCompletionToolCalls{
+				Id:   resContent.Id,
+				Type: "function",
+				Function: &types.ChatCompletionToolCallsFunction{
+					Name:      resContent.Name,
+					Arguments: string(resContent.Input),
+				},
+				Index: len(choice.Message.ToolCalls),
+			})
+		}
+	}
+	if content != "" || len(choice.Message.ToolCalls) == 0 {
+		choice.Message.Content = strings.TrimPrefix(content, " ")
+	}
 	openaiResponse = &types.ChatCompletionResponse{
 		ID:      response.Id,
 		Object:  "chat.completion",
@@ -219,8 +320,15 @@ func (h *claudeStreamHandler) handlerStream(rawLine *[]byte, dataChan chan strin
 	}
 
 	switch claudeResponse.Type {
+	case "content_block_start":
+		if claudeResponse.ContentBlock.Type == "tool_use" {
+			h.convertToOpenaiStream(&claudeResponse, dataChan)
+		}
 	case "content_block_delta":
 		h.convertToOpenaiStream(&claudeResponse, dataChan)
+		// 先按已输出内容估算用量，客户端中途断开时也能计费，message_delta 会使用上游统计覆盖
+		h.Usage.CompletionTokens += common.CountTokenText(claudeResponse.Delta.Text+claudeResponse.Delta.PartialJson, h.Request.Model)
+		h.Usage.TotalTokens = h.Usage.PromptTokens + h.Usage.CompletionTokens
 	case "message_start":
 		h.Usage.PromptTokens = claudeResponse.Message.Usage.InputTokens
 		h.Id = claudeResponse.Message.Id
@@ -238,7 +346,7 @@ func (h *claudeStreamHandler) handlerStream(rawLine *[]byte, dataChan chan strin
 
 func (h *claudeStreamHandler) convertToOpenaiStream(claudeResponse *ClaudeStreamResponse, dataChan chan string) {
 	choice := types.ChatCompletionStreamChoice{
-		Index: claudeResponse.Index,
+		Index: 0,
 	}
 
 	if claudeResponse.Message.Role != "" {
@@ -249,6 +357,37 @@ func (h *claudeStreamHandler) convertToOpenaiStream(claudeResponse *ClaudeStream
 		choice.Delta.Content = claudeResponse.Delta.Text
 	}
 
+	if claudeResponse.ContentBlock.Type == "tool_use" {
+		toolIndex := len(h.ToolIndex)
+		h.ToolIndex[claudeResponse.Index] = toolIndex
+		choice.Delta.Role = types.ChatMessageRoleAssistant
+		choice.Delta.ToolCalls = []*types.ChatCompletionToolCalls{
+			{
+				Id:    claudeResponse.ContentBlock.Id,
+				Type:  "function",
+				Index: toolIndex,
+				Function: &types.ChatCompletionToolCallsFunction{
+					Name:      claudeResponse.ContentBlock.Name,
+					Arguments: "",
+				},
+			},
+		}
+	}
+
+	if claudeResponse.Delta.Type == "input_json_delta" {
+		if claudeResponse.Delta.PartialJson == "" {
+			return
+		}
+		choice.Delta.ToolCalls = []*types.ChatCompletionToolCalls{
+			{
+				Index: h.ToolIndex[claudeResponse.Index],
+				Function: &types.ChatCompletionToolCallsFunction{
+					Arguments: claudeResponse.Delta.PartialJson,
+				},
+			},
+		}
+	}
+
 	finishReason := stopReasonClaude2OpenAI(claudeResponse.Delta.StopReason)
 	if finishReason != "" {
 		choice.FinishReason = &finishReason
@@ -1,5 +1,7 @@
 package claude
 
+import "encoding/json"
+
 type ClaudeError struct {
 	Type    string `json:"type"`
 	Message string `json:"message"`
@@ -10,8 +12,11 @@ type ClaudeMetadata struct {
 }
 
 type ResContent struct {
-	Text string `json:"text"`
-	Type string `json:"type"`
+	Text  string          `json:"text,omitempty"`
+	Type  string          `json:"type"`
+	Id    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
 }
 
 type ContentSource struct {
@@ -24,6 +29,13 @@ type MessageContent struct {
 	Type   string         `json:"type"`
 	Text   string         `json:"text,omitempty"`
 	Source *ContentSource `json:"source,omitempty"`
+	// tool_use
+	Id    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// tool_result
+	ToolUseId string `json:"tool_use_id,omitempty"`
+	Content   any    `json:"content,omitempty"`
 }
 
 type Message struct {
@@ -31,15 +43,28 @@ type Message struct {
 	Content []MessageContent `json:"content"`
 }
 
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
 type ClaudeRequest struct {
-	Model         string    `json:"model"`
-	System        *string   `json:"system,omitempty"`
-	Messages      []Message `json:"messages"`
-	MaxTokens     int       `json:"max_tokens"`
-	StopSequences []string  `json:"stop_sequences,omitempty"`
-	Temperature   float64   `json:"temperature,omitempty"`
-	TopP          *float64  `json:"top_p,omitempty"`
-	TopK          int       `json:"top_k,omitempty"`
+	Model         string      `json:"model"`
+	System        *string     `json:"system,omitempty"`
+	Messages      []Message   `json:"messages"`
+	MaxTokens     int         `json:"max_tokens"`
+	StopSequences []string    `json:"stop_sequences,omitempty"`
+	Temperature   float64     `json:"temperature,omitempty"`
+	TopP          *float64    `json:"top_p,omitempty"`
+	TopK          int         `json:"top_k,omitempty"`
+	Tools         []Tool      `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice `json:"tool_choice,omitempty"`
 	//ClaudeMetadata    `json:"metadata,omitempty"`
 	Stream bool `json:"stream,omitempty"`
 }
@@ -64,15 +89,17 @@ type ClaudeResponse struct {
 type Delta struct {
 	Type         string `json:"type,omitempty"`
 	Text         string `json:"text,omitempty"`
+	PartialJson  string `json:"partial_json,omitempty"`
 	StopReason   string `json:"stop_reason,omitempty"`
 	StopSequence string `json:"stop_sequence,omitempty"`
 }
 
 type ClaudeStreamResponse struct {
-	Type    string         `json:"type"`
-	Message ClaudeResponse `json:"message,omitempty"`
-	Index   int            `json:"index,omitempty"`
-	Delta   Delta          `json:"delta,omitempty"`
-	Usage   Usage          `json:"usage,omitempty"`
-	Error   ClaudeError    `json:"error,omitempty"`
+	Type         string         `json:"type"`
+	Message      ClaudeResponse `json:"message,omitempty"`
+	Index        int            `json:"index,omitempty"`
+	ContentBlock ResContent     `json:"content_block,omitempty"`
+	Delta        Delta          `json:"delta,omitempty"`
+	Usage        Usage          `json:"usage,omitempty"`
+	Error        ClaudeError    `json:"error,omitempty"`
 }
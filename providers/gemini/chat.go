@@ -18,6 +18,8 @@ const (
 type geminiStreamHandler struct {
 	Usage   *types.Usage
 	Request *types.ChatCompletionRequest
+	// 已输出的 tool_calls 数量，用于生成流式响应中的 index
+	ToolCallNum int
 }
 
 func (p *GeminiProvider) CreateChatCompletion(request *types.ChatCompletionRequest) (*types.ChatCompletionResponse, *types.OpenAIErrorWithStatusCode) {
@@ -113,15 +115,47 @@ func convertFromChatOpenai(request *types.ChatCompletionRequest) (*GeminiChatReq
 			MaxOutputTokens: request.MaxTokens,
 		},
 	}
-	if request.Functions != nil {
-		geminiRequest.Tools = []GeminiChatTools{
-			{
-				FunctionDeclarations: request.Functions,
-			},
+	if request.Tools != nil {
+		functions := make([]*types.ChatCompletionFunction, 0, len(request.Tools))
+		for _, tool := range request.Tools {
+			functions = append(functions, &tool.Function)
 		}
+		geminiRequest.Tools = convertToolsFromOpenai(functions)
+		geminiRequest.ToolConfig = convertToolChoice(request.ToolChoice)
+	} else if request.Functions != nil {
+		geminiRequest.Tools = convertToolsFromOpenai(request.Functions)
+		geminiRequest.ToolConfig = convertToolChoice(request.FunctionCall)
 	}
+
+	// tool_call_id => 函数名，gemini 的 functionResponse 需要带上函数名
+	toolCallNames := make(map[string]string)
 	shouldAddDummyModelMessage := false
 	for _, message := range request.Messages {
+		if message.Role == types.ChatMessageRoleTool || message.Role == types.ChatMessageRoleFunction {
+			name := toolCallNames[message.ToolCallID]
+			if message.Name != nil && *message.Name != "" {
+				name = *message.Name
+			}
+			part := GeminiPart{
+				FunctionResponse: &GeminiFunctionResponse{
+					Name:     name,
+					Response: convertFunctionResponse(name, message.StringContent()),
+				},
+			}
+
+			// 并行调用的结果需要放在同一条消息中返回
+			lastIndex := len(geminiRequest.Contents) - 1
+			if lastIndex >= 0 && geminiRequest.Contents[lastIndex].Role == types.ChatMessageRoleFunction {
+				geminiRequest.Contents[lastIndex].Parts = append(geminiRequest.Contents[lastIndex].Parts, part)
+			} else {
+				geminiRequest.Contents = append(geminiRequest.Contents, GeminiChatContent{
+					Role:  types.ChatMessageRoleFunction,
+					Parts: []GeminiPart{part},
+				})
+			}
+			continue
+		}
+
 		content := GeminiChatContent{
 			Role: message.Role,
 			Parts: []GeminiPart{
@@ -136,6 +170,10 @@ func convertFromChatOpenai(request *types.ChatCompletionRequest) (*GeminiChatReq
 		imageNum := 0
 		for _, part := range openaiContent {
 			if part.Type == types.ContentTypeText {
+				// 带函数调用的 assistant 消息 content 可能为空
+				if part.Text == "" && (message.ToolCalls != nil || message.FunctionCall != nil) {
+					continue
+				}
 				parts = append(parts, GeminiPart{
 					Text: part.Text,
 				})
@@ -156,6 +194,17 @@ func convertFromChatOpenai(request *types.ChatCompletionRequest) (*GeminiChatReq
 				})
 			}
 		}
+
+		for _, toolCall := range message.ToolCalls {
+			if toolCall.Function == nil {
+				continue
+			}
+			toolCallNames[toolCall.Id] = toolCall.Function.Name
+			parts = append(parts, convertFunctionCallPart(toolCall.Function))
+		}
+		if message.FunctionCall != nil {
+			parts = append(parts, convertFunctionCallPart(message.FunctionCall))
+		}
 		content.Parts = parts
 
 		// there's no assistant role in gemini and API shall vomit if Role is not user or model
@@ -186,6 +235,119 @@ func convertFromChatOpenai(request *types.ChatCompletionRequest) (*GeminiChatReq
 	return &geminiRequest, nil
 }
 
+func convertToolsFromOpenai(functions []*types.ChatCompletionFunction) []GeminiChatTools {
+	declarations := make([]GeminiFunctionDeclaration, 0, len(functions))
+	for _, function := range functions {
+		declaration := GeminiFunctionDeclaration{
+			Name:        function.Name,
+			Description: function.Description,
+		}
+		// gemini 不接受 properties 为空的 object 参数
+		if parameters, ok := function.Parameters.(map[string]any); ok {
+			if properties, ok := parameters["properties"].(map[string]any); ok && len(properties) > 0 {
+				declaration.Parameters = parameters
+			}
+		}
+		declarations = append(declarations, declaration)
+	}
+
+	return []GeminiChatTools{
+		{
+			FunctionDeclarations: declarations,
+		},
+	}
+}
+
+// 将 tool_choice / function_call 转换为 gemini 的 function_calling_config
+func convertToolChoice(toolChoice any) *GeminiToolConfig {
+	config := &GeminiToolConfig{}
+	switch choice := toolChoice.(type) {
+	case string:
+		switch choice {
+		case "none":
+			config.FunctionCallingConfig.Mode = "NONE"
+		case "required":
+			config.FunctionCallingConfig.Mode = "ANY"
+		case "auto":
+			config.FunctionCallingConfig.Mode = "AUTO"
+		default:
+			return nil
+		}
+	case map[string]any:
+		name, _ := choice["name"].(string)
+		if function, ok := choice["function"].(map[string]any); ok {
+			name, _ = function["name"].(string)
+		}
+		if name == "" {
+			return nil
+		}
+		config.FunctionCallingConfig.Mode = "ANY"
+		config.FunctionCallingConfig.AllowedFunctionNames = []string{name}
+	default:
+		return nil
+	}
+
+	return config
+}
+
+func convertFunctionCallPart(function *types.ChatCompletionToolCallsFunction) GeminiPart {
+	args := make(map[string]any)
+	if function.Arguments != "" {
+		_ = json.Unmarshal([]byte(function.Arguments), &args)
+	}
+
+	return GeminiPart{
+		FunctionCall: &GeminiFunctionCall{
+			Name: function.Name,
+			Args: args,
+		},
+	}
+}
+
+// gemini 的 functionResponse.response 必须是一个对象
+func convertFunctionResponse(name, content string) any {
+	response := make(map[string]any)
+	if err := json.Unmarshal([]byte(content), &response); err == nil {
+		return response
+	}
+
+	return map[string]any{
+		"name":    name,
+		"content": content,
+	}
+}
+
+// 将 gemini 返回的 parts 转换为文本及函数调用
+func convertPartsToOpenai(parts []GeminiPart, functionCate string, toolCallIndex int) (content string, toolCalls []*types.ChatCompletionToolCalls, functionCall *types.ChatCompletionToolCallsFunction) {
+	for _, part := range parts {
+		if part.FunctionCall == nil {
+			content += part.Text
+			continue
+		}
+
+		args, _ := json.Marshal(part.FunctionCall.Args)
+		function := &types.ChatCompletionToolCallsFunction{
+			Name:      part.FunctionCall.Name,
+			Arguments: string(args),
+		}
+
+		if functionCate == "function" {
+			functionCall = function
+			continue
+		}
+
+		toolCalls = append(toolCalls, &types.ChatCompletionToolCalls{
+			Id:       fmt.Sprintf("call_%s", common.GetUUID()),
+			Type:     "function",
+			Function: function,
+			Index:    toolCallIndex,
+		})
+		toolCallIndex++
+	}
+
+	return
+}
+
 func (p *GeminiProvider) convertToChatOpenai(response *GeminiChatResponse, request *types.ChatCompletionRequest) (openaiResponse *types.ChatCompletionResponse, errWithCode *types.OpenAIErrorWithStatusCode) {
 	error := errorHandle(&response.GeminiErrorResponse)
 	if error != nil {
@@ -212,8 +374,14 @@ func (p *GeminiProvider) convertToChatOpenai(response *GeminiChatResponse, reque
 			},
 			FinishReason: types.FinishReasonStop,
 		}
-		if len(candidate.Content.Parts) > 0 {
-			choice.Message.Content = candidate.Content.Parts[0].Text
+		content, toolCalls, functionCall := convertPartsToOpenai(candidate.Content.Parts, request.GetFunctionCate(), 0)
+		choice.Message.Content = content
+		if toolCalls != nil {
+			choice.Message.ToolCalls = toolCalls
+			choice.FinishReason = types.FinishReasonToolCalls
+		} else if functionCall != nil {
+			choice.Message.FunctionCall = functionCall
+			choice.FinishReason = types.FinishReasonFunctionCall
 		}
 		openaiResponse.Choices = append(openaiResponse.Choices, choice)
 	}
@@ -259,13 +427,25 @@ func (h *geminiStreamHandler) convertToOpenaiStream(geminiResponse *GeminiChatRe
 	choices := make([]types.ChatCompletionStreamChoice, 0, len(geminiResponse.Candidates))
 
 	for i, candidate := range geminiResponse.Candidates {
+		content, toolCalls, functionCall := convertPartsToOpenai(candidate.Content.Parts, h.Request.GetFunctionCate(), h.ToolCallNum)
 		choice := types.ChatCompletionStreamChoice{
 			Index: i,
 			Delta: types.ChatCompletionStreamChoiceDelta{
-				Content: candidate.Content.Parts[0].Text,
+				Content: content,
 			},
 			FinishReason: types.FinishReasonStop,
 		}
+		// gemini 的函数调用不会分片返回，每个调用直接输出完整参数
+		if toolCalls != nil {
+			h.ToolCallNum += len(toolCalls)
+			choice.Delta.Role = types.ChatMessageRoleAssistant
+			choice.Delta.ToolCalls = toolCalls
+			choice.FinishReason = types.FinishReasonToolCalls
+		} else if functionCall != nil {
+			choice.Delta.Role = types.ChatMessageRoleAssistant
+			choice.Delta.FunctionCall = functionCall
+			choice.FinishReason = types.FinishReasonFunctionCall
+		}
 		choices = append(choices, choice)
 	}
 
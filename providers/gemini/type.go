@@ -1,12 +1,16 @@
 package gemini
 
-import "one-api/types"
+import (
+	"encoding/json"
+	"one-api/types"
+)
 
 type GeminiChatRequest struct {
 	Contents         []GeminiChatContent        `json:"contents"`
 	SafetySettings   []GeminiChatSafetySettings `json:"safety_settings,omitempty"`
 	GenerationConfig GeminiChatGenerationConfig `json:"generation_config,omitempty"`
 	Tools            []GeminiChatTools          `json:"tools,omitempty"`
+	ToolConfig       *GeminiToolConfig          `json:"tool_config,omitempty"`
 }
 
 type GeminiInlineData struct {
@@ -14,9 +18,21 @@ type GeminiInlineData struct {
 	Data     string `json:"data"`
 }
 
+type GeminiFunctionCall struct {
+	Name string `json:"name"`
+	Args any    `json:"args"`
+}
+
+type GeminiFunctionResponse struct {
+	Name     string `json:"name"`
+	Response any    `json:"response"`
+}
+
 type GeminiPart struct {
-	Text       string            `json:"text,omitempty"`
-	InlineData *GeminiInlineData `json:"inlineData,omitempty"`
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *GeminiInlineData       `json:"inlineData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
 }
 
 type GeminiChatContent struct {
@@ -30,7 +46,22 @@ type GeminiChatSafetySettings struct {
 }
 
 type GeminiChatTools struct {
-	FunctionDeclarations any `json:"functionDeclarations,omitempty"`
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations,omitempty"`
+}
+
+type GeminiFunctionDeclaration struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+type GeminiToolConfig struct {
+	FunctionCallingConfig GeminiFunctionCallingConfig `json:"functionCallingConfig"`
 }
 
 type GeminiChatGenerationConfig struct {
@@ -80,8 +111,15 @@ func (g *GeminiChatResponse) GetResponseText() string {
 	if g == nil {
 		return ""
 	}
-	if len(g.Candidates) > 0 && len(g.Candidates[0].Content.Parts) > 0 {
-		return g.Candidates[0].Content.Parts[0].Text
+	var text string
+	if len(g.Candidates) > 0 {
+		for _, part := range g.Candidates[0].Content.Parts {
+			text += part.Text
+			if part.FunctionCall != nil {
+				args, _ := json.Marshal(part.FunctionCall.Args)
+				text += part.FunctionCall.Name + string(args)
+			}
+		}
 	}
-	return ""
+	return text
 }
@@ -0,0 +1,35 @@
+package openai
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/types"
+)
+
+const assistantsBetaVersion = "assistants=v1"
+
+// 转发 Assistants/Threads/Runs 请求，uri 为完整的请求路径（含查询参数）
+func (p *OpenAIProvider) SendAssistantsRequest(method, uri string, body any) (*http.Response, *types.OpenAIErrorWithStatusCode) {
+	if p.IsAzure {
+		return nil, common.StringErrorWrapper("The API interface is not supported", "unsupported_api", http.StatusNotImplemented)
+	}
+
+	// 获取请求地址
+	fullRequestURL := p.GetFullRequestURL(uri, "")
+
+	// 获取请求头
+	headers := p.GetRequestHeaders()
+	headers["OpenAI-Beta"] = p.Context.Request.Header.Get("OpenAI-Beta")
+	if headers["OpenAI-Beta"] == "" {
+		headers["OpenAI-Beta"] = assistantsBetaVersion
+	}
+
+	// 创建请求
+	req, err := p.Requester.NewRequest(method, fullRequestURL, p.Requester.WithBody(body), p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	// 发送请求
+	return p.Requester.SendRequestRaw(req)
+}
@@ -57,6 +57,8 @@ func getOpenAIConfig(baseURL string) base.ProviderConfig {
 		ImagesGenerations:   "/v1/images/generations",
 		ImagesEdit:          "/v1/images/edits",
 		ImagesVariations:    "/v1/images/variations",
+		Files:               "/v1/files",
+		FineTuningJobs:      "/v1/fine_tuning/jobs",
 	}
 }
 
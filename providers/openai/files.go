@@ -0,0 +1,168 @@
+package openai
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/common/requester"
+	"one-api/types"
+)
+
+func (p *OpenAIProvider) CreateFile(request *types.FileRequest) (*types.File, *types.OpenAIErrorWithStatusCode) {
+	fullRequestURL, errWithCode := p.getFilesRequestURL("")
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	var formBody bytes.Buffer
+	builder := p.Requester.CreateFormBuilder(&formBody)
+	if err := filesMultipartForm(request, builder); err != nil {
+		return nil, common.ErrorWrapper(err, "create_form_builder_failed", http.StatusInternalServerError)
+	}
+
+	// 获取请求头
+	headers := p.GetRequestHeaders()
+	// 创建请求
+	req, err := p.Requester.NewRequest(
+		http.MethodPost,
+		fullRequestURL,
+		p.Requester.WithBody(&formBody),
+		p.Requester.WithHeader(headers),
+		p.Requester.WithContentType(builder.FormDataContentType()))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	req.ContentLength = int64(formBody.Len())
+
+	response := &OpenAIProviderFileResponse{}
+	errWithCode = p.sendJSONRequest(req, response, &response.OpenAIErrorResponse)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return &response.File, nil
+}
+
+func (p *OpenAIProvider) ListFiles(purpose string) (*types.FilesList, *types.OpenAIErrorWithStatusCode) {
+	uri := ""
+	if purpose != "" {
+		uri = "?purpose=" + url.QueryEscape(purpose)
+	}
+
+	req, errWithCode := p.getFilesRequest(http.MethodGet, uri)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	response := &OpenAIProviderFilesListResponse{}
+	errWithCode = p.sendJSONRequest(req, response, &response.OpenAIErrorResponse)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return &response.FilesList, nil
+}
+
+func (p *OpenAIProvider) RetrieveFile(fileId string) (*types.File, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getFilesRequest(http.MethodGet, "/"+fileId)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	response := &OpenAIProviderFileResponse{}
+	errWithCode = p.sendJSONRequest(req, response, &response.OpenAIErrorResponse)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return &response.File, nil
+}
+
+func (p *OpenAIProvider) DeleteFile(fileId string) (*types.FileDeleteResponse, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getFilesRequest(http.MethodDelete, "/"+fileId)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	response := &OpenAIProviderFileDeleteResponse{}
+	errWithCode = p.sendJSONRequest(req, response, &response.OpenAIErrorResponse)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return &response.FileDeleteResponse, nil
+}
+
+func (p *OpenAIProvider) RetrieveFileContent(fileId string) (*http.Response, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getFilesRequest(http.MethodGet, "/"+fileId+"/content")
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	// 发送请求
+	return p.Requester.SendRequestRaw(req)
+}
+
+func (p *OpenAIProvider) getFilesRequestURL(uri string) (string, *types.OpenAIErrorWithStatusCode) {
+	if p.Config.Files == "" || p.IsAzure {
+		return "", common.StringErrorWrapper("The API interface is not supported", "unsupported_api", http.StatusNotImplemented)
+	}
+
+	return p.GetFullRequestURL(p.Config.Files+uri, ""), nil
+}
+
+func (p *OpenAIProvider) getFilesRequest(method, uri string) (*http.Request, *types.OpenAIErrorWithStatusCode) {
+	fullRequestURL, errWithCode := p.getFilesRequestURL(uri)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	// 获取请求头
+	headers := p.GetRequestHeaders()
+	// 创建请求
+	req, err := p.Requester.NewRequest(method, fullRequestURL, p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	return req, nil
+}
+
+func (p *OpenAIProvider) sendJSONRequest(req *http.Request, response any, errorResponse *types.OpenAIErrorResponse) *types.OpenAIErrorWithStatusCode {
+	// 发送请求
+	_, errWithCode := p.Requester.SendRequest(req, response, false)
+	if errWithCode != nil {
+		return errWithCode
+	}
+
+	if errorResponse == nil {
+		return nil
+	}
+
+	// 检测是否错误
+	openaiErr := ErrorHandle(errorResponse)
+	if openaiErr != nil {
+		return &types.OpenAIErrorWithStatusCode{
+			OpenAIError: *openaiErr,
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+
+	return nil
+}
+
+func filesMultipartForm(request *types.FileRequest, b requester.FormBuilder) error {
+	err := b.CreateFormFile("file", request.File)
+	if err != nil {
+		return fmt.Errorf("creating form file: %w", err)
+	}
+
+	err = b.WriteField("purpose", request.Purpose)
+	if err != nil {
+		return fmt.Errorf("writing purpose: %w", err)
+	}
+
+	return b.Close()
+}
@@ -0,0 +1,126 @@
+package openai
+
+import (
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/types"
+	"strconv"
+)
+
+func (p *OpenAIProvider) CreateFineTuningJob(request *types.FineTuningJobRequest) (*types.FineTuningJob, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getFineTuningRequest(http.MethodPost, "", request)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+	defer req.Body.Close()
+
+	// 任务对象自带 error 字段，上游出错时依靠状态码判断
+	response := &types.FineTuningJob{}
+	errWithCode = p.sendJSONRequest(req, response, nil)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return response, nil
+}
+
+func (p *OpenAIProvider) ListFineTuningJobs(params *types.FineTuningListParams) (*types.FineTuningJobList, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getFineTuningRequest(http.MethodGet, fineTuningListQuery(params), nil)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	response := &OpenAIProviderFineTuningJobListResponse{}
+	errWithCode = p.sendJSONRequest(req, response, &response.OpenAIErrorResponse)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return &response.FineTuningJobList, nil
+}
+
+func (p *OpenAIProvider) RetrieveFineTuningJob(jobId string) (*types.FineTuningJob, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getFineTuningRequest(http.MethodGet, "/"+jobId, nil)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	// 任务对象自带 error 字段，上游出错时依靠状态码判断
+	response := &types.FineTuningJob{}
+	errWithCode = p.sendJSONRequest(req, response, nil)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return response, nil
+}
+
+func (p *OpenAIProvider) CancelFineTuningJob(jobId string) (*types.FineTuningJob, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getFineTuningRequest(http.MethodPost, "/"+jobId+"/cancel", nil)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	// 任务对象自带 error 字段，上游出错时依靠状态码判断
+	response := &types.FineTuningJob{}
+	errWithCode = p.sendJSONRequest(req, response, nil)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return response, nil
+}
+
+func (p *OpenAIProvider) ListFineTuningJobEvents(jobId string, params *types.FineTuningListParams) (*types.FineTuningJobEventList, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getFineTuningRequest(http.MethodGet, "/"+jobId+"/events"+fineTuningListQuery(params), nil)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	response := &OpenAIProviderFineTuningJobEventListResponse{}
+	errWithCode = p.sendJSONRequest(req, response, &response.OpenAIErrorResponse)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return &response.FineTuningJobEventList, nil
+}
+
+func (p *OpenAIProvider) getFineTuningRequest(method, uri string, body any) (*http.Request, *types.OpenAIErrorWithStatusCode) {
+	if p.Config.FineTuningJobs == "" || p.IsAzure {
+		return nil, common.StringErrorWrapper("The API interface is not supported", "unsupported_api", http.StatusNotImplemented)
+	}
+	// 获取请求地址
+	fullRequestURL := p.GetFullRequestURL(p.Config.FineTuningJobs+uri, "")
+
+	// 获取请求头
+	headers := p.GetRequestHeaders()
+	headers["Content-Type"] = "application/json"
+	// 创建请求
+	req, err := p.Requester.NewRequest(method, fullRequestURL, p.Requester.WithBody(body), p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	return req, nil
+}
+
+func fineTuningListQuery(params *types.FineTuningListParams) string {
+	if params == nil {
+		return ""
+	}
+
+	query := url.Values{}
+	if params.After != "" {
+		query.Set("after", params.After)
+	}
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if len(query) == 0 {
+		return ""
+	}
+
+	return "?" + query.Encode()
+}
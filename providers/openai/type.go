@@ -73,3 +73,28 @@ type OpenAIUsageResponse struct {
 	//DailyCosts []OpenAIUsageDailyCost `json:"daily_costs"`
 	TotalUsage float64 `json:"total_usage"` // unit: 0.01 dollar
 }
+
+type OpenAIProviderFileResponse struct {
+	types.File
+	types.OpenAIErrorResponse
+}
+
+type OpenAIProviderFilesListResponse struct {
+	types.FilesList
+	types.OpenAIErrorResponse
+}
+
+type OpenAIProviderFileDeleteResponse struct {
+	types.FileDeleteResponse
+	types.OpenAIErrorResponse
+}
+
+type OpenAIProviderFineTuningJobListResponse struct {
+	types.FineTuningJobList
+	types.OpenAIErrorResponse
+}
+
+type OpenAIProviderFineTuningJobEventListResponse struct {
+	types.FineTuningJobEventList
+	types.OpenAIErrorResponse
+}
@@ -63,6 +63,11 @@ func init() {
 
 // 获取供应商
 func GetProvider(channel *model.Channel, c *gin.Context) base.ProviderInterface {
+	// 多 key 渠道每次请求选择一个 key
+	channel = channel.SelectKey()
+	if channel == nil {
+		return nil
+	}
 	factory, ok := providerFactories[channel.Type]
 	var provider base.ProviderInterface
 	if !ok {
@@ -13,6 +13,8 @@ import (
 	"one-api/types"
 	"strings"
 	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
 type XunfeiProviderFactory struct{}
@@ -37,6 +39,11 @@ type XunfeiProvider struct {
 	wsRequester *requester.WSRequester
 }
 
+func (p *XunfeiProvider) SetContext(c *gin.Context) {
+	p.BaseProvider.SetContext(c)
+	p.wsRequester.SetContext(c.Request.Context())
+}
+
 func getConfig() base.ProviderConfig {
 	return base.ProviderConfig{
 		BaseURL:         "wss://spark-api.xf-yun.com",
@@ -3,6 +3,7 @@ package router
 import (
 	"one-api/controller"
 	"one-api/middleware"
+	"one-api/payment"
 
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
@@ -12,6 +13,7 @@ func SetApiRouter(router *gin.Engine) {
 	apiRouter := router.Group("/api")
 	apiRouter.Use(gzip.Gzip(gzip.DefaultCompression))
 	apiRouter.POST("/telegram/:token", middleware.Telegram(), controller.TelegramBotWebHook)
+	payment.SetRouter(apiRouter.Group("/payment"))
 	apiRouter.Use(middleware.GlobalAPIRateLimit())
 	{
 		apiRouter.GET("/status", controller.GetStatus)
@@ -33,6 +35,7 @@ func SetApiRouter(router *gin.Engine) {
 			userRoute.POST("/login", middleware.CriticalRateLimit(), controller.Login)
 			userRoute.GET("/logout", controller.Logout)
 			userRoute.GET("/rechargenotify", controller.RechargeNotify)
+			userRoute.POST("/rechargenotify", controller.RechargeNotify)
 
 			selfRoute := userRoute.Group("/")
 			selfRoute.Use(middleware.UserAuth())
@@ -40,11 +43,17 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/dashboard", controller.GetUserDashboard)
 				selfRoute.GET("/self", controller.GetSelf)
 				selfRoute.PUT("/self", controller.UpdateSelf)
+				selfRoute.PUT("/notify", controller.UpdateSelfNotify)
 				selfRoute.DELETE("/self", controller.DeleteSelf)
 				selfRoute.GET("/token", controller.GenerateAccessToken)
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.POST("/topup", controller.TopUp)
 				selfRoute.POST("/recharge", controller.Recharge)
+				selfRoute.GET("/payment", controller.GetPaymentGateways)
+				selfRoute.GET("/plan", controller.GetEnabledPlans)
+				selfRoute.GET("/subscription", controller.GetSelfSubscription)
+				selfRoute.GET("/subscription/quote", controller.GetSubscriptionQuote)
+				selfRoute.POST("/subscription", controller.Subscribe)
 				selfRoute.GET("/models", controller.ListModels)
 			}
 
@@ -85,6 +94,7 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.PUT("/", controller.UpdateChannel)
 			channelRoute.PUT("/batch/azure_api", controller.BatchUpdateChannelsAzureApi)
 			channelRoute.PUT("/batch/del_model", controller.BatchDelModelChannels)
+			channelRoute.PUT("/key/:id/status", controller.UpdateChannelKeyStatus)
 			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
 			channelRoute.DELETE("/:id", controller.DeleteChannel)
 		}
@@ -101,11 +111,31 @@ func SetApiRouter(router *gin.Engine) {
 		redemptionRoute.Use(middleware.AdminAuth())
 		{
 			redemptionRoute.GET("/", controller.GetRedemptionsList)
+			redemptionRoute.GET("/campaign", controller.GetRedemptionCampaignsList)
+			redemptionRoute.GET("/campaign/:id", controller.GetRedemptionCampaign)
+			redemptionRoute.GET("/campaign/:id/export", controller.ExportRedemptionCampaign)
+			redemptionRoute.POST("/campaign", controller.AddRedemptionCampaign)
+			redemptionRoute.POST("/campaign/:id/batch", controller.AddRedemptionCampaignBatch)
+			redemptionRoute.PUT("/campaign", controller.UpdateRedemptionCampaign)
 			redemptionRoute.GET("/:id", controller.GetRedemption)
 			redemptionRoute.POST("/", controller.AddRedemption)
 			redemptionRoute.PUT("/", controller.UpdateRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
+		rechargeRoute := apiRouter.Group("/recharge")
+		rechargeRoute.GET("/", middleware.AdminAuth(), controller.GetRechargeLogsList)
+		rechargeRoute.POST("/:id/refund", middleware.AdminAuth(), controller.RefundRechargeLog)
+		rechargeRoute.GET("/self", middleware.UserAuth(), controller.GetUserRechargeLogsList)
+		planRoute := apiRouter.Group("/plan")
+		planRoute.Use(middleware.AdminAuth())
+		{
+			planRoute.GET("/", controller.GetPlansList)
+			planRoute.GET("/:id", controller.GetPlan)
+			planRoute.POST("/", controller.AddPlan)
+			planRoute.PUT("/", controller.UpdatePlan)
+		}
+		subscriptionRoute := apiRouter.Group("/subscription")
+		subscriptionRoute.GET("/", middleware.AdminAuth(), controller.GetSubscriptionsList)
 		logRoute := apiRouter.Group("/log")
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetLogsList)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
@@ -129,6 +159,7 @@ func SetApiRouter(router *gin.Engine) {
 			analyticsRoute.GET("/users_period", controller.GetUserStatisticsByPeriod)
 			analyticsRoute.GET("/channel_period", controller.GetChannelExpensesByPeriod)
 			analyticsRoute.GET("/redemption_period", controller.GetRedemptionStatisticsByPeriod)
+			analyticsRoute.GET("/concurrency", controller.GetConcurrencyStatistics)
 		}
 	}
 
@@ -0,0 +1,29 @@
+package router
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/metrics"
+	"one-api/middleware"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 设置了 METRICS_ADDR 时在单独的地址上暴露指标（由网络隔离保护），否则挂载在主服务上且仅限超级管理员访问
+func SetMetricsRouter(router *gin.Engine) {
+	metricsAddr := os.Getenv("METRICS_ADDR")
+	if metricsAddr == "" {
+		router.GET("/metrics", middleware.RootAuth(), gin.WrapH(metrics.Handler()))
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	go func() {
+		common.SysLog("metrics server listening on " + metricsAddr)
+		if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+			common.SysError("failed to start metrics server: " + err.Error())
+		}
+	}()
+}
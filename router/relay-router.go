@@ -18,10 +18,11 @@ func SetRelayRouter(router *gin.Engine) {
 		modelsRouter.GET("/:model", controller.RetrieveModel)
 	}
 	relayV1Router := router.Group("/v1")
-	relayV1Router.Use(middleware.RelayPanicRecover(), middleware.TokenAuth(), middleware.Distribute())
+	relayV1Router.Use(middleware.RelayPanicRecover(), middleware.TokenAuth(), middleware.Distribute(), middleware.ConcurrencyLimit())
 	{
 		relayV1Router.POST("/completions", relay.Relay)
 		relayV1Router.POST("/chat/completions", relay.Relay)
+		relayV1Router.POST("/messages", relay.Relay)
 		// relayV1Router.POST("/edits", controller.Relay)
 		relayV1Router.POST("/images/generations", relay.Relay)
 		relayV1Router.POST("/images/edits", relay.Relay)
@@ -32,42 +33,50 @@ func SetRelayRouter(router *gin.Engine) {
 		relayV1Router.POST("/audio/translations", relay.Relay)
 		relayV1Router.POST("/audio/speech", relay.Relay)
 		relayV1Router.POST("/moderations", relay.Relay)
-		relayV1Router.GET("/files", controller.RelayNotImplemented)
-		relayV1Router.POST("/files", controller.RelayNotImplemented)
-		relayV1Router.DELETE("/files/:id", controller.RelayNotImplemented)
-		relayV1Router.GET("/files/:id", controller.RelayNotImplemented)
-		relayV1Router.GET("/files/:id/content", controller.RelayNotImplemented)
-		relayV1Router.POST("/fine_tuning/jobs", controller.RelayNotImplemented)
-		relayV1Router.GET("/fine_tuning/jobs", controller.RelayNotImplemented)
-		relayV1Router.GET("/fine_tuning/jobs/:id", controller.RelayNotImplemented)
-		relayV1Router.POST("/fine_tuning/jobs/:id/cancel", controller.RelayNotImplemented)
-		relayV1Router.GET("/fine_tuning/jobs/:id/events", controller.RelayNotImplemented)
+		relayV1Router.GET("/files", relay.ListFiles)
+		relayV1Router.POST("/files", relay.UploadFile)
+		relayV1Router.DELETE("/files/:id", relay.DeleteFile)
+		relayV1Router.GET("/files/:id", relay.RetrieveFile)
+		relayV1Router.GET("/files/:id/content", relay.RetrieveFileContent)
+		relayV1Router.POST("/fine_tuning/jobs", relay.CreateFineTuningJob)
+		relayV1Router.GET("/fine_tuning/jobs", relay.ListFineTuningJobs)
+		relayV1Router.GET("/fine_tuning/jobs/:id", relay.RetrieveFineTuningJob)
+		relayV1Router.POST("/fine_tuning/jobs/:id/cancel", relay.CancelFineTuningJob)
+		relayV1Router.GET("/fine_tuning/jobs/:id/events", relay.ListFineTuningJobEvents)
 		relayV1Router.DELETE("/models/:model", controller.RelayNotImplemented)
-		relayV1Router.POST("/assistants", controller.RelayNotImplemented)
-		relayV1Router.GET("/assistants/:id", controller.RelayNotImplemented)
-		relayV1Router.POST("/assistants/:id", controller.RelayNotImplemented)
-		relayV1Router.DELETE("/assistants/:id", controller.RelayNotImplemented)
-		relayV1Router.GET("/assistants", controller.RelayNotImplemented)
-		relayV1Router.POST("/assistants/:id/files", controller.RelayNotImplemented)
-		relayV1Router.GET("/assistants/:id/files/:fileId", controller.RelayNotImplemented)
-		relayV1Router.DELETE("/assistants/:id/files/:fileId", controller.RelayNotImplemented)
-		relayV1Router.GET("/assistants/:id/files", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id", controller.RelayNotImplemented)
-		relayV1Router.DELETE("/threads/:id", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id/messages", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/messages/:messageId", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id/messages/:messageId", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/messages/:messageId/files/:filesId", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/messages/:messageId/files", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id/runs", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/runs/:runsId", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id/runs/:runsId", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/runs", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id/runs/:runsId/submit_tool_outputs", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id/runs/:runsId/cancel", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/runs/:runsId/steps/:stepId", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/runs/:runsId/steps", controller.RelayNotImplemented)
+		relayV1Router.POST("/assistants", relay.RelayAssistants)
+		relayV1Router.GET("/assistants/:id", relay.RelayAssistants)
+		relayV1Router.POST("/assistants/:id", relay.RelayAssistants)
+		relayV1Router.DELETE("/assistants/:id", relay.RelayAssistants)
+		relayV1Router.GET("/assistants", relay.RelayAssistants)
+		relayV1Router.POST("/assistants/:id/files", relay.RelayAssistants)
+		relayV1Router.GET("/assistants/:id/files/:fileId", relay.RelayAssistants)
+		relayV1Router.DELETE("/assistants/:id/files/:fileId", relay.RelayAssistants)
+		relayV1Router.GET("/assistants/:id/files", relay.RelayAssistants)
+		relayV1Router.POST("/threads", relay.RelayAssistants)
+		relayV1Router.POST("/threads/runs", relay.RelayAssistants)
+		relayV1Router.GET("/threads/:id", relay.RelayAssistants)
+		relayV1Router.POST("/threads/:id", relay.RelayAssistants)
+		relayV1Router.DELETE("/threads/:id", relay.RelayAssistants)
+		relayV1Router.POST("/threads/:id/messages", relay.RelayAssistants)
+		relayV1Router.GET("/threads/:id/messages/:messageId", relay.RelayAssistants)
+		relayV1Router.POST("/threads/:id/messages/:messageId", relay.RelayAssistants)
+		relayV1Router.GET("/threads/:id/messages/:messageId/files/:filesId", relay.RelayAssistants)
+		relayV1Router.GET("/threads/:id/messages/:messageId/files", relay.RelayAssistants)
+		relayV1Router.POST("/threads/:id/runs", relay.RelayAssistants)
+		relayV1Router.GET("/threads/:id/runs/:runsId", relay.RelayAssistants)
+		relayV1Router.POST("/threads/:id/runs/:runsId", relay.RelayAssistants)
+		relayV1Router.GET("/threads/:id/runs", relay.RelayAssistants)
+		relayV1Router.POST("/threads/:id/runs/:runsId/submit_tool_outputs", relay.RelayAssistants)
+		relayV1Router.POST("/threads/:id/runs/:runsId/cancel", relay.RelayAssistants)
+		relayV1Router.GET("/threads/:id/runs/:runsId/steps/:stepId", relay.RelayAssistants)
+		relayV1Router.GET("/threads/:id/runs/:runsId/steps", relay.RelayAssistants)
+	}
+
+	// https://ai.google.dev/api/rest/v1beta/models/generateContent
+	relayGeminiRouter := router.Group("/v1beta")
+	relayGeminiRouter.Use(middleware.RelayPanicRecover(), middleware.GeminiAuth(), middleware.Distribute(), middleware.ConcurrencyLimit())
+	{
+		relayGeminiRouter.POST("/models/:model", relay.Relay)
 	}
 }
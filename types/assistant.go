@@ -25,6 +25,7 @@ type AssistantRequest struct {
 
 // AssistantsList is a list of assistants.
 type AssistantsList struct {
+	Object     string      `json:"object"`
 	Assistants []Assistant `json:"data"`
 	LastID     *string     `json:"last_id"`
 	FirstID    *string     `json:"first_id"`
@@ -51,3 +52,83 @@ type AssistantFileRequest struct {
 type AssistantFilesList struct {
 	AssistantFiles []AssistantFile `json:"data"`
 }
+
+type Thread struct {
+	ID        string         `json:"id"`
+	Object    string         `json:"object"`
+	CreatedAt int64          `json:"created_at"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+// ThreadRequest 创建 thread 的请求，只解析用于选择渠道的字段，请求体原样转发
+type ThreadRequest struct {
+	AssistantID   string                 `json:"assistant_id,omitempty"` // 非 OpenAI 参数，在该 assistant 所在的渠道创建，转发前移除
+	Messages      []ThreadMessageRequest `json:"messages,omitempty"`
+	ToolResources *ToolResources         `json:"tool_resources,omitempty"`
+}
+
+type ThreadMessageRequest struct {
+	FileIDs     []string            `json:"file_ids,omitempty"`
+	Attachments []MessageAttachment `json:"attachments,omitempty"`
+}
+
+type MessageAttachment struct {
+	FileID string `json:"file_id"`
+}
+
+type ToolResources struct {
+	CodeInterpreter *struct {
+		FileIDs []string `json:"file_ids,omitempty"`
+	} `json:"code_interpreter,omitempty"`
+}
+
+// GetFileIDs 请求中引用的所有文件
+func (r *ThreadRequest) GetFileIDs() []string {
+	fileIDs := make([]string, 0)
+	if r.ToolResources != nil && r.ToolResources.CodeInterpreter != nil {
+		fileIDs = append(fileIDs, r.ToolResources.CodeInterpreter.FileIDs...)
+	}
+	for _, message := range r.Messages {
+		fileIDs = append(fileIDs, message.FileIDs...)
+		for _, attachment := range message.Attachments {
+			fileIDs = append(fileIDs, attachment.FileID)
+		}
+	}
+	return fileIDs
+}
+
+type ThreadDeleteResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
+
+type Run struct {
+	ID           string         `json:"id"`
+	Object       string         `json:"object"`
+	CreatedAt    int64          `json:"created_at"`
+	ThreadID     string         `json:"thread_id"`
+	AssistantID  string         `json:"assistant_id"`
+	Status       string         `json:"status"`
+	Model        string         `json:"model"`
+	Instructions string         `json:"instructions,omitempty"`
+	Tools        any            `json:"tools,omitempty"`
+	FileIDs      []string       `json:"file_ids,omitempty"`
+	Metadata     map[string]any `json:"metadata,omitempty"`
+	Usage        *Usage         `json:"usage,omitempty"`
+}
+
+type RunRequest struct {
+	AssistantID  string         `json:"assistant_id"`
+	Model        *string        `json:"model,omitempty"`
+	Instructions *string        `json:"instructions,omitempty"`
+	Tools        any            `json:"tools,omitempty"`
+	Metadata     map[string]any `json:"metadata,omitempty"`
+}
+
+type RunList struct {
+	Runs    []Run   `json:"data"`
+	LastID  *string `json:"last_id"`
+	FirstID *string `json:"first_id"`
+	HasMore bool    `json:"has_more"`
+}
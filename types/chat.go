@@ -28,8 +28,8 @@ type ChatCompletionToolCallsFunction struct {
 }
 
 type ChatCompletionToolCalls struct {
-	Id       string                           `json:"id"`
-	Type     string                           `json:"type"`
+	Id       string                           `json:"id,omitempty"`
+	Type     string                           `json:"type,omitempty"`
 	Function *ChatCompletionToolCallsFunction `json:"function"`
 	Index    int                              `json:"index"`
 }
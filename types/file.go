@@ -0,0 +1,32 @@
+package types
+
+import "mime/multipart"
+
+type FileRequest struct {
+	File    *multipart.FileHeader `form:"file" binding:"required"`
+	Purpose string                `form:"purpose" binding:"required"`
+	// 非 OpenAI 参数，assistants 用途的文件上传到该 assistant 所在的渠道，不转发给上游
+	AssistantID string `form:"assistant_id"`
+}
+
+type File struct {
+	ID            string `json:"id"`
+	Object        string `json:"object"`
+	Bytes         int64  `json:"bytes"`
+	CreatedAt     int64  `json:"created_at"`
+	Filename      string `json:"filename"`
+	Purpose       string `json:"purpose"`
+	Status        string `json:"status,omitempty"`
+	StatusDetails any    `json:"status_details,omitempty"`
+}
+
+type FilesList struct {
+	Object string `json:"object"`
+	Files  []File `json:"data"`
+}
+
+type FileDeleteResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
@@ -0,0 +1,58 @@
+package types
+
+type FineTuningJobRequest struct {
+	Model           string  `json:"model" binding:"required"`
+	TrainingFile    string  `json:"training_file" binding:"required"`
+	ValidationFile  *string `json:"validation_file,omitempty"`
+	Hyperparameters any     `json:"hyperparameters,omitempty"`
+	Suffix          *string `json:"suffix,omitempty"`
+}
+
+type FineTuningJob struct {
+	ID              string   `json:"id"`
+	Object          string   `json:"object"`
+	CreatedAt       int64    `json:"created_at"`
+	FinishedAt      *int64   `json:"finished_at"`
+	Model           string   `json:"model"`
+	FineTunedModel  *string  `json:"fine_tuned_model"`
+	OrganizationID  string   `json:"organization_id"`
+	Status          string   `json:"status"`
+	Hyperparameters any      `json:"hyperparameters,omitempty"`
+	TrainingFile    string   `json:"training_file"`
+	ValidationFile  *string  `json:"validation_file"`
+	ResultFiles     []string `json:"result_files"`
+	TrainedTokens   *int     `json:"trained_tokens"`
+	Error           any      `json:"error,omitempty"`
+}
+
+// 是否已经结束
+func (j *FineTuningJob) IsFinished() bool {
+	return j.Status == "succeeded" || j.Status == "failed" || j.Status == "cancelled"
+}
+
+type FineTuningJobList struct {
+	Object  string          `json:"object"`
+	Data    []FineTuningJob `json:"data"`
+	HasMore bool            `json:"has_more"`
+}
+
+type FineTuningJobEvent struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	CreatedAt int64  `json:"created_at"`
+	Level     string `json:"level"`
+	Message   string `json:"message"`
+	Data      any    `json:"data,omitempty"`
+	Type      string `json:"type,omitempty"`
+}
+
+type FineTuningJobEventList struct {
+	Object  string               `json:"object"`
+	Data    []FineTuningJobEvent `json:"data"`
+	HasMore bool                 `json:"has_more"`
+}
+
+type FineTuningListParams struct {
+	After string `form:"after"`
+	Limit int    `form:"limit"`
+}
@@ -0,0 +1,168 @@
+package types
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Anthropic Messages API 兼容的请求/响应格式
+
+const (
+	MessagesContentTypeText       = "text"
+	MessagesContentTypeImage      = "image"
+	MessagesContentTypeToolUse    = "tool_use"
+	MessagesContentTypeToolResult = "tool_result"
+)
+
+const (
+	MessagesStopReasonEndTurn      = "end_turn"
+	MessagesStopReasonMaxTokens    = "max_tokens"
+	MessagesStopReasonStopSequence = "stop_sequence"
+	MessagesStopReasonToolUse      = "tool_use"
+)
+
+type MessagesContentSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type MessagesContent struct {
+	Type      string                 `json:"type"`
+	Text      string                 `json:"text,omitempty"`
+	Source    *MessagesContentSource `json:"source,omitempty"`
+	Id        string                 `json:"id,omitempty"`
+	Name      string                 `json:"name,omitempty"`
+	Input     json.RawMessage        `json:"input,omitempty"`
+	ToolUseId string                 `json:"tool_use_id,omitempty"`
+	Content   any                    `json:"content,omitempty"`
+	IsError   bool                   `json:"is_error,omitempty"`
+}
+
+type MessagesMessage struct {
+	Role    string `json:"role" binding:"required"`
+	Content any    `json:"content" binding:"required"`
+}
+
+// ParseContent content 可以是字符串或者内容块数组
+func (m MessagesMessage) ParseContent() ([]MessagesContent, error) {
+	if content, ok := m.Content.(string); ok {
+		return []MessagesContent{{Type: MessagesContentTypeText, Text: content}}, nil
+	}
+
+	data, err := json.Marshal(m.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents []MessagesContent
+	err = json.Unmarshal(data, &contents)
+	return contents, err
+}
+
+type MessagesTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+type MessagesToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type MessagesMetadata struct {
+	UserId string `json:"user_id,omitempty"`
+}
+
+type MessagesRequest struct {
+	Model         string              `json:"model" binding:"required"`
+	Messages      []MessagesMessage   `json:"messages" binding:"required"`
+	System        any                 `json:"system,omitempty"`
+	MaxTokens     int                 `json:"max_tokens"`
+	StopSequences []string            `json:"stop_sequences,omitempty"`
+	Temperature   float64             `json:"temperature,omitempty"`
+	TopP          float64             `json:"top_p,omitempty"`
+	TopK          int                 `json:"top_k,omitempty"`
+	Stream        bool                `json:"stream,omitempty"`
+	Tools         []MessagesTool      `json:"tools,omitempty"`
+	ToolChoice    *MessagesToolChoice `json:"tool_choice,omitempty"`
+	Metadata      *MessagesMetadata   `json:"metadata,omitempty"`
+}
+
+// GetSystem system 可以是字符串或者文本块数组
+func (r MessagesRequest) GetSystem() string {
+	if system, ok := r.System.(string); ok {
+		return system
+	}
+
+	message := MessagesMessage{Content: r.System}
+	contents, _ := message.ParseContent()
+	var system string
+	for _, content := range contents {
+		system += content.Text
+	}
+	return system
+}
+
+type MessagesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type MessagesResponse struct {
+	Id           string            `json:"id"`
+	Type         string            `json:"type"`
+	Role         string            `json:"role"`
+	Content      []MessagesContent `json:"content"`
+	Model        string            `json:"model"`
+	StopReason   *string           `json:"stop_reason"`
+	StopSequence *string           `json:"stop_sequence"`
+	Usage        MessagesUsage     `json:"usage"`
+}
+
+type MessagesStreamDelta struct {
+	Type         string  `json:"type,omitempty"`
+	Text         string  `json:"text,omitempty"`
+	PartialJson  string  `json:"partial_json,omitempty"`
+	StopReason   *string `json:"stop_reason,omitempty"`
+	StopSequence *string `json:"stop_sequence,omitempty"`
+}
+
+type MessagesStreamEvent struct {
+	Type         string               `json:"type"`
+	Message      *MessagesResponse    `json:"message,omitempty"`
+	Index        *int                 `json:"index,omitempty"`
+	ContentBlock any                  `json:"content_block,omitempty"`
+	Delta        *MessagesStreamDelta `json:"delta,omitempty"`
+	Usage        *MessagesUsage       `json:"usage,omitempty"`
+	Error        *MessagesError       `json:"error,omitempty"`
+}
+
+type MessagesError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// MessagesErrorType 将 HTTP 状态码转换为 Anthropic 的错误类型
+func MessagesErrorType(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
@@ -257,8 +257,9 @@ function getRedemptionData(data, dateRange) {
   for (const item of data) {
     const index = dates.indexOf(item.date);
     if (index !== -1) {
-      result[0].data[index] = calculateQuota(item.quota, 3);
-      result[1].data[index] = item.user_count;
+      // 每天按活动拆分为多行，这里汇总
+      result[0].data[index] = parseFloat((result[0].data[index] + parseFloat(calculateQuota(item.quota, 3))).toFixed(3));
+      result[1].data[index] += item.user_count;
     }
   }
 
@@ -19,8 +19,8 @@ export default function Overview() {
   });
   const [redemptionStatistics, setRedemptionStatistics] = useState({
     total: 0,
-    used: 0,
-    unused: 0
+    count: 0,
+    campaigns: 0
   });
 
   const userStatisticsData = useCallback(async () => {
@@ -74,16 +74,16 @@ export default function Overview() {
       const { success, message, data } = res.data;
       if (success) {
         let redemptionData = redemptionStatistics;
-        redemptionData.total = 0;
+        // 统计按活动拆分，这里汇总所有活动
+        let count = 0;
+        let total = 0;
         data.forEach((item) => {
-          if (item.status === 1) {
-            redemptionData.unused = renderQuota(item.quota);
-          } else if (item.status === 3) {
-            redemptionData.used = renderQuota(item.quota);
-          }
-          redemptionData.total += item.quota;
+          count += item.count;
+          total += item.quota;
         });
-        redemptionData.total = renderQuota(redemptionData.total);
+        redemptionData.count = count;
+        redemptionData.campaigns = data.filter((item) => item.campaign_id !== 0).length;
+        redemptionData.total = renderQuota(total);
         setRedemptionStatistics(redemptionData);
         setRedemptionLoading(false);
       } else {
@@ -137,11 +137,11 @@ export default function Overview() {
       <Grid item lg={3} xs={12}>
         <DataCard
           isLoading={redemptionLoading}
-          title="兑换码发行量"
+          title="兑换码兑换额度"
           content={redemptionStatistics.total}
           subContent={
             <>
-              已使用: {redemptionStatistics.used} <br /> 未使用: {redemptionStatistics.unused}
+              兑换次数: {redemptionStatistics.count} <br /> 活动数: {redemptionStatistics.campaigns}
             </>
           }
         />